@@ -0,0 +1,148 @@
+package address
+
+import (
+	"GoProject/params"
+	"GoProject/utils"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	HASH_LEN     = 20                          //RIPEMD-160哈希长度
+	CHECKSUM_LEN = 4                           //校验和长度
+	ADDRESS_LEN  = 1 + HASH_LEN + CHECKSUM_LEN //版本字节 + 哈希 + 校验和
+)
+
+var (
+	ErrEmpty    = errors.New("empty address")
+	ErrBase58   = errors.New("not valid base58")
+	ErrLength   = errors.New("wrong length")
+	ErrChecksum = errors.New("checksum mismatch")
+	ErrVersion  = errors.New("address is for another network")
+)
+
+// Base58Check地址: 版本字节 + 公钥哈希
+type Address struct {
+	Version byte
+	Hash    [HASH_LEN]byte
+}
+
+// 由公钥计算地址
+func FromPublicKey(pub *ecdsa.PublicKey, version byte) *Address {
+	return &Address{Version: version, Hash: hash160(PublicKeyBytes(pub))}
+}
+
+func hash160(b []byte) [HASH_LEN]byte {
+	//1. 执行SHA-256哈希运算
+	h2 := sha256.New()
+	h2.Write(b)
+	digest2 := h2.Sum(nil)
+	//2. 对SHA-256的结果执行RIPEMD-160哈希运算(20字节)
+	h3 := ripemd160.New()
+	h3.Write(digest2)
+	var hash [HASH_LEN]byte
+	copy(hash[:], h3.Sum(nil))
+	return hash
+}
+
+// 用于计算地址的公钥字节
+// P-256沿用X||Y,secp256k1与比特币一致使用SEC1压缩公钥
+func PublicKeyBytes(pub *ecdsa.PublicKey) []byte {
+	if id, err := utils.CurveIdOf(pub.Curve); err == nil && id == utils.CURVE_SECP256K1 {
+		return utils.CompressPublicKey(pub)
+	}
+	return append(pub.X.Bytes(), pub.Y.Bytes()...)
+}
+
+// 对 版本字节+哈希 进行两次SHA-256,取前4个字节作为校验和
+func checksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:CHECKSUM_LEN]
+}
+
+// 版本字节 + 哈希 + 校验和(25个字节),转换为base58编码
+func (a *Address) String() string {
+	b := make([]byte, 0, ADDRESS_LEN)
+	b = append(b, a.Version)
+	b = append(b, a.Hash[:]...)
+	b = append(b, checksum(b)...)
+	return base58.Encode(b)
+}
+
+// 解析地址并验证长度和校验和,不检查版本字节
+func Parse(s string) (*Address, error) {
+	if s == "" {
+		return nil, ErrEmpty
+	}
+	b := base58.Decode(s)
+	if len(b) == 0 {
+		return nil, fmt.Errorf("invalid address %q: %w", s, ErrBase58)
+	}
+	if len(b) != ADDRESS_LEN {
+		return nil, fmt.Errorf("invalid address %q: %w", s, ErrLength)
+	}
+	payload := b[:ADDRESS_LEN-CHECKSUM_LEN]
+	if !bytes.Equal(checksum(payload), b[ADDRESS_LEN-CHECKSUM_LEN:]) {
+		return nil, fmt.Errorf("invalid address %q: %w", s, ErrChecksum)
+	}
+	a := &Address{Version: payload[0]}
+	copy(a.Hash[:], payload[1:])
+	return a, nil
+}
+
+// 解析地址并检查版本字节属于网络p(普通地址、多重签名地址、脚本地址或合约地址)
+func Decode(s string, p *params.Params) (*Address, error) {
+	a, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if a.Version != p.AddressVersion && a.Version != p.MultisigVersion && a.Version != p.ScriptVersion &&
+		a.Version != p.ContractVersion {
+		return nil, fmt.Errorf("invalid address %q: %w (version 0x%02x, %s uses 0x%02x/0x%02x/0x%02x/0x%02x)",
+			s, ErrVersion, a.Version, p.Name, p.AddressVersion, p.MultisigVersion, p.ScriptVersion, p.ContractVersion)
+	}
+	return a, nil
+}
+
+func Validate(s string, p *params.Params) error {
+	_, err := Decode(s, p)
+	return err
+}
+
+// 脚本地址: 锁定脚本的哈希,使用单独的版本字节
+// 多重签名地址是公钥集合编码的哈希,共用版本字节时该编码可以作为锁定脚本花费多重签名地址的资金
+func FromScript(script []byte, p *params.Params) string {
+	a := &Address{Version: p.ScriptVersion, Hash: hash160(script)}
+	return a.String()
+}
+
+// 合约地址: 部署者地址(带长度前缀)、字节码的SHA-256和salt的哈希
+// 同一部署者用相同的字节码和salt只能部署一次
+func FromContract(sender string, code []byte, salt []byte, p *params.Params) string {
+	h := sha256.Sum256(code)
+	b := append([]byte{byte(len(sender))}, sender...)
+	b = append(b, h[:]...)
+	a := &Address{Version: p.ContractVersion, Hash: hash160(append(b, salt...))}
+	return a.String()
+}
+
+// 是否为网络p的合约地址
+func IsContract(s string, p *params.Params) bool {
+	a, err := Parse(s)
+	return err == nil && a.Version == p.ContractVersion
+}
+
+// 公钥是否对应地址s
+func MatchesPublicKey(s string, pub *ecdsa.PublicKey, p *params.Params) bool {
+	if pub == nil {
+		return false
+	}
+	return FromPublicKey(pub, p.AddressVersion).String() == s
+}
@@ -0,0 +1,72 @@
+package address
+
+import (
+	"GoProject/params"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+func TestRoundTrip(t *testing.T) {
+	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for _, p := range []*params.Params{params.MainNet, params.RegTest} {
+		s := FromPublicKey(&privateKey.PublicKey, p.AddressVersion).String()
+		a, err := Decode(s, p)
+		if err != nil {
+			t.Fatalf("%s: %v", p.Name, err)
+		}
+		if a.String() != s || !MatchesPublicKey(s, &privateKey.PublicKey, p) {
+			t.Errorf("%s: round trip of %s gives %s", p.Name, s, a)
+		}
+	}
+	if MatchesPublicKey(FromPublicKey(&privateKey.PublicKey, 0).String(), nil, params.MainNet) {
+		t.Error("nil public key matches")
+	}
+}
+
+// 主网地址的版本字节为0,编码后以1开头
+func TestVersionPrefix(t *testing.T) {
+	if s := (&Address{Version: params.MainNet.AddressVersion}).String(); s[0] != '1' {
+		t.Errorf("mainnet address %s", s)
+	}
+	if s := (&Address{Version: params.TestNet.AddressVersion}).String(); s[0] != 'm' && s[0] != 'n' {
+		t.Errorf("testnet address %s", s)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := (&Address{Version: params.MainNet.AddressVersion, Hash: [HASH_LEN]byte{1, 2, 3}}).String()
+	raw := base58.Decode(valid)
+	flip := func(i int) string {
+		b := append([]byte{}, raw...)
+		b[i] ^= 1
+		return base58.Encode(b)
+	}
+	tests := []struct {
+		name string
+		s    string
+		p    *params.Params
+		want error
+	}{
+		{"empty", "", params.MainNet, ErrEmpty},
+		{"invalid character", "0OIl", params.MainNet, ErrBase58},
+		{"too short", base58.Encode(raw[:ADDRESS_LEN-1]), params.MainNet, ErrLength},
+		{"too long", base58.Encode(append(raw, 0)), params.MainNet, ErrLength},
+		{"checksum changed", flip(ADDRESS_LEN - 1), params.MainNet, ErrChecksum},
+		{"hash changed", flip(5), params.MainNet, ErrChecksum},
+		{"version changed", flip(0), params.MainNet, ErrChecksum},
+		{"other network", valid, params.TestNet, ErrVersion},
+	}
+	for _, tt := range tests {
+		if _, err := Decode(tt.s, tt.p); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if err := Validate(valid, params.MainNet); err != nil {
+		t.Errorf("valid address rejected: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package address
+
+import (
+	"GoProject/params"
+	"GoProject/utils"
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// 多重签名最多的公钥数
+const MAX_MULTISIG_KEYS = 15
+
+// m-of-n多重签名: n个公钥中至少m个签名才能花费
+type Multisig struct {
+	threshold  int
+	publicKeys []*ecdsa.PublicKey //按编码排序,与公钥的顺序无关
+}
+
+func NewMultisig(threshold int, publicKeys []*ecdsa.PublicKey) (*Multisig, error) {
+	n := len(publicKeys)
+	if n == 0 || n > MAX_MULTISIG_KEYS {
+		return nil, fmt.Errorf("multisig needs 1 to %d public keys, got %d", MAX_MULTISIG_KEYS, n)
+	}
+	if threshold < 1 || threshold > n {
+		return nil, fmt.Errorf("invalid threshold %d for %d public keys", threshold, n)
+	}
+	encoded := make([][]byte, n)
+	keys := make([]*ecdsa.PublicKey, n)
+	for i, pub := range publicKeys {
+		if pub == nil {
+			return nil, fmt.Errorf("missing public key %d", i)
+		}
+		b, err := utils.EncodePublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = b
+		keys[i] = pub
+	}
+	sort.Sort(&keySorter{encoded, keys})
+	for i := 1; i < n; i++ {
+		if bytes.Equal(encoded[i-1], encoded[i]) {
+			return nil, fmt.Errorf("duplicate public key in multisig")
+		}
+	}
+	return &Multisig{threshold: threshold, publicKeys: keys}, nil
+}
+
+type keySorter struct {
+	encoded [][]byte
+	keys    []*ecdsa.PublicKey
+}
+
+func (s *keySorter) Len() int           { return len(s.keys) }
+func (s *keySorter) Less(i, j int) bool { return bytes.Compare(s.encoded[i], s.encoded[j]) < 0 }
+func (s *keySorter) Swap(i, j int) {
+	s.encoded[i], s.encoded[j] = s.encoded[j], s.encoded[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+func (ms *Multisig) Threshold() int {
+	return ms.threshold
+}
+
+func (ms *Multisig) PublicKeys() []*ecdsa.PublicKey {
+	return ms.publicKeys
+}
+
+// 公钥在排序后的位置,不存在时返回-1
+func (ms *Multisig) IndexOf(pub *ecdsa.PublicKey) int {
+	if pub == nil {
+		return -1
+	}
+	for i, k := range ms.publicKeys {
+		if k.Curve.Params().Name == pub.Curve.Params().Name && k.X.Cmp(pub.X) == 0 && k.Y.Cmp(pub.Y) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// 计算地址的内容: m(1字节) + n(1字节) + 排序后的公钥(曲线标识 + 压缩公钥)
+func (ms *Multisig) Bytes() []byte {
+	b := []byte{byte(ms.threshold), byte(len(ms.publicKeys))}
+	for _, pub := range ms.publicKeys {
+		k, _ := utils.EncodePublicKey(pub)
+		b = append(b, k...)
+	}
+	return b
+}
+
+// 多重签名地址,使用网络的多重签名版本字节
+func (ms *Multisig) Address(p *params.Params) string {
+	a := &Address{Version: p.MultisigVersion, Hash: hash160(ms.Bytes())}
+	return a.String()
+}
+
+func (ms *Multisig) MarshalJSON() ([]byte, error) {
+	keys := make([]string, len(ms.publicKeys))
+	for i, pub := range ms.publicKeys {
+		keys[i] = utils.PublicKeyToString(pub)
+	}
+	return json.Marshal(struct {
+		Threshold  int      `json:"threshold"`
+		PublicKeys []string `json:"public_keys"`
+	}{
+		Threshold:  ms.threshold,
+		PublicKeys: keys,
+	})
+}
+
+func (ms *Multisig) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Threshold  int      `json:"threshold"`
+		PublicKeys []string `json:"public_keys"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := ParseMultisig(v.Threshold, v.PublicKeys)
+	if err != nil {
+		return err
+	}
+	*ms = *parsed
+	return nil
+}
+
+// 由公钥字符串创建多重签名
+func ParseMultisig(threshold int, publicKeys []string) (*Multisig, error) {
+	keys := make([]*ecdsa.PublicKey, len(publicKeys))
+	for i, s := range publicKeys {
+		pub, err := utils.DecodePublicKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("public key %d: %v", i, err)
+		}
+		keys[i] = pub
+	}
+	return NewMultisig(threshold, keys)
+}
+
+// 是否为网络p的多重签名地址
+func IsMultisig(s string, p *params.Params) bool {
+	a, err := Parse(s)
+	return err == nil && a.Version == p.MultisigVersion
+}
@@ -0,0 +1,112 @@
+package address
+
+import (
+	"GoProject/params"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func newKeys(n int) []*ecdsa.PublicKey {
+	keys := make([]*ecdsa.PublicKey, n)
+	for i := range keys {
+		curve := elliptic.P256()
+		if i%2 == 1 {
+			curve = secp256k1.S256()
+		}
+		privateKey, _ := ecdsa.GenerateKey(curve, rand.Reader)
+		keys[i] = &privateKey.PublicKey
+	}
+	return keys
+}
+
+func TestNewMultisig(t *testing.T) {
+	keys := newKeys(MAX_MULTISIG_KEYS + 1)
+	tests := []struct {
+		name      string
+		threshold int
+		keys      []*ecdsa.PublicKey
+		ok        bool
+	}{
+		{"1 of 1", 1, keys[:1], true},
+		{"2 of 3", 2, keys[:3], true},
+		{"3 of 3", 3, keys[:3], true},
+		{"15 of 15", MAX_MULTISIG_KEYS, keys[:MAX_MULTISIG_KEYS], true},
+		{"threshold 0", 0, keys[:3], false},
+		{"threshold above n", 4, keys[:3], false},
+		{"no keys", 1, nil, false},
+		{"too many keys", 1, keys, false},
+		{"duplicate key", 2, []*ecdsa.PublicKey{keys[0], keys[1], keys[0]}, false},
+		{"nil key", 1, []*ecdsa.PublicKey{keys[0], nil}, false},
+	}
+	for _, tt := range tests {
+		_, err := NewMultisig(tt.threshold, tt.keys)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err %v", tt.name, err)
+		}
+	}
+}
+
+// 地址与公钥的顺序无关,与阈值有关
+func TestMultisigAddress(t *testing.T) {
+	keys := newKeys(3)
+	a, _ := NewMultisig(2, keys)
+	b, _ := NewMultisig(2, []*ecdsa.PublicKey{keys[2], keys[0], keys[1]})
+	c, _ := NewMultisig(3, keys)
+	if a.Address(params.MainNet) != b.Address(params.MainNet) {
+		t.Error("address depends on key order")
+	}
+	if a.Address(params.MainNet) == c.Address(params.MainNet) {
+		t.Error("2-of-3 and 3-of-3 share an address")
+	}
+	if !IsMultisig(a.Address(params.MainNet), params.MainNet) || IsMultisig(a.Address(params.MainNet), params.TestNet) {
+		t.Error("multisig address version")
+	}
+	if IsMultisig(FromPublicKey(keys[0], params.MainNet.AddressVersion).String(), params.MainNet) {
+		t.Error("single key address is multisig")
+	}
+	if err := Validate(a.Address(params.MainNet), params.MainNet); err != nil {
+		t.Error(err)
+	}
+	for i, k := range b.PublicKeys() {
+		if a.IndexOf(k) != i {
+			t.Errorf("key %d at index %d", i, a.IndexOf(k))
+		}
+	}
+	if a.IndexOf(newKeys(1)[0]) != -1 || a.IndexOf(nil) != -1 {
+		t.Error("unknown key has an index")
+	}
+
+	m, _ := json.Marshal(a)
+	var decoded Multisig
+	if err := json.Unmarshal(m, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Address(params.MainNet) != a.Address(params.MainNet) || decoded.Threshold() != 2 {
+		t.Errorf("json round trip %s", m)
+	}
+	if err := json.Unmarshal([]byte(`{"threshold":4,"public_keys":[]}`), &decoded); err == nil {
+		t.Error("invalid multisig json accepted")
+	}
+}
+
+// 公钥集合的编码作为锁定脚本时,脚本地址与多重签名地址不同
+func TestScriptAddressSeparated(t *testing.T) {
+	ms, _ := NewMultisig(2, newKeys(3))
+	for _, p := range []*params.Params{params.MainNet, params.TestNet, params.RegTest} {
+		s := FromScript(ms.Bytes(), p)
+		if s == ms.Address(p) {
+			t.Errorf("%s: script address equals the multisig address", p.Name)
+		}
+		if IsMultisig(s, p) {
+			t.Errorf("%s: script address is multisig", p.Name)
+		}
+		if err := Validate(s, p); err != nil {
+			t.Errorf("%s: %v", p.Name, err)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package block
 
 import (
+	"GoProject/address"
+	"GoProject/discovery"
+	"GoProject/params"
+	"GoProject/script"
 	utils "GoProject/utils"
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,21 +21,19 @@ import (
 
 //使用共识算法计算挖矿时间
 
-// 挖矿难度
+// 挖矿难度、自动挖矿间隔和端口范围由网络参数(params)决定
 const (
-	MINING_DIFFICULTY = 3
-	MINING_SENDER     = "THE BLOCKCHAIN"
-	MINING_REWARD     = 1.0
-	MINING_TIMER_SEC  = 20
-
-	//区块链端口开始反胃
-	BLOCKCHAIN_PORT_RANGE_START = 5000
-	BLOCKCHAIN_PORT_RANGE_END   = 5003
+	MINING_SENDER = "THE BLOCKCHAIN"
+	MINING_REWARD = 1.0
+
 	//使用IP范围  0~1：只使用一个
 	NEIGHBOR_IP_RANGE_START = 0
 	NEIGHBOR_IP_RANGE_END   = 1
 	//区块链同步时间
 	BLOCKCHAIN_NEIGHBOR_SYNC_SEC = 20
+
+	//节点间请求携带的网络magic,用于拒绝其他网络的节点
+	NETWORK_MAGIC_HEADER = "X-Network-Magic"
 )
 
 // 定义一个区块对象
@@ -38,6 +41,7 @@ type Block struct {
 	timestamp    int64
 	nonce        int
 	previousHash [32]byte
+	stateRoot    [32]byte //应用本区块交易后账户状态的稀疏默克尔树根
 	transactions []*Transaction
 }
 
@@ -45,6 +49,10 @@ func (b *Block) PreviousHash() [32]byte {
 	return b.previousHash
 }
 
+func (b *Block) StateRoot() [32]byte {
+	return b.stateRoot
+}
+
 func (b *Block) Nonce() int {
 	return b.nonce
 }
@@ -53,11 +61,12 @@ func (b *Block) Transactions() []*Transaction {
 	return b.transactions
 }
 
-func newBlock(nonce int, previousHash [32]byte, transactions []*Transaction) *Block {
+func newBlock(timestamp int64, nonce int, previousHash [32]byte, stateRoot [32]byte, transactions []*Transaction) *Block {
 	b := new(Block)
-	b.timestamp = time.Now().UnixNano()
+	b.timestamp = timestamp
 	b.nonce = nonce
 	b.previousHash = previousHash
+	b.stateRoot = stateRoot
 	b.transactions = transactions
 	return b
 }
@@ -73,34 +82,46 @@ func (b *Block) MarshalJSON() ([]byte, error) {
 		Timestamp    int64          `json:"timestamp"`
 		Nonce        int            `json:"nonce"`
 		PreviousHash string         `json:"previous_hash"`
+		StateRoot    string         `json:"state_root"`
 		Transactions []*Transaction `json:"transactions"`
 	}{
 		Timestamp:    b.timestamp,
 		Nonce:        b.nonce,
 		PreviousHash: fmt.Sprintf("%x", b.previousHash),
+		StateRoot:    fmt.Sprintf("%x", b.stateRoot),
 		Transactions: b.transactions,
 	})
 }
 
 // 反序列化
 func (b *Block) UnmarshalJSON(data []byte) error {
-	var previousHash string
+	var previousHash, stateRoot string
 	v := &struct {
 		Timestamp    *int64          `json:"timestamp"`
 		Nonce        *int            `json:"nonce"`
 		PreviousHash *string         `json:"previous_hash"`
+		StateRoot    *string         `json:"state_root"`
 		Transactions *[]*Transaction `json:"transactions"`
 	}{
 		Timestamp:    &b.timestamp,
 		Nonce:        &b.nonce,
 		PreviousHash: &previousHash,
+		StateRoot:    &stateRoot,
 		Transactions: &b.transactions,
 	}
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
-	ph, _ := hex.DecodeString(*v.PreviousHash)
+	ph, err := hex.DecodeString(*v.PreviousHash)
+	if err != nil || len(ph) != 32 {
+		return fmt.Errorf("invalid previous_hash %q", *v.PreviousHash)
+	}
 	copy(b.previousHash[:], ph[:32])
+	sr, err := hex.DecodeString(*v.StateRoot)
+	if err != nil || len(sr) != 32 {
+		return fmt.Errorf("invalid state_root %q", *v.StateRoot)
+	}
+	copy(b.stateRoot[:], sr)
 	return nil
 }
 
@@ -108,6 +129,7 @@ func (b *Block) Print() {
 	fmt.Printf("timestamp:           %d\n", b.timestamp)
 	fmt.Printf("nonce:               %d\n", b.nonce)
 	fmt.Printf("previous_hash:       %x\n", b.previousHash)
+	fmt.Printf("state_root:          %x\n", b.stateRoot)
 	for _, t := range b.transactions {
 		t.Print()
 	}
@@ -120,21 +142,60 @@ type BlockChain struct {
 	port              uint16     //当前节点监听端口号
 	mux               sync.Mutex //互斥锁
 
-	neighbors    []string   //附近节点列表
-	muxNeighbors sync.Mutex //节点同步锁
+	neighbors    []string             //附近节点列表
+	muxNeighbors sync.Mutex           //节点同步锁
+	discoverer   discovery.Discoverer //节点发现方式,为空时扫描本机网段
+
+	index        *BlockIndex                //所有已知区块(包括侧链)组成的区块树
+	state        *State                     //主链末端的账户状态
+	dataIndex    map[string][]*DataLocation //交易数据 -> 主链上携带该数据的交易
+	txIndex      map[[32]byte][]*TxLocation //交易ID -> 主链上的位置
+	addressIndex map[string][]*TxLocation   //地址 -> 主链上涉及该地址的交易,按高度递增
+
+	params      *params.Params   //所在网络的参数
+	checkpoints map[int][32]byte //检查点: 区块高度 -> 区块哈希
+	assumeValid *[32]byte        //该区块及其祖先跳过签名验证
+
+	mockTime int64 //回归测试网的模拟时间(unix秒),0表示使用系统时间
+
+	miner miner //自动挖矿的状态和统计
 }
 
 // 创建区块链同时创建第一个区块
-func NewBlockChain(blockChainAddress string, port uint16) *BlockChain {
-	b := &Block{}
+func NewBlockChain(blockChainAddress string, port uint16, p *params.Params) *BlockChain {
 	bc := new(BlockChain)
 	bc.blockChainAddress = blockChainAddress
-	//nonce为0,使用空区块的hash,创建第一个区块
-	bc.CreateBlock(0, b.Hash())
+	bc.params = p
+	bc.index = NewBlockIndex()
+	bc.state = NewState()
+	bc.dataIndex = make(map[string][]*DataLocation)
+	bc.txIndex = make(map[[32]byte][]*TxLocation)
+	bc.addressIndex = make(map[string][]*TxLocation)
+	bc.chain = append(bc.chain, newGenesisBlock(p))
+	bc.connectIndexes(0, bc.chain[0])
+	if _, err := bc.index.AddBlock(bc.chain[0], p.Difficulty, StatusValid, true); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
 	bc.port = port
 	return bc
 }
 
+// 同一网络的所有节点共享相同的创世区块:
+// 固定时间戳,nonce为0,使用空区块的hash作为前一个区块的hash,账户状态为空
+func newGenesisBlock(p *params.Params) *Block {
+	b := &Block{}
+	return &Block{
+		timestamp:    p.GenesisTimestamp,
+		previousHash: b.Hash(),
+		stateRoot:    NewState().Root(),
+		transactions: []*Transaction{},
+	}
+}
+
+func (bc *BlockChain) Params() *params.Params {
+	return bc.params
+}
+
 func (bc *BlockChain) Chain() []*Block {
 	return bc.chain
 }
@@ -144,11 +205,24 @@ func (bc *BlockChain) Run() {
 	bc.ResolveConflicts()
 }
 
+// 设置节点发现方式
+func (bc *BlockChain) SetDiscoverer(d discovery.Discoverer) {
+	bc.discoverer = d
+}
+
 func (bc *BlockChain) SetNeighbors() {
-	bc.neighbors = utils.FindNeighbors(
-		utils.GetHost(), bc.port,
-		NEIGHBOR_IP_RANGE_START, NEIGHBOR_IP_RANGE_END,
-		BLOCKCHAIN_PORT_RANGE_START, BLOCKCHAIN_PORT_RANGE_END)
+	if bc.discoverer == nil {
+		bc.discoverer = discovery.NewScanDiscoverer(
+			utils.GetHost(), bc.port,
+			NEIGHBOR_IP_RANGE_START, NEIGHBOR_IP_RANGE_END,
+			bc.params.PortRangeStart, bc.params.PortRangeEnd)
+	}
+	neighbors, err := bc.discoverer.FindPeers()
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		return
+	}
+	bc.neighbors = neighbors
 	log.Printf("%v", bc.neighbors)
 }
 
@@ -189,11 +263,59 @@ func (bc *BlockChain) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func (bc *BlockChain) CreateBlock(nonce int, previousHash [32]byte) *Block {
-	b := newBlock(nonce, previousHash, bc.transactionPool)
-	bc.chain = append(bc.chain, b)
-	bc.transactionPool = []*Transaction{}
-	return b
+// 当前主链末端在区块树中的节点
+func (bc *BlockChain) ActiveTip() *BlockNode {
+	return bc.index.LookupNode(bc.LastBlock().Hash())
+}
+
+// 列出区块树中的所有分支
+func (bc *BlockChain) Forks() []*ForkInfo {
+	return bc.index.Forks(bc.ActiveTip())
+}
+
+// 将一条链上的区块加入区块树,遇到无效区块时标记并返回错误
+// 链必须从本网络的创世区块开始,且每个区块都指向前一个区块
+func (bc *BlockChain) indexChain(chain []*Block) error {
+	if len(chain) == 0 {
+		return errors.New("empty chain")
+	}
+	if n := bc.index.LookupNode(chain[0].Hash()); n == nil || n.height != 0 {
+		return fmt.Errorf("unknown genesis block %x", chain[0].Hash())
+	}
+	assumed := bc.assumedValidHeight(chain)
+	state := NewState()
+	for i, b := range chain {
+		hash := b.Hash()
+		if i > 0 && b.previousHash != chain[i-1].Hash() {
+			return fmt.Errorf("block %x at height %d does not link to %x", hash, i, chain[i-1].Hash())
+		}
+		if n := bc.index.LookupNode(hash); n != nil {
+			if n.Status() == StatusInvalid {
+				return fmt.Errorf("block %x is invalid", hash)
+			}
+			state.Apply(b.transactions)
+			continue
+		}
+		var invalid error
+		if err := bc.checkCheckpoint(i, hash); err != nil {
+			invalid = err
+		} else if next, err := bc.validBlock(b, chain[:i], state, i > assumed); err != nil {
+			invalid = fmt.Errorf("block %x: %v", hash, err)
+		} else {
+			state = next
+		}
+		status := StatusValid
+		if invalid != nil {
+			status = StatusInvalid
+		}
+		if _, err := bc.index.AddBlock(b, bc.params.Difficulty, status, false); err != nil {
+			return err
+		}
+		if invalid != nil {
+			return invalid
+		}
+	}
+	return nil
 }
 
 func (bc *BlockChain) LastBlock() *Block {
@@ -213,135 +335,263 @@ func (bc *BlockChain) Print() {
 func (bc *BlockChain) CopyTransactionPool() []*Transaction {
 	transactions := make([]*Transaction, 0)
 	for _, t := range bc.transactionPool {
-		transactions = append(transactions,
-			NewTransaction(t.senderBlockchainAddress,
-				t.recipientBlockchainAddress,
-				t.value))
+		c := NewTransaction(t.senderBlockchainAddress,
+			t.recipientBlockchainAddress,
+			t.value)
+		c.senderPublicKey = t.senderPublicKey
+		c.signature = t.signature
+		c.nonce = t.nonce
+		c.multisig = t.multisig
+		c.signatures = t.signatures
+		c.lockTime = t.lockTime
+		c.lockScript = t.lockScript
+		c.unlockScript = t.unlockScript
+		c.code = t.code
+		c.input = t.input
+		c.gasLimit = t.gasLimit
+		c.token = t.token
+		c.data = t.data
+		transactions = append(transactions, c)
 	}
 	return transactions
 }
 
 // 检验找的哈希是否满足工作量证明的要求
-func (bc *BlockChain) ValidProof(nonce int, previousHash [32]byte, transactions []*Transaction, difficulty int) bool {
+func (bc *BlockChain) ValidProof(nonce int, previousHash [32]byte, stateRoot [32]byte, transactions []*Transaction, difficulty int) bool {
+	return MeetsDifficulty(nonce, previousHash, stateRoot, transactions, difficulty)
+}
+
+// 工作量证明使用的哈希值,时间戳固定为0
+func ProofHash(nonce int, previousHash [32]byte, stateRoot [32]byte, transactions []*Transaction) [32]byte {
+	guessBlock := Block{0, nonce, previousHash, stateRoot, transactions}
+	return guessBlock.Hash()
+}
+
+// 外部矿工也使用该函数检验哈希
+func MeetsDifficulty(nonce int, previousHash [32]byte, stateRoot [32]byte, transactions []*Transaction, difficulty int) bool {
 	//比较新区块哈希值的基准(前面是几个0,控制挖矿难度)
 	//0越少,找到有效哈希值所需的计算工作越少，挖矿相对容易
 	zeros := strings.Repeat("0", difficulty)
-	guessBlock := Block{0, nonce, previousHash, transactions}
 	//获得区块的哈希值
-	guessHashStr := fmt.Sprintf("%x", guessBlock.Hash())
+	guessHashStr := fmt.Sprintf("%x", ProofHash(nonce, previousHash, stateRoot, transactions))
 	//fmt.Println(guessHashStr)
 	return guessHashStr[:difficulty] == zeros
 }
 
-func (bc *BlockChain) ProofOfWork() int {
-	//上个区块的事务
-	transactions := bc.CopyTransactionPool()
-	//上个区块的hash
-	previousHash := bc.LastBlock().Hash()
-	nonce := 0
-	for !bc.ValidProof(nonce, previousHash, transactions, MINING_DIFFICULTY) {
-		nonce += 1
+func (bc *BlockChain) Mining() bool {
+	bc.mux.Lock() //加锁
+	//有交易产生时才能挖矿(回归测试网允许挖空区块)
+	if len(bc.transactionPool) == 0 && !bc.params.AllowEmptyBlocks {
+		bc.mux.Unlock()
+		return false
 	}
-	return nonce
-}
+	//在锁内复制候选区块,工作量证明在锁外进行,期间仍可接受交易和同步区块
+	tmpl := bc.newBlockTemplate(bc.RewardAddress())
+	bc.mux.Unlock()
+	start := time.Now()
+	nonce := tmpl.ProofOfWork()
+	elapsed := time.Since(start)
 
-func (bc *BlockChain) Mining() bool {
-	bc.mux.Lock()         //加锁
-	defer bc.mux.Unlock() //执行完成解锁
-	//有交易产生时才能挖矿
-	if len(bc.transactionPool) == 0 {
+	bc.mux.Lock()
+	if tmpl.PreviousHash != bc.LastBlock().Hash() {
+		bc.mux.Unlock()
+		log.Println("action=mining, status=stale")
 		return false
 	}
+	bc.connectMined(tmpl, nonce, elapsed)
+	bc.mux.Unlock() //执行完成解锁
+	bc.notifyNeighbors()
+	return true
+}
 
-	bc.AddTransaction(MINING_SENDER, bc.blockChainAddress, MINING_REWARD, nil, nil)
-	nonce := bc.ProofOfWork()
-	previousHash := bc.LastBlock().Hash()
-	bc.CreateBlock(nonce, previousHash)
+// 把挖矿奖励发给rewardAddress,并用交易池中的交易挖出一个区块,调用方需持有bc.mux
+func (bc *BlockChain) mineBlock(rewardAddress string) *Block {
+	tmpl := bc.newBlockTemplate(rewardAddress)
+	start := time.Now()
+	nonce := tmpl.ProofOfWork()
+	return bc.connectMined(tmpl, nonce, time.Since(start))
+}
+
+// 把本节点挖出的区块连接到主链末端,候选区块必须基于当前的主链末端,调用方需持有bc.mux
+func (bc *BlockChain) connectMined(tmpl *BlockTemplate, nonce int, elapsed time.Duration) *Block {
+	b := tmpl.Block(nonce)
+	state := bc.state.Copy()
+	state.Apply(b.transactions)
+	bc.connectBlock(b, state)
+	bc.miner.recordBlock(nonce+1, elapsed, b.timestamp)
 	log.Println("action=mining, status=success")
+	return b
+}
 
+// 通知邻居节点执行共识
+func (bc *BlockChain) notifyNeighbors() {
 	for _, n := range bc.neighbors {
 		endpoint := fmt.Sprintf("http://%s/consensus", n)
 		client := &http.Client{}
 		req, _ := http.NewRequest("PUT", endpoint, nil)
-		resp, _ := client.Do(req)
+		req.Header.Set(NETWORK_MAGIC_HEADER, bc.params.MagicString())
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			continue
+		}
+		resp.Body.Close()
 		log.Printf("%v", resp)
 	}
-	return true
 }
 
-// 开始挖矿
-func (bc *BlockChain) StartMining() {
-	bc.Mining()
-	_ = time.AfterFunc(time.Second*MINING_TIMER_SEC, bc.StartMining)
+// 根据区块链地址获取虚拟币数量,从主链末端的账户状态中读取
+func (bc *BlockChain) CalculateTotalAmount(blockChainAddress string) float32 {
+	return bc.state.Account(blockChainAddress).Balance
 }
 
-// 根据区块链地址获取虚拟币数量
-func (bc *BlockChain) CalculateTotalAmount(blockChainAddress string) float32 {
-	var totalAmount float32 = 0.0
-	for _, c := range bc.chain {
-		transactions := c.transactions
-		for _, t := range transactions {
-			value := t.value
-			if t.recipientBlockchainAddress == blockChainAddress {
-				totalAmount += value
+// 发送方下一笔交易应使用的nonce,包含交易池中尚未打包的交易
+func (bc *BlockChain) NextNonce(blockChainAddress string) uint64 {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.pendingState().Account(blockChainAddress).Nonce
+}
+
+// 验证区块的工作量证明、时间戳、交易的锁定时间和格式、gas上限、挖矿奖励、余额和状态根,checkSignatures为true时同时验证交易签名
+// prev为该区块之前的链,用于计算高度和中位时间;state为prev之后的账户状态
+// 验证通过时返回应用该区块后的账户状态
+func (bc *BlockChain) validBlock(b *Block, prev []*Block, state *State, checkSignatures bool) (*State, error) {
+	if !bc.ValidProof(b.nonce, b.previousHash, b.stateRoot, b.transactions, bc.params.Difficulty) {
+		return nil, fmt.Errorf("invalid proof of work")
+	}
+	height, mtp := int64(len(prev)), medianTimePast(prev)
+	//时间戳必须晚于中位时间,且不能超前本节点时间太多,否则矿工可以操纵时间锁
+	if ts := b.timestamp / int64(time.Second); ts <= mtp {
+		return nil, fmt.Errorf("block timestamp %d is not after median time past %d", ts, mtp)
+	}
+	if b.timestamp > bc.Now().Add(time.Second*MAX_FUTURE_BLOCK_SEC).UnixNano() {
+		return nil, fmt.Errorf("block timestamp too far in the future")
+	}
+	var gas uint64
+	for _, t := range b.transactions {
+		if err := t.checkLockTime(height, mtp); err != nil {
+			return nil, err
+		}
+		if err := t.checkContract(bc.params); err != nil {
+			return nil, err
+		}
+		if err := t.checkToken(); err != nil {
+			return nil, err
+		}
+		if err := t.checkData(); err != nil {
+			return nil, err
+		}
+		gas += t.gasLimit
+	}
+	if gas > bc.params.BlockGasLimit {
+		return nil, fmt.Errorf("block gas %d exceeds limit %d", gas, bc.params.BlockGasLimit)
+	}
+	//只能有一笔挖矿奖励且金额正确,其他交易的nonce必须连续,发送方余额在交易执行前必须足够支付金额和手续费
+	next := state.Copy()
+	coinbase := 0
+	for _, t := range b.transactions {
+		if t.senderBlockchainAddress == MINING_SENDER {
+			coinbase += 1
+			if t.value != MINING_REWARD {
+				return nil, fmt.Errorf("invalid coinbase value %.1f", t.value)
+			}
+			if t.nonce != uint64(height) {
+				return nil, fmt.Errorf("coinbase nonce must be the block height %d, got %d", height, t.nonce)
+			}
+		} else {
+			if err := t.checkValue(); err != nil {
+				return nil, err
+			}
+			if err := next.checkNonce(t); err != nil {
+				return nil, err
 			}
-			if t.senderBlockchainAddress == blockChainAddress {
-				totalAmount -= value
+			if next.Account(t.senderBlockchainAddress).Balance < t.value+t.fee() {
+				return nil, fmt.Errorf("not enough balance in %s", t.senderBlockchainAddress)
 			}
 		}
+		if err := next.checkToken(t); err != nil {
+			return nil, err
+		}
+		next.Apply([]*Transaction{t})
 	}
-	return totalAmount
-}
-
-// 验证区块链有效性
-func (bc *BlockChain) ValidChain(chain []*Block) bool {
-	//获取初始区块
-	preBlock := chain[0]
-	//从第二个区块开始遍历区块链
-	currentIndex := 1
-	for currentIndex < len(chain) {
-		b := chain[currentIndex]
-		//检查与前一个区块的哈希值相匹配
-		if b.previousHash != preBlock.Hash() {
-			return false
+	if coinbase != 1 {
+		return nil, fmt.Errorf("block must contain exactly one coinbase transaction, got %d", coinbase)
+	}
+	if root := next.Root(); root != b.stateRoot {
+		return nil, fmt.Errorf("state root %x does not match %x", b.stateRoot, root)
+	}
+	if !checkSignatures {
+		return next, nil
+	}
+	for _, t := range b.transactions {
+		if t.senderBlockchainAddress == MINING_SENDER {
+			continue
 		}
-		//验证工作量证明
-		if !bc.ValidProof(b.nonce, b.previousHash, b.transactions, MINING_DIFFICULTY) {
-			return false
+		if err := bc.verifyTransaction(t, height, mtp); err != nil {
+			return nil, err
 		}
-		//替换区块,继续验证下一个区块
-		preBlock = b
-		currentIndex += 1
 	}
-	return true
+	return next, nil
 }
 
 func (bc *BlockChain) ResolveConflicts() bool {
-	var longestChain []*Block = nil
-	maxLeng := len(bc.chain)
 	//遍历区块链节点
 	for _, n := range bc.neighbors {
 		//对每个邻居节点发起 HTTP GET 请求，获取它们的区块链。
 		endpoint := fmt.Sprintf("http://%s/chain", n)
-		resp, _ := http.Get(endpoint)
+		resp, err := http.Get(endpoint)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			continue
+		}
+		//忽略其他网络的节点
+		if magic := resp.Header.Get(NETWORK_MAGIC_HEADER); magic != bc.params.MagicString() {
+			log.Printf("ERROR: neighbor %s is on another network (magic %q)", n, magic)
+			resp.Body.Close()
+			continue
+		}
 		if resp.StatusCode == http.StatusOK {
 			//解析响应体中的 BlockChain 结构
 			var bcResp BlockChain
 			decoder := json.NewDecoder(resp.Body)
 			_ = decoder.Decode(&bcResp)
-			chain := bcResp.chain
-			//判断获取的链是否比当前的链更长,更长则验证其有消息
-			if len(chain) > maxLeng && bc.ValidChain(chain) {
-				//更新链长度
-				maxLeng = len(bc.chain)
-				//替换当前节点的链
-				longestChain = chain
+			//不论是否采用,都把邻居的链记录到区块树中
+			if err := bc.indexChain(bcResp.chain); err != nil {
+				log.Printf("ERROR: chain from %s: %v", n, err)
 			}
 		}
+		resp.Body.Close()
 	}
-	//longestChain不为空，说明替换成功
-	if longestChain != nil {
-		bc.chain = longestChain
+	//选择累计工作量最大的分支,切换主链和更新索引时持有bc.mux
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	best := bc.index.BestTip()
+	tip := bc.ActiveTip()
+	if best != nil && best != tip && best.chainWork.Cmp(tip.chainWork) > 0 {
+		//从分叉点断开旧分支的区块,再连接新分支的区块
+		forkHeight := -1
+		if fork := FindFork(tip, best); fork != nil {
+			forkHeight = fork.height
+		}
+		//旧分支上的交易(挖矿奖励除外)放回交易池,排在原有交易之前
+		var pool []*Transaction
+		for _, b := range bc.chain[forkHeight+1:] {
+			for _, t := range b.transactions {
+				if t.senderBlockchainAddress != MINING_SENDER {
+					pool = append(pool, t)
+				}
+			}
+		}
+		pool = append(pool, bc.transactionPool...)
+		for h := len(bc.chain) - 1; h > forkHeight; h-- {
+			bc.disconnectIndexes(h, bc.chain[h])
+		}
+		bc.chain = bc.index.Chain(best)
+		bc.state = stateOf(bc.chain)
+		for h := forkHeight + 1; h < len(bc.chain); h++ {
+			bc.connectIndexes(h, bc.chain[h])
+		}
+		bc.transactionPool = bc.revalidatePool(pool)
 		log.Printf("Resolve conflicts replaced")
 		return true
 	}
@@ -354,10 +604,40 @@ type Transaction struct {
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
 	value                      float32
+	senderPublicKey            *ecdsa.PublicKey //发送方公钥,挖矿奖励为空
+	signature                  *utils.Signature //发送方签名,挖矿奖励为空
+	nonce                      uint64           //必须等于执行前发送方账户的nonce,签名后不能重放,挖矿奖励为0
+
+	multisig   *address.Multisig  //发送方为多重签名地址时的公钥集合
+	signatures []*utils.Signature //与multisig中的公钥一一对应,未签名为nil
+
+	lockTime int64 //锁定时间: 0表示不锁定,小于LOCKTIME_THRESHOLD为区块高度,否则为unix时间(秒)
+
+	lockScript   script.Script //发送方为脚本地址时的锁定脚本,地址为其哈希
+	unlockScript script.Script //满足锁定脚本的解锁脚本
+
+	code     []byte //部署合约时的字节码
+	input    []byte //调用合约时的参数,部署时为salt
+	gasLimit uint64 //大于0表示合约交易
+
+	token *utils.TokenOp //代币交易的操作,为空表示普通交易
+
+	data []byte //附带的数据,按字节收取手续费
 }
 
 func NewTransaction(sender string, recipient string, value float32) *Transaction {
-	return &Transaction{sender, recipient, value}
+	return &Transaction{
+		senderBlockchainAddress:    sender,
+		recipientBlockchainAddress: recipient,
+		value:                      value,
+	}
+}
+
+// 挖矿奖励交易,nonce为区块高度,使不同区块的挖矿奖励交易ID不同
+func newCoinbase(rewardAddress string, height int) *Transaction {
+	t := NewTransaction(MINING_SENDER, rewardAddress, MINING_REWARD)
+	t.nonce = uint64(height)
+	return t
 }
 
 func (t *Transaction) Print() {
@@ -368,15 +648,65 @@ func (t *Transaction) Print() {
 }
 
 func (t *Transaction) MarshalJSON() ([]byte, error) {
+	var publicKey, signature string
+	if t.senderPublicKey != nil {
+		publicKey = utils.PublicKeyToString(t.senderPublicKey)
+	}
+	if t.signature != nil {
+		signature = t.signature.String()
+	}
 	return json.Marshal(struct {
-		Sender    string  `json:"sender_blockchain_address"`
-		Recipient string  `json:"recipient_blockchain_address"`
-		Value     float32 `json:"value"`
+		Sender          string            `json:"sender_blockchain_address"`
+		Recipient       string            `json:"recipient_blockchain_address"`
+		Value           float32           `json:"value"`
+		Nonce           uint64            `json:"nonce,omitempty"`
+		LockTime        int64             `json:"lock_time,omitempty"`
+		SenderPublicKey string            `json:"sender_public_key,omitempty"`
+		Signature       string            `json:"signature,omitempty"`
+		Multisig        *address.Multisig `json:"multisig,omitempty"`
+		Signatures      []string          `json:"signatures,omitempty"`
+		LockScript      string            `json:"lock_script,omitempty"`
+		UnlockScript    string            `json:"unlock_script,omitempty"`
+		Code            string            `json:"code,omitempty"`
+		Input           string            `json:"input,omitempty"`
+		GasLimit        uint64            `json:"gas_limit,omitempty"`
+		Token           *utils.TokenOp    `json:"token,omitempty"`
+		Data            string            `json:"data,omitempty"`
 	}{
+		Sender:          t.senderBlockchainAddress,
+		Recipient:       t.recipientBlockchainAddress,
+		Value:           t.value,
+		Nonce:           t.nonce,
+		LockTime:        t.lockTime,
+		SenderPublicKey: publicKey,
+		Signature:       signature,
+		Multisig:        t.multisig,
+		Signatures:      utils.SignaturesToStrings(t.signatures),
+		LockScript:      t.lockScript.Hex(),
+		UnlockScript:    t.unlockScript.Hex(),
+		Code:            hex.EncodeToString(t.code),
+		Input:           hex.EncodeToString(t.input),
+		GasLimit:        t.gasLimit,
+		Token:           t.token,
+		Data:            hex.EncodeToString(t.data),
+	})
+}
+
+// 签名的内容,不包含公钥和签名本身
+func (t *Transaction) signingPayload(chainId uint32) *utils.SigningPayload {
+	return &utils.SigningPayload{
+		ChainId:   chainId,
 		Sender:    t.senderBlockchainAddress,
 		Recipient: t.recipientBlockchainAddress,
 		Value:     t.value,
-	})
+		Nonce:     t.nonce,
+		LockTime:  t.lockTime,
+		Code:      hex.EncodeToString(t.code),
+		Input:     hex.EncodeToString(t.input),
+		GasLimit:  t.gasLimit,
+		Token:     t.token,
+		Data:      hex.EncodeToString(t.data),
+	}
 }
 func (bc *BlockChain) CreateTransaction(sender string, recipient string, value float32,
 	senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
@@ -386,65 +716,326 @@ func (bc *BlockChain) CreateTransaction(sender string, recipient string, value f
 
 func (bc *BlockChain) AddTransaction(sender string, recipient string, value float32, senderPublicKey *ecdsa.PublicKey,
 	s *utils.Signature) bool {
+	if err := bc.AcceptTransaction(sender, recipient, value, bc.NextNonce(sender), 0, nil, senderPublicKey, s); err != nil {
+		log.Printf("ERROR: %v", err)
+		return false
+	}
+	return true
+}
+
+// 验证交易并加入交易池,失败时返回原因
+// lockTime为0时不锁定
+func (bc *BlockChain) AcceptTransaction(sender string, recipient string, value float32, nonce uint64, lockTime int64, data []byte,
+	senderPublicKey *ecdsa.PublicKey, s *utils.Signature) error {
 	t := NewTransaction(sender, recipient, value)
+	t.nonce = nonce
+	t.lockTime = lockTime
+	t.data = data
+	t.senderPublicKey = senderPublicKey
+	t.signature = s
+	return bc.acceptTransaction(t)
+}
 
-	if sender == MINING_SENDER {
-		bc.transactionPool = append(bc.transactionPool, t)
-		return true
+// 验证多重签名交易并加入交易池,signatures与ms中排序后的公钥一一对应
+func (bc *BlockChain) AcceptMultisigTransaction(sender string, recipient string, value float32, nonce uint64, lockTime int64, data []byte,
+	ms *address.Multisig, signatures []*utils.Signature) error {
+	if ms == nil {
+		return fmt.Errorf("missing multisig")
+	}
+	t := NewTransaction(sender, recipient, value)
+	t.nonce = nonce
+	t.lockTime = lockTime
+	t.data = data
+	t.multisig = ms
+	t.signatures = signatures
+	return bc.acceptTransaction(t)
+}
+
+// 验证脚本交易并加入交易池,发送地址必须是锁定脚本的哈希
+func (bc *BlockChain) AcceptScriptTransaction(sender string, recipient string, value float32, nonce uint64, lockTime int64, data []byte,
+	lock script.Script, unlock script.Script) error {
+	if len(lock) == 0 {
+		return fmt.Errorf("missing lock script")
+	}
+	t := NewTransaction(sender, recipient, value)
+	t.nonce = nonce
+	t.lockTime = lockTime
+	t.data = data
+	t.lockScript = lock
+	t.unlockScript = unlock
+	return bc.acceptTransaction(t)
+}
+
+func (bc *BlockChain) acceptTransaction(t *Transaction) error {
+	//挖矿奖励只能由挖矿生成,不能作为交易提交
+	if t.senderBlockchainAddress == MINING_SENDER {
+		return fmt.Errorf("sender %s is reserved for the coinbase", MINING_SENDER)
+	}
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if err := address.Validate(t.recipientBlockchainAddress, bc.params); err != nil {
+		return fmt.Errorf("recipient: %v", err)
 	}
-	if bc.VerifyTransactionSignature(senderPublicKey, s, t) {
-		if bc.CalculateTotalAmount(sender) < value {
-			log.Println("Error: Not enough balance in a wallet")
-			return false
+	if err := address.Validate(t.senderBlockchainAddress, bc.params); err != nil {
+		return fmt.Errorf("sender: %v", err)
+	}
+	if err := t.checkValue(); err != nil {
+		return err
+	}
+	if err := t.checkData(); err != nil {
+		return err
+	}
+	if err := t.checkContract(bc.params); err != nil {
+		return err
+	}
+	if err := t.checkToken(); err != nil {
+		return err
+	}
+	//交易池中的交易将被打包进下一个区块,只接受已解锁的交易
+	height, mtp := int64(len(bc.chain)), bc.MedianTimePast()
+	if err := t.checkLockTime(height, mtp); err != nil {
+		return err
+	}
+	if err := bc.verifyTransaction(t, height, mtp); err != nil {
+		return err
+	}
+	if err := bc.checkPending(bc.pendingState(), bc.poolGas(), t); err != nil {
+		return err
+	}
+	bc.transactionPool = append(bc.transactionPool, t)
+	return nil
+}
+
+// 按交易池中的交易之后的nonce、余额和gas检查,与打包进区块时的验证一致
+// pending为应用交易池之后的状态,不会被修改;gas为交易池中合约交易的gas上限之和
+func (bc *BlockChain) checkPending(pending *State, gas uint64, t *Transaction) error {
+	if err := pending.checkNonce(t); err != nil {
+		return err
+	}
+	if pending.Account(t.senderBlockchainAddress).Balance < t.value+t.fee() {
+		return fmt.Errorf("not enough balance in %s", t.senderBlockchainAddress)
+	}
+	if t.isContract() {
+		if gas+t.gasLimit > bc.params.BlockGasLimit {
+			return fmt.Errorf("block gas limit %d reached", bc.params.BlockGasLimit)
+		}
+		if err := tryContract(pending.Copy(), t); err != nil {
+			return err
 		}
-		bc.transactionPool = append(bc.transactionPool, t)
-		return true
-	} else {
-		log.Println("ERROR: Verify Transaction")
 	}
-	return false
+	if t.isToken() {
+		if err := pending.checkToken(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 切换主链后按新的主链末端重新验证交易,返回仍能打包的交易
+// 已打包进新主链的交易nonce不再匹配,与锁定时间未到、余额不足或执行失败的交易一起被丢弃,调用方需持有bc.mux
+func (bc *BlockChain) revalidatePool(transactions []*Transaction) []*Transaction {
+	pending := bc.state.Copy()
+	height, mtp := int64(len(bc.chain)), bc.MedianTimePast()
+	var gas uint64
+	pool := make([]*Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		err := t.checkLockTime(height, mtp)
+		if err == nil {
+			err = bc.checkPending(pending, gas, t)
+		}
+		if err != nil {
+			log.Printf("action=drop_transaction, reason=%v", err)
+			continue
+		}
+		pending.Apply([]*Transaction{t})
+		gas += t.gasLimit
+		pool = append(pool, t)
+	}
+	return pool
+}
+
+// 多重签名: 公钥集合对应发送地址,至少threshold个有效签名,不允许无效签名
+func (bc *BlockChain) verifyMultisig(t *Transaction) error {
+	ms := t.multisig
+	if ms.Address(bc.params) != t.senderBlockchainAddress {
+		return fmt.Errorf("multisig does not match address %s", t.senderBlockchainAddress)
+	}
+	keys := ms.PublicKeys()
+	if len(t.signatures) != len(keys) {
+		return fmt.Errorf("expected %d signature slots, got %d", len(keys), len(t.signatures))
+	}
+	valid := 0
+	for i, s := range t.signatures {
+		if s == nil {
+			continue
+		}
+		if !bc.VerifyTransactionSignature(keys[i], s, t) {
+			return fmt.Errorf("invalid signature %d in transaction from %s", i, t.senderBlockchainAddress)
+		}
+		valid += 1
+	}
+	if valid < ms.Threshold() {
+		return fmt.Errorf("multisig transaction from %s has %d of %d required signatures",
+			t.senderBlockchainAddress, valid, ms.Threshold())
+	}
+	return nil
+}
+
+// 脚本: 锁定脚本对应发送地址,解锁脚本满足锁定脚本
+func (bc *BlockChain) verifyScript(t *Transaction, height int64, mtp int64) error {
+	if address.FromScript(t.lockScript, bc.params) != t.senderBlockchainAddress {
+		return fmt.Errorf("lock script does not match address %s", t.senderBlockchainAddress)
+	}
+	ctx := &script.Context{
+		SigHash: t.signingPayload(bc.params.ChainId).Hash(),
+		Height:  height,
+		Time:    mtp,
+	}
+	if err := script.Verify(t.unlockScript, t.lockScript, ctx); err != nil {
+		return fmt.Errorf("script of transaction from %s: %v", t.senderBlockchainAddress, err)
+	}
+	return nil
+}
+
+// 验证发送方公钥与地址一致,并验证签名
+// height和mtp为交易所在区块的高度和中位时间(unix秒),用于脚本的时间锁
+func (bc *BlockChain) verifyTransaction(t *Transaction, height int64, mtp int64) error {
+	if t.lockScript != nil {
+		return bc.verifyScript(t, height, mtp)
+	}
+	if t.multisig != nil {
+		return bc.verifyMultisig(t)
+	}
+	if t.senderPublicKey == nil {
+		return fmt.Errorf("missing or invalid sender public key")
+	}
+	if !address.MatchesPublicKey(t.senderBlockchainAddress, t.senderPublicKey, bc.params) {
+		return fmt.Errorf("sender public key does not match address %s", t.senderBlockchainAddress)
+	}
+	if !bc.VerifyTransactionSignature(t.senderPublicKey, t.signature, t) {
+		return fmt.Errorf("invalid signature in transaction from %s", t.senderBlockchainAddress)
+	}
+	return nil
 }
 
 func (bc *BlockChain) VerifyTransactionSignature(
 	senderPublicKey *ecdsa.PublicKey, s *utils.Signature, t *Transaction) bool {
-	m, _ := json.Marshal(t)
-	h := sha256.Sum256([]byte(m))
+	if senderPublicKey == nil || s == nil {
+		return false
+	}
+	h := t.signingPayload(bc.params.ChainId).Hash()
 	return ecdsa.Verify(senderPublicKey, h[:], s.R, s.S)
 }
 
 func (t *Transaction) UnmarshalJSON(data []byte) error {
+	var publicKey, signature string
+	var signatures []string
+	var lockScript, unlockScript string
+	var code, input, dataHex string
 	v := &struct {
-		Sender    *string  `json:"sender_blockchain_address"`
-		Recipient *string  `json:"recipient_blockchain_address"`
-		Value     *float32 `json:"value"`
+		Sender          *string            `json:"sender_blockchain_address"`
+		Recipient       *string            `json:"recipient_blockchain_address"`
+		Value           *float32           `json:"value"`
+		Nonce           *uint64            `json:"nonce"`
+		LockTime        *int64             `json:"lock_time"`
+		SenderPublicKey *string            `json:"sender_public_key"`
+		Signature       *string            `json:"signature"`
+		Multisig        **address.Multisig `json:"multisig"`
+		Signatures      *[]string          `json:"signatures"`
+		LockScript      *string            `json:"lock_script"`
+		UnlockScript    *string            `json:"unlock_script"`
+		Code            *string            `json:"code"`
+		Input           *string            `json:"input"`
+		GasLimit        *uint64            `json:"gas_limit"`
+		Token           **utils.TokenOp    `json:"token"`
+		Data            *string            `json:"data"`
 	}{
-		Sender:    &t.senderBlockchainAddress,
-		Recipient: &t.recipientBlockchainAddress,
-		Value:     &t.value,
+		Sender:          &t.senderBlockchainAddress,
+		Recipient:       &t.recipientBlockchainAddress,
+		Value:           &t.value,
+		Nonce:           &t.nonce,
+		LockTime:        &t.lockTime,
+		SenderPublicKey: &publicKey,
+		Signature:       &signature,
+		Multisig:        &t.multisig,
+		Signatures:      &signatures,
+		LockScript:      &lockScript,
+		UnlockScript:    &unlockScript,
+		Code:            &code,
+		Input:           &input,
+		GasLimit:        &t.gasLimit,
+		Token:           &t.token,
+		Data:            &dataHex,
 	}
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
+	if publicKey != "" {
+		t.senderPublicKey = utils.PublicKeyFromString(publicKey)
+	}
+	if len(signature) == 128 {
+		t.signature = utils.SignatureFromString(signature)
+	}
+	if signatures != nil {
+		t.signatures = utils.SignaturesFromStrings(signatures)
+	}
+	if lockScript != "" {
+		var err error
+		if t.lockScript, err = script.FromHex(lockScript); err != nil {
+			return err
+		}
+		if t.unlockScript, err = script.FromHex(unlockScript); err != nil {
+			return err
+		}
+	}
+	var err error
+	if t.code, err = decodeBytes(code); err != nil {
+		return fmt.Errorf("invalid code: %v", err)
+	}
+	if t.input, err = decodeBytes(input); err != nil {
+		return fmt.Errorf("invalid input: %v", err)
+	}
+	if t.data, err = decodeBytes(dataHex); err != nil {
+		return fmt.Errorf("invalid data: %v", err)
+	}
 	return nil
 }
 
 type TransactionRequest struct {
-	SenderBlockChainAddress   *string  `json:"sender_blockchain_address"`
-	ReceiverBlockChainAddress *string  `json:"receiver_blockchain_address"`
-	SenderPublicKey           *string  `json:"sender_public_key"`
-	Value                     *float32 `json:"value"`
-	Signature                 *string  `json:"signature"`
+	SenderBlockChainAddress   *string           `json:"sender_blockchain_address"`
+	ReceiverBlockChainAddress *string           `json:"receiver_blockchain_address"`
+	SenderPublicKey           *string           `json:"sender_public_key,omitempty"`
+	Value                     *float32          `json:"value"`
+	Nonce                     uint64            `json:"nonce,omitempty"`     //发送方账户的nonce,GET /nonce获取
+	LockTime                  int64             `json:"lock_time,omitempty"` //0表示不锁定
+	Signature                 *string           `json:"signature,omitempty"`
+	Multisig                  *address.Multisig `json:"multisig,omitempty"`      //多重签名交易代替公钥
+	Signatures                []string          `json:"signatures,omitempty"`    //多重签名交易代替签名
+	LockScript                *string           `json:"lock_script,omitempty"`   //脚本交易的锁定脚本(十六进制)
+	UnlockScript              *string           `json:"unlock_script,omitempty"` //脚本交易的解锁脚本(十六进制)
+	Code                      string            `json:"code,omitempty"`          //合约交易的字节码和参数(十六进制)
+	Input                     string            `json:"input,omitempty"`
+	GasLimit                  uint64            `json:"gas_limit,omitempty"` //大于0表示合约交易
+	Token                     *utils.TokenOp    `json:"token,omitempty"`     //代币交易的操作
+	Data                      string            `json:"data,omitempty"`      //附带的数据(十六进制)
 }
 
 func (tr *TransactionRequest) Validate() bool {
 	if tr.SenderBlockChainAddress == nil ||
 		tr.ReceiverBlockChainAddress == nil ||
-		tr.SenderPublicKey == nil ||
-		tr.Value == nil ||
-		tr.Signature == nil {
+		tr.Value == nil {
 		return false
 	}
-	return true
+	if tr.GasLimit > 0 || tr.Token != nil { //合约和代币交易只支持单个公钥的发送方
+		return tr.LockScript == nil && tr.Multisig == nil && tr.SenderPublicKey != nil && tr.Signature != nil
+	}
+	if tr.LockScript != nil {
+		return tr.UnlockScript != nil
+	}
+	if tr.Multisig != nil {
+		return tr.Signatures != nil
+	}
+	return tr.SenderPublicKey != nil && tr.Signature != nil
 }
 
 type AmountResponse struct {
@@ -0,0 +1,232 @@
+package block
+
+import (
+	"GoProject/address"
+	"GoProject/params"
+	"GoProject/utils"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// 节点的挖矿地址
+var testMiner = (&address.Address{Version: params.RegTest.AddressVersion}).String()
+
+type testKey struct {
+	privateKey *ecdsa.PrivateKey
+	address    string
+}
+
+func newTestKey(t *testing.T) *testKey {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKey{privateKey, address.FromPublicKey(&privateKey.PublicKey, params.RegTest.AddressVersion).String()}
+}
+
+// 使用chainId签名交易
+func signTransaction(t *testing.T, privateKey *ecdsa.PrivateKey, tx *Transaction, chainId uint32) *Transaction {
+	t.Helper()
+	h := tx.signingPayload(chainId).Hash()
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.senderPublicKey = &privateKey.PublicKey
+	tx.signature = &utils.Signature{R: r, S: s}
+	return tx
+}
+
+// 同一网络的节点共享创世区块,不同网络的创世区块不同
+func TestGenesisBlock(t *testing.T) {
+	a := NewBlockChain("a", 0, params.RegTest)
+	b := NewBlockChain("b", 0, params.RegTest)
+	if a.Chain()[0].Hash() != b.Chain()[0].Hash() {
+		t.Errorf("regtest nodes have different genesis blocks")
+	}
+	c := NewBlockChain("c", 0, params.TestNet)
+	if a.Chain()[0].Hash() == c.Chain()[0].Hash() {
+		t.Errorf("regtest and testnet share the genesis block")
+	}
+}
+
+// 签名包含chain id,其他网络的签名无效
+func TestSignatureChainId(t *testing.T) {
+	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	tx := signTransaction(t, privateKey, NewTransaction("alice", "bob", 1), params.RegTest.ChainId)
+	if !bc.VerifyTransactionSignature(tx.senderPublicKey, tx.signature, tx) {
+		t.Fatalf("signature for this network rejected")
+	}
+	replayed := signTransaction(t, privateKey, NewTransaction("alice", "bob", 1), params.MainNet.ChainId)
+	if bc.VerifyTransactionSignature(replayed.senderPublicKey, replayed.signature, replayed) {
+		t.Fatalf("signature for another network accepted")
+	}
+	tx.value = 2
+	if bc.VerifyTransactionSignature(tx.senderPublicKey, tx.signature, tx) {
+		t.Fatalf("signature valid after changing the value")
+	}
+}
+
+// secp256k1签名的交易经过JSON编码后仍然有效
+func TestSignatureSecp256k1(t *testing.T) {
+	privateKey, _ := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	tx := signTransaction(t, privateKey, NewTransaction("alice", "bob", 1), params.RegTest.ChainId)
+	m, _ := json.Marshal(tx)
+	var decoded Transaction
+	if err := json.Unmarshal(m, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.senderPublicKey == nil || decoded.senderPublicKey.Curve.Params().Name != secp256k1.S256().Params().Name {
+		t.Fatalf("decoded public key %+v", decoded.senderPublicKey)
+	}
+	if !bc.VerifyTransactionSignature(decoded.senderPublicKey, decoded.signature, &decoded) {
+		t.Fatalf("secp256k1 signature rejected")
+	}
+}
+
+// 交易的地址必须属于当前网络,公钥必须与发送方地址一致
+func TestAcceptTransaction(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice, bob := newTestKey(t), newTestKey(t)
+	bc.Generate(1, alice.address)
+	mainnet := address.FromPublicKey(&bob.privateKey.PublicKey, params.MainNet.AddressVersion).String()
+	tests := []struct {
+		name string
+		key  *testKey
+		tx   *Transaction
+	}{
+		{"invalid recipient", alice, NewTransaction(alice.address, "bob", 1)},
+		{"mainnet recipient", alice, NewTransaction(alice.address, mainnet, 1)},
+		{"invalid sender", alice, NewTransaction("alice", bob.address, 1)},
+		{"zero value", alice, NewTransaction(alice.address, bob.address, 0)},
+		{"key of another address", bob, NewTransaction(alice.address, bob.address, 1)},
+		{"not enough balance", bob, NewTransaction(bob.address, alice.address, 1)},
+		//挖矿奖励不能作为交易提交
+		{"coinbase sender", alice, NewTransaction(MINING_SENDER, bob.address, MINING_REWARD)},
+	}
+	for _, tt := range tests {
+		tx := signTransaction(t, tt.key.privateKey, tt.tx, params.RegTest.ChainId)
+		if err := bc.AcceptTransaction(tx.senderBlockchainAddress, tx.recipientBlockchainAddress, tx.value, tx.nonce, 0, nil,
+			tx.senderPublicKey, tx.signature); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+	tx := signTransaction(t, alice.privateKey, NewTransaction(alice.address, bob.address, 1), params.RegTest.ChainId)
+	if err := bc.AcceptTransaction(alice.address, bob.address, 1, 0, 0, nil, tx.senderPublicKey, tx.signature); err != nil {
+		t.Fatal(err)
+	}
+	if len(bc.TransactionPool()) != 1 {
+		t.Errorf("pool has %d transactions", len(bc.TransactionPool()))
+	}
+}
+
+// 区块只能有一笔金额正确的挖矿奖励,发送方的nonce和余额按区块内的交易顺序检查
+func TestValidBlockRules(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice, bob := newTestKey(t), newTestKey(t)
+	genesis := bc.Chain()[0]
+	coinbase := func(value float32) *Transaction {
+		tx := newCoinbase(testMiner, 2)
+		tx.value = value
+		return tx
+	}
+	transfer := func(value float32, nonce uint64) *Transaction {
+		tx := NewTransaction(alice.address, bob.address, value)
+		tx.nonce = nonce
+		return signTransaction(t, alice.privateKey, tx, params.RegTest.ChainId)
+	}
+	replayed := transfer(0.1, 0)
+	coinbaseHeight := coinbase(MINING_REWARD)
+	coinbaseHeight.nonce = 1
+	funded := []*Block{genesis, solveBlock(bc, []*Block{genesis}, []*Transaction{newCoinbase(alice.address, 1)})}
+	tests := []struct {
+		name         string
+		transactions []*Transaction
+		ok           bool
+	}{
+		{"valid", []*Transaction{transfer(0.5, 0), coinbase(MINING_REWARD)}, true},
+		{"consecutive nonces", []*Transaction{transfer(0.1, 0), transfer(0.1, 1), coinbase(MINING_REWARD)}, true},
+		{"no coinbase", []*Transaction{transfer(0.5, 0)}, false},
+		{"two coinbases", []*Transaction{coinbase(MINING_REWARD), coinbase(MINING_REWARD)}, false},
+		{"wrong reward", []*Transaction{coinbase(MINING_REWARD * 2)}, false},
+		//挖矿奖励的nonce必须是区块高度
+		{"coinbase height", []*Transaction{coinbaseHeight}, false},
+		{"overdraft", []*Transaction{transfer(1.5, 0), coinbase(MINING_REWARD)}, false},
+		{"spent twice", []*Transaction{transfer(0.6, 0), transfer(0.7, 1), coinbase(MINING_REWARD)}, false},
+		//同一笔交易不能重放,nonce不能跳过
+		{"replayed", []*Transaction{replayed, replayed, coinbase(MINING_REWARD)}, false},
+		{"skipped nonce", []*Transaction{transfer(0.1, 1), coinbase(MINING_REWARD)}, false},
+	}
+	for _, tt := range tests {
+		chain := append(append([]*Block{}, funded...), solveBlock(bc, funded, tt.transactions))
+		if err := bc.indexChain(chain); (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+
+	//交易池按交易池中的交易之后的nonce和余额检查
+	bc.Generate(1, alice.address)
+	accept := func(tx *Transaction) error {
+		return bc.AcceptTransaction(alice.address, bob.address, tx.value, tx.nonce, 0, nil, tx.senderPublicKey, tx.signature)
+	}
+	mined := transfer(0.6, 0)
+	if err := accept(mined); err != nil {
+		t.Fatal(err)
+	}
+	if n := bc.NextNonce(alice.address); n != 1 {
+		t.Errorf("next nonce %d, want 1", n)
+	}
+	if err := accept(transfer(0.1, 0)); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("reused nonce: %v", err)
+	}
+	if err := accept(transfer(0.7, 1)); err == nil || !strings.Contains(err.Error(), "not enough balance") {
+		t.Errorf("pool overdraft: %v", err)
+	}
+	//打包后同一笔交易不能再次提交
+	bc.Generate(1, testMiner)
+	if err := accept(mined); err == nil {
+		t.Error("mined transaction replayed")
+	}
+}
+
+// 邻居的链必须从同一个创世区块开始并且前后相连
+func TestIndexChainLinkage(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	genesis := bc.Chain()[0]
+	coinbase := func(height int) []*Transaction { return []*Transaction{newCoinbase(testMiner, height)} }
+	b1 := solveBlock(bc, []*Block{genesis}, coinbase(1))
+	b2 := solveBlock(bc, []*Block{genesis, b1}, coinbase(2))
+	//在创世区块之后挖出,却放在b1之后
+	unlinked := solveBlock(bc, []*Block{genesis}, coinbase(2))
+	other := newGenesisBlock(params.TestNet)
+	tests := []struct {
+		name  string
+		chain []*Block
+		ok    bool
+	}{
+		{"empty", nil, false},
+		{"unknown genesis", []*Block{other}, false},
+		{"genesis not first", []*Block{b1, b2}, false},
+		{"unlinked", []*Block{genesis, b1, unlinked}, false},
+		{"valid", []*Block{genesis, b1, b2}, true},
+	}
+	for _, tt := range tests {
+		if err := bc.indexChain(tt.chain); (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+	if bc.index.LookupNode(other.Hash()) != nil {
+		t.Error("unknown genesis added to the index")
+	}
+	if best := bc.index.BestTip(); best.Hash() != b2.Hash() {
+		t.Errorf("best tip %x, want %x", best.Hash(), b2.Hash())
+	}
+}
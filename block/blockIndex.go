@@ -0,0 +1,239 @@
+package block
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// 区块验证状态
+type BlockStatus int
+
+const (
+	StatusHeaderValid BlockStatus = iota //只确认了与父区块相连
+	StatusValid                          //工作量证明验证通过
+	StatusInvalid                        //验证失败,该分支不能被选为主链
+)
+
+func (s BlockStatus) String() string {
+	switch s {
+	case StatusHeaderValid:
+		return "header_valid"
+	case StatusValid:
+		return "valid"
+	case StatusInvalid:
+		return "invalid"
+	}
+	return "unknown"
+}
+
+// 区块树中的一个节点
+type BlockNode struct {
+	hash      [32]byte
+	parent    *BlockNode
+	height    int
+	chainWork *big.Int //从创世区块到当前区块的累计工作量
+	status    BlockStatus
+	block     *Block
+}
+
+func (n *BlockNode) Hash() [32]byte {
+	return n.hash
+}
+
+func (n *BlockNode) Parent() *BlockNode {
+	return n.parent
+}
+
+func (n *BlockNode) Height() int {
+	return n.height
+}
+
+func (n *BlockNode) ChainWork() *big.Int {
+	return new(big.Int).Set(n.chainWork)
+}
+
+func (n *BlockNode) Status() BlockStatus {
+	return n.status
+}
+
+func (n *BlockNode) Block() *Block {
+	return n.block
+}
+
+// 当前节点或其祖先被标记为无效时返回true
+func (n *BlockNode) invalid() bool {
+	for p := n; p != nil; p = p.parent {
+		if p.status == StatusInvalid {
+			return true
+		}
+	}
+	return false
+}
+
+// 沿着父指针返回height高度的祖先
+func (n *BlockNode) Ancestor(height int) *BlockNode {
+	if height < 0 || height > n.height {
+		return nil
+	}
+	p := n
+	for p != nil && p.height > height {
+		p = p.parent
+	}
+	return p
+}
+
+// 单个区块的工作量: 难度为d时平均需要16^d次哈希
+func BlockWork(difficulty int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(16), big.NewInt(int64(difficulty)), nil)
+}
+
+// 内存中的区块树,记录所有已知的分支
+type BlockIndex struct {
+	nodes map[[32]byte]*BlockNode
+	tips  map[[32]byte]*BlockNode //没有子区块的叶子节点
+	mux   sync.RWMutex
+}
+
+func NewBlockIndex() *BlockIndex {
+	return &BlockIndex{
+		nodes: make(map[[32]byte]*BlockNode),
+		tips:  make(map[[32]byte]*BlockNode),
+	}
+}
+
+// 将区块加入索引,父区块未知时只有创世区块(height 0)可以作为根节点
+func (bi *BlockIndex) AddBlock(b *Block, difficulty int, status BlockStatus, genesis bool) (*BlockNode, error) {
+	bi.mux.Lock()
+	defer bi.mux.Unlock()
+	hash := b.Hash()
+	if n, ok := bi.nodes[hash]; ok {
+		return n, nil
+	}
+	parent, ok := bi.nodes[b.previousHash]
+	if !ok && !genesis {
+		return nil, fmt.Errorf("orphan block %x: unknown parent %x", hash, b.previousHash)
+	}
+	n := &BlockNode{
+		hash:      hash,
+		status:    status,
+		block:     b,
+		chainWork: BlockWork(difficulty),
+	}
+	if parent != nil {
+		n.parent = parent
+		n.height = parent.height + 1
+		n.chainWork.Add(n.chainWork, parent.chainWork)
+		delete(bi.tips, parent.hash)
+	}
+	bi.nodes[hash] = n
+	bi.tips[hash] = n
+	return n, nil
+}
+
+func (bi *BlockIndex) LookupNode(hash [32]byte) *BlockNode {
+	bi.mux.RLock()
+	defer bi.mux.RUnlock()
+	return bi.nodes[hash]
+}
+
+// 修改区块状态
+func (bi *BlockIndex) SetStatus(hash [32]byte, status BlockStatus) {
+	bi.mux.Lock()
+	defer bi.mux.Unlock()
+	if n, ok := bi.nodes[hash]; ok {
+		n.status = status
+	}
+}
+
+func (bi *BlockIndex) Tips() []*BlockNode {
+	bi.mux.RLock()
+	defer bi.mux.RUnlock()
+	tips := make([]*BlockNode, 0, len(bi.tips))
+	for _, n := range bi.tips {
+		tips = append(tips, n)
+	}
+	return tips
+}
+
+// 返回累计工作量最大且有效的分支末端
+func (bi *BlockIndex) BestTip() *BlockNode {
+	var best *BlockNode
+	for _, n := range bi.Tips() {
+		//分支上有无效区块时,回退到最后一个有效的祖先
+		for n != nil && n.invalid() {
+			n = n.parent
+		}
+		if n == nil {
+			continue
+		}
+		if best == nil || n.chainWork.Cmp(best.chainWork) > 0 {
+			best = n
+		}
+	}
+	return best
+}
+
+// 从创世区块到node的完整区块列表
+func (bi *BlockIndex) Chain(node *BlockNode) []*Block {
+	chain := make([]*Block, node.height+1)
+	for n := node; n != nil; n = n.parent {
+		chain[n.height] = n.block
+	}
+	return chain
+}
+
+// 两个分支的最近公共祖先,不同创世区块时返回nil
+func FindFork(a *BlockNode, b *BlockNode) *BlockNode {
+	for a != nil && b != nil && a.height > b.height {
+		a = a.parent
+	}
+	for a != nil && b != nil && b.height > a.height {
+		b = b.parent
+	}
+	for a != nil && b != nil && a != b {
+		a = a.parent
+		b = b.parent
+	}
+	if a == nil || b == nil {
+		return nil
+	}
+	return a
+}
+
+// 分支信息,用于/forks接口
+type ForkInfo struct {
+	Hash         string `json:"hash"`
+	Height       int    `json:"height"`
+	ChainWork    string `json:"chain_work"`
+	Status       string `json:"status"`
+	Active       bool   `json:"active"`
+	ForkHeight   int    `json:"fork_height"`
+	BranchLength int    `json:"branch_length"`
+}
+
+// 列出所有分支相对于当前主链的位置
+func (bi *BlockIndex) Forks(activeTip *BlockNode) []*ForkInfo {
+	forks := make([]*ForkInfo, 0)
+	for _, tip := range bi.Tips() {
+		fi := &ForkInfo{
+			Hash:      fmt.Sprintf("%x", tip.hash),
+			Height:    tip.height,
+			ChainWork: tip.chainWork.String(),
+			Status:    tip.status.String(),
+			Active:    tip == activeTip,
+		}
+		if tip.invalid() {
+			fi.Status = StatusInvalid.String()
+		}
+		fi.ForkHeight = -1
+		if fork := FindFork(tip, activeTip); fork != nil {
+			fi.ForkHeight = fork.height
+			fi.BranchLength = tip.height - fork.height
+		} else {
+			fi.BranchLength = tip.height + 1
+		}
+		forks = append(forks, fi)
+	}
+	return forks
+}
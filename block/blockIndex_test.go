@@ -0,0 +1,119 @@
+package block
+
+import (
+	"math/big"
+	"testing"
+)
+
+// 以parent为父区块的测试区块,nonce用于区分同一父区块下的分支
+func childBlock(parent *Block, nonce int) *Block {
+	return newBlock(0, nonce, parent.Hash(), [32]byte{}, []*Transaction{})
+}
+
+// 在parent之后连续加入n个区块
+func extend(t *testing.T, bi *BlockIndex, parent *Block, n int, nonce int) []*Block {
+	t.Helper()
+	blocks := make([]*Block, n)
+	for i := range blocks {
+		blocks[i] = childBlock(parent, nonce)
+		if _, err := bi.AddBlock(blocks[i], 1, StatusValid, false); err != nil {
+			t.Fatal(err)
+		}
+		parent = blocks[i]
+	}
+	return blocks
+}
+
+func testIndex(t *testing.T) (*BlockIndex, *Block) {
+	genesis := newBlock(0, 0, [32]byte{}, [32]byte{}, []*Transaction{})
+	bi := NewBlockIndex()
+	if _, err := bi.AddBlock(genesis, 1, StatusValid, true); err != nil {
+		t.Fatal(err)
+	}
+	return bi, genesis
+}
+
+func TestAddBlock(t *testing.T) {
+	bi, genesis := testIndex(t)
+	main := extend(t, bi, genesis, 3, 0)
+	n := bi.LookupNode(main[2].Hash())
+	if n == nil || n.Height() != 3 || n.Parent().Hash() != main[1].Hash() {
+		t.Fatalf("node %+v", n)
+	}
+	if work := n.ChainWork(); work.Cmp(big.NewInt(4*16)) != 0 {
+		t.Errorf("chain work %v, want %d", work, 4*16)
+	}
+	if a := n.Ancestor(1); a == nil || a.Hash() != main[0].Hash() {
+		t.Errorf("ancestor at height 1 %+v", a)
+	}
+	if n.Ancestor(4) != nil || n.Ancestor(-1) != nil {
+		t.Errorf("ancestor outside the branch")
+	}
+	//重复加入返回已有节点
+	if again, err := bi.AddBlock(main[2], 1, StatusValid, false); err != nil || again != n {
+		t.Errorf("re-adding returned %+v, %v", again, err)
+	}
+	//父区块未知的区块不能加入
+	orphan := newBlock(0, 0, [32]byte{1}, [32]byte{}, []*Transaction{})
+	if _, err := bi.AddBlock(orphan, 1, StatusValid, false); err == nil {
+		t.Errorf("orphan block accepted")
+	}
+	if len(bi.Tips()) != 1 {
+		t.Errorf("%d tips, want 1", len(bi.Tips()))
+	}
+}
+
+func TestBestTip(t *testing.T) {
+	bi, genesis := testIndex(t)
+	main := extend(t, bi, genesis, 2, 0)
+	side := extend(t, bi, main[0], 2, 1)
+	if best := bi.BestTip(); best.Hash() != side[1].Hash() {
+		t.Fatalf("best tip at height %d, want the longer side branch", best.Height())
+	}
+	//分支上有无效区块时回退到其有效的祖先
+	bi.SetStatus(side[1].Hash(), StatusInvalid)
+	if best := bi.BestTip(); best.Hash() != main[1].Hash() && best.Hash() != side[0].Hash() {
+		t.Fatalf("best tip %x is not a valid tip with the most work", best.Hash())
+	}
+	bi.SetStatus(side[0].Hash(), StatusInvalid)
+	if best := bi.BestTip(); best.Hash() != main[1].Hash() {
+		t.Fatalf("best tip at height %d, want the main branch", best.Height())
+	}
+	chain := bi.Chain(bi.BestTip())
+	if len(chain) != 3 || chain[0] != genesis || chain[2] != main[1] {
+		t.Errorf("chain of best tip has %d blocks", len(chain))
+	}
+}
+
+func TestForks(t *testing.T) {
+	bi, genesis := testIndex(t)
+	main := extend(t, bi, genesis, 3, 0)
+	side := extend(t, bi, main[0], 1, 1)
+	active := bi.LookupNode(main[2].Hash())
+	if fork := FindFork(active, bi.LookupNode(side[0].Hash())); fork == nil || fork.Hash() != main[0].Hash() {
+		t.Fatalf("fork point %+v, want height 1", fork)
+	}
+	forks := bi.Forks(active)
+	if len(forks) != 2 {
+		t.Fatalf("%d forks, want 2", len(forks))
+	}
+	for _, f := range forks {
+		switch {
+		case f.Active:
+			if f.Height != 3 || f.ForkHeight != 3 || f.BranchLength != 0 {
+				t.Errorf("active branch %+v", f)
+			}
+		default:
+			if f.Height != 2 || f.ForkHeight != 1 || f.BranchLength != 1 || f.Status != "valid" {
+				t.Errorf("side branch %+v", f)
+			}
+		}
+	}
+	//另一个创世区块的分支没有分叉点
+	other := NewBlockIndex()
+	otherGenesis := newBlock(0, 1, [32]byte{}, [32]byte{}, []*Transaction{})
+	n, _ := other.AddBlock(otherGenesis, 1, StatusValid, true)
+	if FindFork(active, n) != nil {
+		t.Errorf("fork point between different genesis blocks")
+	}
+}
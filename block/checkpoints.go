@@ -0,0 +1,115 @@
+package block
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// 链配置: 检查点和assume-valid区块
+type ChainSpec struct {
+	Checkpoints map[int]string `json:"checkpoints"`  //区块高度 -> 区块哈希(hex)
+	AssumeValid string         `json:"assume_valid"` //该区块及其祖先跳过签名验证
+}
+
+// 从JSON文件读取链配置
+func LoadChainSpec(path string) (*ChainSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec ChainSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+func decodeHash(s string) ([32]byte, error) {
+	var hash [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return hash, err
+	}
+	if len(b) != 32 {
+		return hash, fmt.Errorf("invalid hash length %d", len(b))
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+// 应用链配置,spec中的检查点与网络参数中硬编码的检查点合并
+// spec为nil时只使用硬编码的检查点
+func (bc *BlockChain) ApplyChainSpec(spec *ChainSpec) error {
+	checkpoints := make(map[int][32]byte)
+	merged := make(map[int]string)
+	for h, s := range bc.params.Checkpoints {
+		merged[h] = s
+	}
+	if spec != nil {
+		for h, s := range spec.Checkpoints {
+			merged[h] = s
+		}
+	}
+	for h, s := range merged {
+		hash, err := decodeHash(s)
+		if err != nil {
+			return fmt.Errorf("checkpoint %d: %v", h, err)
+		}
+		checkpoints[h] = hash
+	}
+	bc.checkpoints = checkpoints
+	bc.assumeValid = nil
+	if spec != nil && spec.AssumeValid != "" {
+		hash, err := decodeHash(spec.AssumeValid)
+		if err != nil {
+			return fmt.Errorf("assume_valid: %v", err)
+		}
+		bc.assumeValid = &hash
+	}
+	return nil
+}
+
+// 最后一个检查点的高度,没有检查点时返回-1
+func (bc *BlockChain) lastCheckpointHeight() int {
+	last := -1
+	for h := range bc.checkpoints {
+		if h > last {
+			last = h
+		}
+	}
+	return last
+}
+
+// 检查区块是否与检查点冲突
+func (bc *BlockChain) checkCheckpoint(height int, hash [32]byte) error {
+	if cp, ok := bc.checkpoints[height]; ok {
+		if cp != hash {
+			return fmt.Errorf("block %x at height %d conflicts with checkpoint %x", hash, height, cp)
+		}
+		return nil
+	}
+	//已经拥有最后一个检查点时,拒绝在检查点之前分叉的新区块,检查点的祖先除外
+	last := bc.lastCheckpointHeight()
+	if height > last || bc.index == nil {
+		return nil
+	}
+	if cp := bc.index.LookupNode(bc.checkpoints[last]); cp != nil && cp.Ancestor(height).Hash() != hash {
+		return fmt.Errorf("block %x at height %d forks below checkpoint %d", hash, height, last)
+	}
+	return nil
+}
+
+// assume-valid区块在chain中的高度,不在该链上时返回0(只跳过创世区块)
+func (bc *BlockChain) assumedValidHeight(chain []*Block) int {
+	if bc.assumeValid == nil {
+		return 0
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].Hash() == *bc.assumeValid {
+			return i
+		}
+	}
+	return 0
+}
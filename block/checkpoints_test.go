@@ -0,0 +1,118 @@
+package block
+
+import (
+	"GoProject/params"
+	"encoding/hex"
+	"testing"
+)
+
+// 在chain之后找到满足难度的区块
+func solveBlock(bc *BlockChain, chain []*Block, transactions []*Transaction) *Block {
+	prev := chain[len(chain)-1]
+	state := stateOf(chain)
+	state.Apply(transactions)
+	nonce := 0
+	for !bc.ValidProof(nonce, prev.Hash(), state.Root(), transactions, bc.params.Difficulty) {
+		nonce += 1
+	}
+	return newBlock(nextBlockTime(bc.Now(), chain), nonce, prev.Hash(), state.Root(), transactions)
+}
+
+// 挖出n个只有挖矿奖励的区块
+func mineBlocks(bc *BlockChain, n int) {
+	for i := 0; i < n; i++ {
+		bc.Mining()
+	}
+}
+
+func hashHex(b *Block) string {
+	hash := b.Hash()
+	return hex.EncodeToString(hash[:])
+}
+
+func TestApplyChainSpec(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	tests := []struct {
+		name string
+		spec *ChainSpec
+	}{
+		{"checkpoint hex", &ChainSpec{Checkpoints: map[int]string{1: "zz"}}},
+		{"checkpoint length", &ChainSpec{Checkpoints: map[int]string{1: "00ff"}}},
+		{"assume valid", &ChainSpec{AssumeValid: "00"}},
+	}
+	for _, tt := range tests {
+		if err := bc.ApplyChainSpec(tt.spec); err == nil {
+			t.Errorf("%s: invalid spec accepted", tt.name)
+		}
+	}
+	if err := bc.ApplyChainSpec(nil); err != nil || len(bc.checkpoints) != len(bc.params.Checkpoints) || bc.assumeValid != nil {
+		t.Errorf("nil spec: %v", err)
+	}
+}
+
+func TestCheckpointConflict(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	mineBlocks(bc, 2)
+	chain := bc.Chain()
+	if err := bc.ApplyChainSpec(&ChainSpec{Checkpoints: map[int]string{1: hashHex(chain[1])}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.checkCheckpoint(1, chain[1].Hash()); err != nil {
+		t.Errorf("checkpoint block rejected: %v", err)
+	}
+	if err := bc.checkCheckpoint(2, chain[2].Hash()); err != nil {
+		t.Errorf("block after the checkpoint rejected: %v", err)
+	}
+
+	//在检查点高度上的另一个区块与检查点冲突
+	alt := solveBlock(bc, chain[:1], []*Transaction{newCoinbase("other", 1)})
+	if err := bc.checkCheckpoint(1, alt.Hash()); err == nil {
+		t.Errorf("conflicting block accepted")
+	}
+	if err := bc.indexChain([]*Block{chain[0], alt, solveBlock(bc, []*Block{chain[0], alt}, []*Transaction{newCoinbase("other", 2)})}); err == nil {
+		t.Errorf("chain conflicting with the checkpoint is valid")
+	}
+	if n := bc.index.LookupNode(alt.Hash()); n == nil || n.Status() != StatusInvalid {
+		t.Errorf("conflicting block not marked invalid: %+v", n)
+	}
+	if best := bc.index.BestTip(); best.Hash() != chain[2].Hash() {
+		t.Errorf("best tip moved to a branch conflicting with the checkpoint")
+	}
+
+	//已有检查点区块时,拒绝在检查点之前分叉
+	if err := bc.ApplyChainSpec(&ChainSpec{Checkpoints: map[int]string{2: hashHex(chain[2])}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.checkCheckpoint(1, alt.Hash()); err == nil {
+		t.Errorf("fork below the last checkpoint accepted")
+	}
+	if err := bc.indexChain(chain); err != nil {
+		t.Errorf("chain matching the checkpoints is invalid: %v", err)
+	}
+}
+
+// assume-valid区块及其祖先跳过签名验证
+func TestAssumeValid(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	genesis := bc.Chain()[0]
+	funded := solveBlock(bc, []*Block{genesis}, []*Transaction{newCoinbase("alice", 1)})
+	unsigned := solveBlock(bc, []*Block{genesis, funded}, []*Transaction{NewTransaction("alice", "bob", 1), newCoinbase(testMiner, 2)})
+	chain := []*Block{genesis, funded, unsigned}
+	chain = append(chain, solveBlock(bc, chain, []*Transaction{newCoinbase(testMiner, 3)}))
+	//被标记为无效的区块不会重新验证,在另一个节点上检查
+	if err := NewBlockChain(testMiner, 0, params.RegTest).indexChain(chain); err == nil {
+		t.Fatalf("unsigned transaction accepted without assume-valid")
+	}
+	if err := bc.ApplyChainSpec(&ChainSpec{AssumeValid: hashHex(chain[3])}); err != nil {
+		t.Fatal(err)
+	}
+	if h := bc.assumedValidHeight(chain); h != 3 {
+		t.Errorf("assumed valid height %d, want 3", h)
+	}
+	if err := bc.indexChain(chain); err != nil {
+		t.Errorf("ancestor of the assume-valid block rejected: %v", err)
+	}
+	if h := bc.assumedValidHeight(chain[:3]); h != 0 {
+		t.Errorf("assumed valid height %d for a chain without the block", h)
+	}
+}
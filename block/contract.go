@@ -0,0 +1,288 @@
+package block
+
+import (
+	"GoProject/address"
+	"GoProject/params"
+	"GoProject/utils"
+	"GoProject/vm"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// 合约交易的gas,手续费 = 实际消耗的gas * GAS_PRICE,从发送方余额中扣除并销毁
+const (
+	GAS_PRICE  = 0.00001 //每单位gas的价格
+	DEPLOY_GAS = 5000    //部署的基础gas
+	CALL_GAS   = 1000    //调用的基础gas
+	BYTE_GAS   = 10      //字节码和参数每字节的gas
+)
+
+var ErrIntrinsicGas = errors.New("gas limit below intrinsic gas")
+
+// 合约: 字节码和键值存储
+type Contract struct {
+	code    []byte
+	storage map[string][]byte
+}
+
+func (c *Contract) copy() *Contract {
+	storage := make(map[string][]byte, len(c.storage))
+	for k, v := range c.storage {
+		storage[k] = v
+	}
+	return &Contract{code: c.code, storage: storage}
+}
+
+// 存储的稀疏默克尔树根,键为存储键的SHA-256
+func (c *Contract) storageRoot() [32]byte {
+	leaves := make(map[[32]byte][32]byte, len(c.storage))
+	for k, v := range c.storage {
+		key := sha256.Sum256([]byte(k))
+		leaves[key] = sha256.Sum256(append(append([]byte{0x00}, key[:]...), v...))
+	}
+	return smtRoot(leaves)
+}
+
+// 执行中的写入,成功后才合并到合约存储
+type storageOverlay struct {
+	base   map[string][]byte
+	writes map[string][]byte
+}
+
+func (o *storageOverlay) Get(key []byte) []byte {
+	if v, ok := o.writes[string(key)]; ok {
+		return v
+	}
+	return o.base[string(key)]
+}
+
+func (o *storageOverlay) Set(key []byte, value []byte) {
+	o.writes[string(key)] = append([]byte{}, value...)
+}
+
+func (o *storageOverlay) commit() {
+	for k, v := range o.writes {
+		if len(v) == 0 {
+			delete(o.base, k)
+		} else {
+			o.base[k] = v
+		}
+	}
+}
+
+func decodeBytes(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return hex.DecodeString(s)
+}
+
+func (t *Transaction) isContract() bool {
+	return t.gasLimit > 0
+}
+
+func (t *Transaction) isDeploy() bool {
+	return t.isContract() && len(t.code) > 0
+}
+
+// 执行前就要消耗的gas
+func (t *Transaction) intrinsicGas() uint64 {
+	if t.isDeploy() {
+		return DEPLOY_GAS + BYTE_GAS*uint64(len(t.code)+len(t.input))
+	}
+	return CALL_GAS + BYTE_GAS*uint64(len(t.input))
+}
+
+// 按gas上限计算的最高手续费
+func (t *Transaction) maxFee() float32 {
+	return float32(t.gasLimit) * GAS_PRICE
+}
+
+// 合约交易的格式: 金额为0,部署交易的接收方为合约地址;普通交易不能发往合约地址
+func (t *Transaction) checkContract(p *params.Params) error {
+	if !t.isContract() {
+		if len(t.code) > 0 || len(t.input) > 0 {
+			return fmt.Errorf("contract transaction without gas limit")
+		}
+		if address.IsContract(t.recipientBlockchainAddress, p) {
+			return fmt.Errorf("%s is a contract, call it with a gas limit", t.recipientBlockchainAddress)
+		}
+		return nil
+	}
+	if t.senderBlockchainAddress == MINING_SENDER {
+		return fmt.Errorf("coinbase cannot be a contract transaction")
+	}
+	if t.value != 0 {
+		return fmt.Errorf("contract transaction must have value 0, got %v", t.value)
+	}
+	if t.isDeploy() {
+		if a := address.FromContract(t.senderBlockchainAddress, t.code, t.input, p); a != t.recipientBlockchainAddress {
+			return fmt.Errorf("deploy recipient must be the contract address %s", a)
+		}
+		return nil
+	}
+	if !address.IsContract(t.recipientBlockchainAddress, p) {
+		return fmt.Errorf("%s is not a contract address", t.recipientBlockchainAddress)
+	}
+	return nil
+}
+
+// 执行合约交易,返回消耗的gas;失败时合约存储不变,gas照常消耗
+func (s *State) execute(t *Transaction) ([]byte, uint64, error) {
+	gas := t.intrinsicGas()
+	if gas > t.gasLimit {
+		return nil, t.gasLimit, ErrIntrinsicGas
+	}
+	if t.isDeploy() {
+		if s.contracts[t.recipientBlockchainAddress] != nil {
+			return nil, gas, fmt.Errorf("contract %s already exists", t.recipientBlockchainAddress)
+		}
+		if err := vm.Validate(t.code); err != nil {
+			return nil, gas, err
+		}
+		s.contracts[t.recipientBlockchainAddress] = &Contract{code: t.code, storage: make(map[string][]byte)}
+		return nil, gas, nil
+	}
+	ret, used, err := s.call(t.recipientBlockchainAddress, t.senderBlockchainAddress, t.input, t.gasLimit-gas, true)
+	return ret, gas + used, err
+}
+
+// 调用合约,commit为true且执行成功时保存存储的修改
+func (s *State) call(contract string, caller string, input []byte, gasLimit uint64, commit bool) ([]byte, uint64, error) {
+	c := s.contracts[contract]
+	if c == nil {
+		return nil, 0, fmt.Errorf("no contract at %s", contract)
+	}
+	args, err := vm.DecodeArgs(input)
+	if err != nil {
+		return nil, 0, err
+	}
+	storage := &storageOverlay{base: c.storage, writes: make(map[string][]byte)}
+	ctx := &vm.Context{Caller: caller, Args: args, Storage: storage}
+	ret, used, err := vm.Run(c.code, ctx, gasLimit)
+	if err == nil && commit {
+		storage.commit()
+	}
+	return ret, used, err
+}
+
+// 应用合约交易: 发送方nonce加1,按消耗的gas扣除手续费
+func (s *State) applyContract(t *Transaction) {
+	_, gas, _ := s.execute(t)
+	sender := s.accounts[t.senderBlockchainAddress]
+	sender.Balance -= float32(gas) * GAS_PRICE
+	sender.Nonce += 1
+	s.accounts[t.senderBlockchainAddress] = sender
+}
+
+// 验证合约交易并加入交易池,部署时recipient为address.FromContract(sender, code, salt)
+// code为空时调用recipient处的合约,input为vm.EncodeArgs编码的参数
+func (bc *BlockChain) AcceptContractTransaction(sender string, recipient string, nonce uint64, lockTime int64, data []byte,
+	code []byte, input []byte, gasLimit uint64, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) error {
+	if gasLimit == 0 {
+		return fmt.Errorf("missing gas limit")
+	}
+	t := NewTransaction(sender, recipient, 0)
+	t.nonce = nonce
+	t.lockTime = lockTime
+	t.data = data
+	t.code = code
+	t.input = input
+	t.gasLimit = gasLimit
+	t.senderPublicKey = senderPublicKey
+	t.signature = s
+	if g := t.intrinsicGas(); g > gasLimit {
+		return fmt.Errorf("%w: %d < %d", ErrIntrinsicGas, gasLimit, g)
+	}
+	return bc.acceptTransaction(t)
+}
+
+// 在应用交易池之后的状态pending上试运行合约交易,执行失败的交易不进入交易池
+func tryContract(pending *State, t *Transaction) error {
+	if _, _, err := pending.execute(t); err != nil {
+		return fmt.Errorf("contract transaction from %s: %v", t.senderBlockchainAddress, err)
+	}
+	return nil
+}
+
+// 应用交易池中的交易之后的状态
+func (bc *BlockChain) pendingState() *State {
+	state := bc.state.Copy()
+	state.Apply(bc.transactionPool)
+	return state
+}
+
+// 交易池中合约交易的gas上限之和
+func (bc *BlockChain) poolGas() uint64 {
+	var gas uint64
+	for _, t := range bc.transactionPool {
+		gas += t.gasLimit
+	}
+	return gas
+}
+
+// 合约的字节码和存储
+type ContractInfo struct {
+	Address     string            `json:"contract_address"`
+	Code        string            `json:"code"`
+	Asm         string            `json:"asm"`
+	Storage     map[string]string `json:"storage"` //十六进制的键 -> 十六进制的值
+	StorageRoot string            `json:"storage_root"`
+}
+
+// 主链末端的合约,不存在时返回nil
+func (bc *BlockChain) Contract(contract string) *ContractInfo {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	c := bc.state.contracts[contract]
+	if c == nil {
+		return nil
+	}
+	asm, _ := vm.Disassemble(c.code)
+	storage := make(map[string]string, len(c.storage))
+	for k, v := range c.storage {
+		storage[hex.EncodeToString([]byte(k))] = hex.EncodeToString(v)
+	}
+	root := c.storageRoot()
+	return &ContractInfo{
+		Address:     contract,
+		Code:        hex.EncodeToString(c.code),
+		Asm:         asm,
+		Storage:     storage,
+		StorageRoot: hex.EncodeToString(root[:]),
+	}
+}
+
+// 只读调用的结果
+type CallResult struct {
+	Return  string `json:"return"` //十六进制
+	GasUsed uint64 `json:"gas_used"`
+	Error   string `json:"error,omitempty"`
+}
+
+// 在主链末端的状态上执行合约但不保存修改,用于读取合约数据
+// gasLimit不能超过区块的gas上限;在状态的副本上执行,不持有bc.mux
+func (bc *BlockChain) CallContract(contract string, caller string, args [][]byte, gasLimit uint64) (*CallResult, error) {
+	if gasLimit == 0 || gasLimit > bc.params.BlockGasLimit {
+		return nil, fmt.Errorf("gas must be between 1 and %d", bc.params.BlockGasLimit)
+	}
+	input, err := vm.EncodeArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	bc.mux.Lock()
+	state := bc.state.Copy()
+	bc.mux.Unlock()
+	if state.contracts[contract] == nil {
+		return nil, fmt.Errorf("no contract at %s", contract)
+	}
+	ret, gas, err := state.call(contract, caller, input, gasLimit, false)
+	r := &CallResult{Return: hex.EncodeToString(ret), GasUsed: gas}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r, nil
+}
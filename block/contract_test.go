@@ -0,0 +1,192 @@
+package block
+
+import (
+	"GoProject/address"
+	"GoProject/params"
+	"GoProject/vm"
+	"strings"
+	"testing"
+)
+
+// 每次调用计数加1并返回新的计数
+const counterAsm = `"n" SLOAD 1 ADD DUP "n" SWAP SSTORE RETURN`
+
+// 使用发送方下一个nonce签名合约交易
+func contractTransaction(t *testing.T, bc *BlockChain, k *testKey, recipient string, code []byte, input []byte, gasLimit uint64) *Transaction {
+	tx := NewTransaction(k.address, recipient, 0)
+	tx.nonce = bc.NextNonce(k.address)
+	tx.code = code
+	tx.input = input
+	tx.gasLimit = gasLimit
+	return signTransaction(t, k.privateKey, tx, params.RegTest.ChainId)
+}
+
+func acceptContract(bc *BlockChain, tx *Transaction) error {
+	return bc.AcceptContractTransaction(tx.senderBlockchainAddress, tx.recipientBlockchainAddress, tx.nonce, tx.lockTime, tx.data,
+		tx.code, tx.input, tx.gasLimit, tx.senderPublicKey, tx.signature)
+}
+
+func TestDeployAndCall(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice := newTestKey(t)
+	bc.Generate(1, alice.address)
+	code, _ := vm.Assemble(counterAsm)
+	contract := address.FromContract(alice.address, code, nil, params.RegTest)
+	if !address.IsContract(contract, params.RegTest) {
+		t.Fatalf("%s is not a contract address", contract)
+	}
+
+	deploy := contractTransaction(t, bc, alice, contract, code, nil, 10000)
+	if err := acceptContract(bc, deploy); err != nil {
+		t.Fatal(err)
+	}
+	bc.Generate(1, "")
+	if bc.Contract(contract) == nil {
+		t.Fatal("contract not deployed")
+	}
+	//同一地址不能再次部署
+	bc.Generate(1, alice.address)
+	if err := acceptContract(bc, contractTransaction(t, bc, alice, contract, code, nil, 10000)); err == nil {
+		t.Error("contract deployed twice")
+	}
+
+	before := bc.CalculateTotalAmount(alice.address)
+	call := contractTransaction(t, bc, alice, contract, nil, nil, 2000)
+	if err := acceptContract(bc, call); err != nil {
+		t.Fatal(err)
+	}
+	bc.Generate(1, "")
+	r, err := bc.CallContract(contract, alice.address, nil, 1000)
+	if err != nil || r.Error != "" || r.Return != "0000000000000002" {
+		t.Fatalf("call result %+v, err %v", r, err)
+	}
+	//只读调用不修改存储
+	if info := bc.Contract(contract); info.Storage["6e"] != "0000000000000001" {
+		t.Errorf("storage %v", info.Storage)
+	}
+	//按实际消耗的gas收取手续费
+	state := NewState()
+	state.contracts[contract] = &Contract{code: code, storage: map[string][]byte{}}
+	_, gas, _ := state.execute(call)
+	fee := before - bc.CalculateTotalAmount(alice.address)
+	if want := float32(gas) * GAS_PRICE; fee < want*0.99 || fee > want*1.01 {
+		t.Errorf("fee %v, want %v for %d gas", fee, want, gas)
+	}
+	if gas >= call.gasLimit {
+		t.Errorf("charged the whole gas limit %d", gas)
+	}
+}
+
+// gas耗尽时存储不变,手续费按gas上限收取
+func TestContractOutOfGas(t *testing.T) {
+	code, _ := vm.Assemble(counterAsm)
+	state := NewState()
+	state.accounts["alice"] = Account{Balance: 1}
+	state.contracts["contract"] = &Contract{code: code, storage: map[string][]byte{}}
+	tx := NewTransaction("alice", "contract", 0)
+	tx.gasLimit = CALL_GAS + 10
+	if _, gas, err := state.execute(tx); err != vm.ErrOutOfGas || gas != tx.gasLimit {
+		t.Fatalf("gas %d, err %v", gas, err)
+	}
+	state.applyContract(tx)
+	if len(state.contracts["contract"].storage) != 0 {
+		t.Error("storage changed by a failed call")
+	}
+	if acc := state.Account("alice"); acc.Nonce != 1 || acc.Balance != 1-float32(CALL_GAS+10)*GAS_PRICE {
+		t.Errorf("account %+v", acc)
+	}
+	tx.gasLimit = CALL_GAS - 1
+	if _, gas, err := state.execute(tx); err != ErrIntrinsicGas || gas != tx.gasLimit {
+		t.Errorf("below intrinsic gas: gas %d, err %v", gas, err)
+	}
+}
+
+func TestContractTransactionRejected(t *testing.T) {
+	alice := newTestKey(t)
+	code, _ := vm.Assemble(counterAsm)
+	contract := address.FromContract(alice.address, code, nil, params.RegTest)
+	revert, _ := vm.Assemble(`"no" REVERT`)
+	tests := []struct {
+		name   string
+		blocks int //发送方得到的挖矿奖励
+		tx     func(bc *BlockChain) *Transaction
+		want   string
+	}{
+		{"wrong deploy address", 1, func(bc *BlockChain) *Transaction {
+			return contractTransaction(t, bc, alice, newTestKey(t).address, code, nil, 10000)
+		}, "contract address"},
+		{"below intrinsic gas", 1, func(bc *BlockChain) *Transaction {
+			return contractTransaction(t, bc, alice, contract, code, nil, DEPLOY_GAS)
+		}, "intrinsic gas"},
+		{"above block gas limit", 11, func(bc *BlockChain) *Transaction {
+			return contractTransaction(t, bc, alice, contract, code, nil, params.RegTest.BlockGasLimit+1)
+		}, "gas limit"},
+		{"fee above balance", 1, func(bc *BlockChain) *Transaction {
+			return contractTransaction(t, bc, alice, contract, code, nil, uint64(2*MINING_REWARD/GAS_PRICE))
+		}, "not enough balance"},
+		{"call without contract", 1, func(bc *BlockChain) *Transaction {
+			return contractTransaction(t, bc, alice, contract, nil, nil, 2000)
+		}, "no contract"},
+		{"reverted", 1, func(bc *BlockChain) *Transaction {
+			return contractTransaction(t, bc, alice, address.FromContract(alice.address, revert, nil, params.RegTest), revert, nil, 10000)
+		}, ""},
+	}
+	for _, tt := range tests {
+		bc := NewBlockChain(testMiner, 0, params.RegTest)
+		bc.Generate(tt.blocks, alice.address)
+		err := acceptContract(bc, tt.tx(bc))
+		if tt.want == "" {
+			//部署不执行代码
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	//普通转账不能发往合约地址,合约交易的金额必须为0
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	bc.Generate(1, alice.address)
+	tx := signTransaction(t, alice.privateKey, NewTransaction(alice.address, contract, 0.1), params.RegTest.ChainId)
+	if err := bc.AcceptTransaction(alice.address, contract, 0.1, 0, 0, nil, tx.senderPublicKey, tx.signature); err == nil {
+		t.Error("transfer to a contract accepted")
+	}
+	tx = contractTransaction(t, bc, alice, contract, code, nil, 10000)
+	tx.value = 0.1
+	if err := tx.checkContract(params.RegTest); err == nil {
+		t.Error("contract transaction with value accepted")
+	}
+	if _, err := bc.CallContract(contract, alice.address, nil, params.RegTest.BlockGasLimit); err == nil {
+		t.Error("call to a missing contract")
+	}
+}
+
+// 只读调用的gas不能超过区块gas上限,且不修改合约状态
+func TestCallContract(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice := newTestKey(t)
+	bc.Generate(1, alice.address)
+	code, _ := vm.Assemble(counterAsm)
+	contract := address.FromContract(alice.address, code, nil, params.RegTest)
+	if err := acceptContract(bc, contractTransaction(t, bc, alice, contract, code, nil, 10000)); err != nil {
+		t.Fatal(err)
+	}
+	bc.Generate(1, "")
+	for _, gas := range []uint64{0, params.RegTest.BlockGasLimit + 1} {
+		if _, err := bc.CallContract(contract, alice.address, nil, gas); err == nil {
+			t.Errorf("call with gas %d accepted", gas)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		r, err := bc.CallContract(contract, alice.address, nil, params.RegTest.BlockGasLimit)
+		if err != nil || r.Return != "0000000000000001" {
+			t.Fatalf("call %d: %+v, err %v", i, r, err)
+		}
+	}
+	if _, err := bc.CallContract(newTestKey(t).address, alice.address, nil, 1000); err == nil {
+		t.Error("call without contract accepted")
+	}
+}
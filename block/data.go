@@ -0,0 +1,109 @@
+package block
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// 交易附带的数据,例如充值备注或文件哈希
+const (
+	MAX_DATA_SIZE = 80     //数据的最大字节数
+	DATA_BYTE_FEE = 0.0001 //每字节数据的手续费,从发送方余额中扣除并销毁
+)
+
+// 数据的格式: 不超过MAX_DATA_SIZE字节,挖矿奖励不能携带数据
+func (t *Transaction) checkData() error {
+	if len(t.data) == 0 {
+		return nil
+	}
+	if t.senderBlockchainAddress == MINING_SENDER {
+		return fmt.Errorf("coinbase cannot carry data")
+	}
+	if len(t.data) > MAX_DATA_SIZE {
+		return fmt.Errorf("data size %d exceeds %d bytes", len(t.data), MAX_DATA_SIZE)
+	}
+	return nil
+}
+
+// 交易附带的数据
+func (t *Transaction) Data() []byte {
+	return t.data
+}
+
+func (t *Transaction) dataFee() float32 {
+	return float32(len(t.data)) * DATA_BYTE_FEE
+}
+
+// 发送方除金额外最多需要支付的手续费
+func (t *Transaction) fee() float32 {
+	return t.maxFee() + t.dataFee()
+}
+
+// 普通转账的金额必须为正,只携带数据时可以为0;合约和代币交易由各自的检查要求金额为0
+func (t *Transaction) checkValue() error {
+	if t.isContract() || t.isToken() {
+		return nil
+	}
+	if t.value < 0 || t.value == 0 && len(t.data) == 0 {
+		return fmt.Errorf("invalid value %v", t.value)
+	}
+	return nil
+}
+
+// 携带数据的交易在主链上的位置
+type DataLocation struct {
+	Height    int     `json:"height"`
+	BlockHash string  `json:"block_hash"`
+	Index     int     `json:"index"` //在区块交易中的序号
+	Sender    string  `json:"sender_blockchain_address"`
+	Recipient string  `json:"recipient_blockchain_address"`
+	Value     float32 `json:"value"`
+}
+
+// 把区块中携带数据的交易加入数据索引,调用方需持有bc.mux
+func (bc *BlockChain) indexData(height int, b *Block) {
+	var blockHash string
+	for i, t := range b.transactions {
+		if len(t.data) == 0 {
+			continue
+		}
+		if blockHash == "" {
+			hash := b.Hash()
+			blockHash = hex.EncodeToString(hash[:])
+		}
+		key := string(t.data)
+		bc.dataIndex[key] = append(bc.dataIndex[key], &DataLocation{
+			Height:    height,
+			BlockHash: blockHash,
+			Index:     i,
+			Sender:    t.senderBlockchainAddress,
+			Recipient: t.recipientBlockchainAddress,
+			Value:     t.value,
+		})
+	}
+}
+
+// 区块从主链末端断开时撤销其数据索引,调用方需持有bc.mux
+func (bc *BlockChain) unindexData(height int, b *Block) {
+	for _, t := range b.transactions {
+		key := string(t.data)
+		locations := bc.dataIndex[key]
+		n := len(locations)
+		for n > 0 && locations[n-1].Height == height {
+			n--
+		}
+		if n == 0 {
+			delete(bc.dataIndex, key)
+		} else {
+			bc.dataIndex[key] = locations[:n]
+		}
+	}
+}
+
+// 查找携带data的主链交易,按高度排序
+func (bc *BlockChain) FindData(data []byte) []*DataLocation {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	locations := bc.dataIndex[string(data)]
+	return append([]*DataLocation{}, locations...)
+}
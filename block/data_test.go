@@ -0,0 +1,111 @@
+package block
+
+import (
+	"GoProject/params"
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDataFee(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		gasLimit uint64
+		want     float32
+	}{
+		{"no data", nil, 0, 0},
+		{"one byte", []byte{1}, 0, DATA_BYTE_FEE},
+		{"max size", make([]byte, MAX_DATA_SIZE), 0, MAX_DATA_SIZE * DATA_BYTE_FEE},
+		//合约交易另外预留gas手续费
+		{"with gas", make([]byte, 10), 1000, 10*DATA_BYTE_FEE + 1000*GAS_PRICE},
+	}
+	for _, tt := range tests {
+		tx := NewTransaction("alice", "bob", 1)
+		tx.data = tt.data
+		tx.gasLimit = tt.gasLimit
+		if got := tx.fee(); math.Abs(float64(got-tt.want)) > 1e-6 {
+			t.Errorf("%s: fee %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckData(t *testing.T) {
+	tests := []struct {
+		name   string
+		sender string
+		value  float32
+		data   []byte
+		ok     bool
+	}{
+		{"no data", "alice", 1, nil, true},
+		{"max size", "alice", 1, make([]byte, MAX_DATA_SIZE), true},
+		{"too large", "alice", 1, make([]byte, MAX_DATA_SIZE+1), false},
+		{"coinbase", MINING_SENDER, MINING_REWARD, []byte("memo"), false},
+		//只携带数据时金额可以为0
+		{"zero value with data", "alice", 0, []byte("memo"), true},
+		{"zero value without data", "alice", 0, nil, false},
+		{"negative value", "alice", -1, []byte("memo"), false},
+	}
+	for _, tt := range tests {
+		tx := NewTransaction(tt.sender, "bob", tt.value)
+		tx.data = tt.data
+		err := tx.checkData()
+		if err == nil {
+			err = tx.checkValue()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err %v", tt.name, err)
+		}
+	}
+}
+
+// 数据手续费从发送方余额中扣除,打包后可以按数据查到交易
+func TestDataTransaction(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice, bob := newTestKey(t), newTestKey(t)
+	if _, err := bc.Generate(1, alice.address); err != nil {
+		t.Fatal(err)
+	}
+	accept := func(value float32, data []byte) error {
+		tx := NewTransaction(alice.address, bob.address, value)
+		tx.data = data
+		signTransaction(t, alice.privateKey, tx, params.RegTest.ChainId)
+		return bc.AcceptTransaction(alice.address, bob.address, value, 0, 0, data, tx.senderPublicKey, tx.signature)
+	}
+	//金额加数据手续费超过余额
+	if err := accept(MINING_REWARD, []byte("memo")); err == nil || !strings.Contains(err.Error(), "not enough balance") {
+		t.Errorf("overdraft: %v", err)
+	}
+	//数据参与签名
+	tx := NewTransaction(alice.address, bob.address, 0)
+	tx.data = []byte("signed")
+	signTransaction(t, alice.privateKey, tx, params.RegTest.ChainId)
+	if err := bc.AcceptTransaction(alice.address, bob.address, 0, 0, 0, []byte("changed"), tx.senderPublicKey, tx.signature); err == nil {
+		t.Error("data changed after signing")
+	}
+
+	memo := bytes.Repeat([]byte{0xab}, 10)
+	if err := accept(0, memo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.Generate(1, bob.address); err != nil {
+		t.Fatal(err)
+	}
+	want := float32(MINING_REWARD - 10*DATA_BYTE_FEE)
+	if got := bc.CalculateTotalAmount(alice.address); math.Abs(float64(got-want)) > 1e-6 {
+		t.Errorf("alice balance %v, want %v", got, want)
+	}
+	locations := bc.FindData(memo)
+	if len(locations) != 1 {
+		t.Fatalf("found %d locations", len(locations))
+	}
+	l := locations[0]
+	if l.Height != 2 || l.Sender != alice.address || l.Recipient != bob.address || l.Value != 0 {
+		t.Errorf("location %+v", l)
+	}
+	if len(bc.FindData([]byte("missing"))) != 0 {
+		t.Error("found missing data")
+	}
+}
@@ -0,0 +1,57 @@
+package block
+
+import (
+	"GoProject/script"
+	"encoding/hex"
+)
+
+// 赎回哈希时间锁合约时公开的原像
+type RevealedPreimage struct {
+	Preimage  string `json:"preimage"`
+	Address   string `json:"htlc_address"` //被赎回的合约地址
+	Recipient string `json:"recipient_blockchain_address"`
+	Height    int    `json:"height"`    //所在区块的高度,尚在交易池中为-1
+	Confirmed bool   `json:"confirmed"` //是否已打包进区块
+}
+
+// 在链上和交易池中查找哈希为hash的原像,从最新的区块开始查找
+// 原像在交易池中就已公开,跨链交换的另一方可以立即用它赎回
+func (bc *BlockChain) FindPreimage(hash [32]byte) *RevealedPreimage {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	for _, t := range bc.transactionPool {
+		if r := t.revealedPreimage(hash); r != nil {
+			r.Height = -1
+			return r
+		}
+	}
+	for height := len(bc.chain) - 1; height > 0; height-- {
+		for _, t := range bc.chain[height].transactions {
+			if r := t.revealedPreimage(hash); r != nil {
+				r.Height = height
+				r.Confirmed = true
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Transaction) revealedPreimage(hash [32]byte) *RevealedPreimage {
+	if t.lockScript == nil {
+		return nil
+	}
+	h, err := script.ParseHTLC(t.lockScript)
+	if err != nil || h.Hash != hash {
+		return nil
+	}
+	preimage, ok := h.Preimage(t.unlockScript)
+	if !ok {
+		return nil
+	}
+	return &RevealedPreimage{
+		Preimage:  hex.EncodeToString(preimage),
+		Address:   t.senderBlockchainAddress,
+		Recipient: t.recipientBlockchainAddress,
+	}
+}
@@ -0,0 +1,25 @@
+package block
+
+import (
+	"GoProject/params"
+	"testing"
+	"time"
+)
+
+// 查找原像时持有bc.mux,不会读到正在切换的主链和交易池
+func TestFindPreimageLocks(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	generate(t, bc, 1, testMiner)
+	bc.mux.Lock()
+	done := make(chan *RevealedPreimage)
+	go func() { done <- bc.FindPreimage([32]byte{1}) }()
+	select {
+	case <-done:
+		t.Fatal("FindPreimage returned while the chain was locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	bc.mux.Unlock()
+	if r := <-done; r != nil {
+		t.Errorf("found %+v", r)
+	}
+}
@@ -0,0 +1,69 @@
+package block
+
+import (
+	"GoProject/script"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	//计算中位时间使用的区块数
+	MEDIAN_TIME_SPAN = 11
+	//小于该值的锁定时间表示区块高度,否则表示unix时间(秒),与脚本的时间锁一致
+	LOCKTIME_THRESHOLD = script.LOCKTIME_THRESHOLD
+	//区块时间戳最多比本节点时间超前多少秒
+	MAX_FUTURE_BLOCK_SEC = 2 * 60 * 60
+)
+
+// 链上最后MEDIAN_TIME_SPAN个区块时间戳的中位数(unix秒)
+// 使用中位数而不是最新区块的时间,矿工无法通过调整时间戳提前解锁交易
+func medianTimePast(chain []*Block) int64 {
+	n := len(chain)
+	if n > MEDIAN_TIME_SPAN {
+		n = MEDIAN_TIME_SPAN
+	}
+	if n == 0 {
+		return 0
+	}
+	timestamps := make([]int64, n)
+	for i, b := range chain[len(chain)-n:] {
+		timestamps[i] = b.timestamp / int64(time.Second)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[n/2]
+}
+
+// chain之后下一个区块的时间戳: 当前时间,但必须晚于中位时间
+func nextBlockTime(now time.Time, chain []*Block) int64 {
+	min := (medianTimePast(chain) + 1) * int64(time.Second)
+	if t := now.UnixNano(); t > min {
+		return t
+	}
+	return min
+}
+
+// 当前链的中位时间,下一个区块中的交易以此判断是否解锁
+func (bc *BlockChain) MedianTimePast() int64 {
+	return medianTimePast(bc.chain)
+}
+
+// 交易能否打包进高度为height、中位时间为mtp的区块
+// 0表示不锁定;高度锁在该高度及以后可以打包,时间锁在中位时间达到后可以打包
+func (t *Transaction) checkLockTime(height int64, mtp int64) error {
+	switch {
+	case t.lockTime < 0:
+		return fmt.Errorf("invalid lock time %d", t.lockTime)
+	case t.lockTime == 0:
+		return nil
+	case t.lockTime < LOCKTIME_THRESHOLD:
+		if t.lockTime > height {
+			return fmt.Errorf("transaction from %s is locked until height %d (block height %d)",
+				t.senderBlockchainAddress, t.lockTime, height)
+		}
+	case t.lockTime > mtp:
+		return fmt.Errorf("transaction from %s is locked until %s (median time %s)", t.senderBlockchainAddress,
+			time.Unix(t.lockTime, 0).UTC().Format(time.RFC3339), time.Unix(mtp, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package block
+
+import (
+	"GoProject/params"
+	"testing"
+	"time"
+)
+
+func chainWithTimes(seconds ...int64) []*Block {
+	chain := make([]*Block, len(seconds))
+	for i, s := range seconds {
+		chain[i] = newBlock(s*int64(time.Second), 0, [32]byte{}, [32]byte{}, []*Transaction{})
+	}
+	return chain
+}
+
+func TestMedianTimePast(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain []*Block
+		want  int64
+	}{
+		{"empty", nil, 0},
+		{"one block", chainWithTimes(100), 100},
+		{"unsorted", chainWithTimes(100, 300, 200), 200},
+		{"even count", chainWithTimes(100, 200, 300, 400), 300},
+		//只使用最后MEDIAN_TIME_SPAN个区块
+		{"long chain", chainWithTimes(1000, 1000, 1000, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11), 6},
+		//最新区块的时间戳不能单独推动中位时间
+		{"future tip", chainWithTimes(10, 20, 30, 40, 50, 1<<32), 40},
+	}
+	for _, tt := range tests {
+		if got := medianTimePast(tt.chain); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 高度锁与时间锁的边界
+func TestCheckLockTime(t *testing.T) {
+	const mtp = 1710000000
+	tests := []struct {
+		name     string
+		lockTime int64
+		height   int64
+		ok       bool
+	}{
+		{"not locked", 0, 0, true},
+		{"negative", -1, 100, false},
+		{"height reached", 5, 5, true},
+		{"height passed", 5, 6, true},
+		{"height not reached", 5, 4, false},
+		//阈值以下都按高度处理,即使数值很大
+		{"largest height", LOCKTIME_THRESHOLD - 1, LOCKTIME_THRESHOLD - 2, false},
+		{"largest height reached", LOCKTIME_THRESHOLD - 1, LOCKTIME_THRESHOLD - 1, true},
+		//阈值及以上按时间处理,与高度无关
+		{"smallest time", LOCKTIME_THRESHOLD, 1 << 40, true},
+		{"time reached", mtp, 1, true},
+		{"time not reached", mtp + 1, 1 << 40, false},
+	}
+	for _, tt := range tests {
+		tx := NewTransaction("alice", "bob", 1)
+		tx.lockTime = tt.lockTime
+		if err := tx.checkLockTime(tt.height, mtp); (err == nil) != tt.ok {
+			t.Errorf("%s: err %v", tt.name, err)
+		}
+	}
+}
+
+// 交易池和区块按下一个区块的高度和当前中位时间判断
+func TestLockedTransaction(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice, bob := newTestKey(t), newTestKey(t)
+	bc.SetMockTime(1710000000)
+	bc.Generate(2, alice.address)
+	mtp := bc.MedianTimePast()
+	if mtp != 1710000000 {
+		t.Fatalf("median time %d", mtp)
+	}
+	accept := func(lockTime int64) error {
+		tx := NewTransaction(alice.address, bob.address, 0.1)
+		tx.nonce = bc.NextNonce(alice.address)
+		tx.lockTime = lockTime
+		signTransaction(t, alice.privateKey, tx, params.RegTest.ChainId)
+		return bc.AcceptTransaction(alice.address, bob.address, 0.1, tx.nonce, lockTime, nil, tx.senderPublicKey, tx.signature)
+	}
+	height := int64(len(bc.Chain()))
+	for _, lockTime := range []int64{height + 1, mtp + 1} {
+		if err := accept(lockTime); err == nil {
+			t.Errorf("lock time %d accepted", lockTime)
+		}
+	}
+	for _, lockTime := range []int64{height, mtp} {
+		if err := accept(lockTime); err != nil {
+			t.Errorf("lock time %d: %v", lockTime, err)
+		}
+	}
+	//锁定时间参与签名
+	tx := NewTransaction(alice.address, bob.address, 0.1)
+	tx.nonce = bc.NextNonce(alice.address)
+	signTransaction(t, alice.privateKey, tx, params.RegTest.ChainId)
+	if err := bc.AcceptTransaction(alice.address, bob.address, 0.1, tx.nonce, 1, nil, tx.senderPublicKey, tx.signature); err == nil {
+		t.Error("lock time changed after signing")
+	}
+}
+
+// 邻居和外部矿工的区块时间戳必须晚于中位时间,且不能超前太多
+func TestBlockTimestamp(t *testing.T) {
+	now := int64(1710000000)
+	tests := []struct {
+		name      string
+		timestamp int64
+		ok        bool
+	}{
+		{"median time past", now, false},
+		{"after median time past", now + 1, true},
+		{"max future", now + MAX_FUTURE_BLOCK_SEC, true},
+		{"too far in the future", now + MAX_FUTURE_BLOCK_SEC + 1, false},
+	}
+	for _, tt := range tests {
+		bc := NewBlockChain(testMiner, 0, params.RegTest)
+		bc.SetMockTime(now)
+		generate(t, bc, 1, testMiner)
+		b := solveBlock(bc, bc.Chain(), []*Transaction{newCoinbase(testMiner, 2)})
+		b.timestamp = tt.timestamp * int64(time.Second)
+		if err := bc.indexChain(append(bc.Chain(), b)); (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
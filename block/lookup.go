@@ -0,0 +1,98 @@
+package block
+
+import (
+	"encoding/hex"
+)
+
+func (b *Block) Timestamp() int64 {
+	return b.timestamp
+}
+
+// 解析十六进制的区块哈希
+func ParseBlockHash(s string) ([32]byte, error) {
+	return parseHash("block hash", s)
+}
+
+// 带高度、哈希和确认数的区块,侧链区块的确认数为-1
+type BlockInfo struct {
+	Height        int    `json:"height"`
+	Hash          string `json:"hash"`
+	Confirmations int    `json:"confirmations"`
+	Block         *Block `json:"block"`
+}
+
+// 主链末端的概要
+type TipInfo struct {
+	Height     int    `json:"height"`
+	Hash       string `json:"hash"`
+	Timestamp  int64  `json:"timestamp"`
+	Difficulty int    `json:"difficulty"`
+}
+
+func (bc *BlockChain) blockInfo(height int) *BlockInfo {
+	b := bc.chain[height]
+	hash := b.Hash()
+	return &BlockInfo{
+		Height:        height,
+		Hash:          hex.EncodeToString(hash[:]),
+		Confirmations: len(bc.chain) - height,
+		Block:         b,
+	}
+}
+
+// 主链上高度为height的区块,超出主链时返回nil
+func (bc *BlockChain) BlockByHeight(height int) *BlockInfo {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if height < 0 || height >= len(bc.chain) {
+		return nil
+	}
+	return bc.blockInfo(height)
+}
+
+// 按哈希查找区块,包括区块树中的侧链区块,未知时返回nil
+func (bc *BlockChain) BlockByHash(hash [32]byte) *BlockInfo {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	n := bc.index.LookupNode(hash)
+	if n == nil {
+		return nil
+	}
+	if n.height < len(bc.chain) && bc.chain[n.height].Hash() == hash {
+		return bc.blockInfo(n.height)
+	}
+	return &BlockInfo{
+		Height:        n.height,
+		Hash:          hex.EncodeToString(hash[:]),
+		Confirmations: -1,
+		Block:         n.block,
+	}
+}
+
+// 主链上高度from到to(包含)的区块,to超出主链时截止到末端
+func (bc *BlockChain) Blocks(from int, to int) []*BlockInfo {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if to >= len(bc.chain) {
+		to = len(bc.chain) - 1
+	}
+	blocks := []*BlockInfo{}
+	for h := from; h <= to; h++ {
+		blocks = append(blocks, bc.blockInfo(h))
+	}
+	return blocks
+}
+
+func (bc *BlockChain) Tip() *TipInfo {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	height := len(bc.chain) - 1
+	b := bc.chain[height]
+	hash := b.Hash()
+	return &TipInfo{
+		Height:     height,
+		Hash:       hex.EncodeToString(hash[:]),
+		Timestamp:  b.timestamp,
+		Difficulty: bc.params.Difficulty,
+	}
+}
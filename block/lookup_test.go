@@ -0,0 +1,112 @@
+package block
+
+import (
+	"GoProject/params"
+	"encoding/hex"
+	"testing"
+)
+
+func TestBlockByHeight(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	generate(t, bc, 3, testMiner)
+	tests := []struct {
+		height        int
+		found         bool
+		confirmations int
+	}{
+		{-1, false, 0},
+		{0, true, 4},
+		{3, true, 1},
+		{4, false, 0},
+	}
+	for _, tt := range tests {
+		info := bc.BlockByHeight(tt.height)
+		if (info != nil) != tt.found {
+			t.Errorf("height %d: found %v", tt.height, info != nil)
+			continue
+		}
+		if info != nil && (info.Height != tt.height || info.Confirmations != tt.confirmations) {
+			t.Errorf("height %d: %+v", tt.height, info)
+		}
+	}
+}
+
+// 侧链区块可以按哈希查到,确认数为-1
+func TestBlockByHash(t *testing.T) {
+	a, b, alice := forkedChains(t)
+	generate(t, a, 1, alice.address)
+	orphan := a.LastBlock().Hash()
+	generate(t, b, 2, testMiner)
+	if !syncFrom(t, a, b) {
+		t.Fatal("longer chain not adopted")
+	}
+	tip := a.LastBlock().Hash()
+	tests := []struct {
+		name          string
+		hash          [32]byte
+		height        int
+		confirmations int
+	}{
+		{"tip", tip, 3, 1},
+		{"genesis", a.Chain()[0].Hash(), 0, 4},
+		{"side chain", orphan, 2, -1},
+	}
+	for _, tt := range tests {
+		info := a.BlockByHash(tt.hash)
+		if info == nil || info.Height != tt.height || info.Confirmations != tt.confirmations ||
+			info.Hash != hex.EncodeToString(tt.hash[:]) {
+			t.Errorf("%s: %+v", tt.name, info)
+		}
+	}
+	if info := a.BlockByHash([32]byte{1}); info != nil {
+		t.Errorf("unknown hash: %+v", info)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	generate(t, bc, 4, testMiner)
+	tests := []struct {
+		from, to int
+		want     []int
+	}{
+		{0, 4, []int{0, 1, 2, 3, 4}},
+		{2, 2, []int{2}},
+		//超出主链的部分截止到末端
+		{3, 10, []int{3, 4}},
+		{5, 10, nil},
+	}
+	for _, tt := range tests {
+		blocks := bc.Blocks(tt.from, tt.to)
+		if len(blocks) != len(tt.want) {
+			t.Errorf("%d-%d: %d blocks", tt.from, tt.to, len(blocks))
+			continue
+		}
+		for i, info := range blocks {
+			if info.Height != tt.want[i] || info.Block.Hash() != bc.Chain()[tt.want[i]].Hash() {
+				t.Errorf("%d-%d: block %d at height %d", tt.from, tt.to, i, info.Height)
+			}
+		}
+	}
+}
+
+func TestTip(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	bc.SetMockTime(1710000000)
+	generate(t, bc, 2, testMiner)
+	tip := bc.Tip()
+	hash := bc.LastBlock().Hash()
+	if tip.Height != 2 || tip.Hash != hex.EncodeToString(hash[:]) || tip.Difficulty != params.RegTest.Difficulty {
+		t.Errorf("tip %+v", tip)
+	}
+	if tip.Timestamp != bc.LastBlock().Timestamp() {
+		t.Errorf("tip timestamp %d", tip.Timestamp)
+	}
+	parsed, err := ParseBlockHash(tip.Hash)
+	if err != nil || parsed != hash {
+		t.Errorf("parse %s: %v", tip.Hash, err)
+	}
+	if _, err := ParseBlockHash("00"); err == nil {
+		t.Error("parsed short hash")
+	}
+}
@@ -0,0 +1,151 @@
+package block
+
+import (
+	"GoProject/address"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 自动挖矿的状态和统计
+type miner struct {
+	running       bool
+	generation    int //每次启动加1,旧的挖矿循环发现不一致时退出
+	timer         *time.Timer
+	rewardAddress string //为空时使用节点地址
+	intervalSec   int    //为0时使用网络参数中的间隔
+	blocksFound   int
+	hashRate      float64 //最近一个区块的每秒哈希次数
+	lastBlockTime int64   //最近挖出区块的时间戳(纳秒)
+	mux           sync.Mutex
+}
+
+func (m *miner) recordBlock(hashes int, elapsed time.Duration, timestamp int64) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.blocksFound += 1
+	if elapsed > 0 {
+		m.hashRate = float64(hashes) / elapsed.Seconds()
+	}
+	m.lastBlockTime = timestamp
+}
+
+// 挖矿状态,用于/mine/status接口
+type MiningStatus struct {
+	Running       bool    `json:"running"`
+	RewardAddress string  `json:"reward_address"`
+	IntervalSec   int     `json:"interval_sec"`
+	BlocksFound   int     `json:"blocks_found"`
+	HashRate      float64 `json:"hash_rate"`
+	LastBlockTime int64   `json:"last_block_time"`
+}
+
+// 挖矿奖励的接收地址
+func (bc *BlockChain) RewardAddress() string {
+	bc.miner.mux.Lock()
+	defer bc.miner.mux.Unlock()
+	if bc.miner.rewardAddress != "" {
+		return bc.miner.rewardAddress
+	}
+	return bc.blockChainAddress
+}
+
+func (bc *BlockChain) SetRewardAddress(rewardAddress string) error {
+	if err := address.Validate(rewardAddress, bc.params); err != nil {
+		return err
+	}
+	bc.miner.mux.Lock()
+	defer bc.miner.mux.Unlock()
+	bc.miner.rewardAddress = rewardAddress
+	return nil
+}
+
+// 自动挖矿间隔,为0时每次启动只挖一个区块
+func (bc *BlockChain) MiningInterval() int {
+	bc.miner.mux.Lock()
+	defer bc.miner.mux.Unlock()
+	return bc.miningInterval()
+}
+
+func (bc *BlockChain) miningInterval() int {
+	if bc.miner.intervalSec > 0 {
+		return bc.miner.intervalSec
+	}
+	return bc.params.MiningTimerSec
+}
+
+func (bc *BlockChain) SetMiningInterval(sec int) error {
+	if sec < 0 {
+		return fmt.Errorf("invalid mining interval %d", sec)
+	}
+	bc.miner.mux.Lock()
+	defer bc.miner.mux.Unlock()
+	bc.miner.intervalSec = sec
+	return nil
+}
+
+// 开始挖矿,已经在挖矿时不会启动第二个循环,返回是否新启动
+func (bc *BlockChain) StartMining() bool {
+	bc.miner.mux.Lock()
+	if bc.miner.running {
+		bc.miner.mux.Unlock()
+		return false
+	}
+	bc.miner.running = true
+	bc.miner.generation += 1
+	generation := bc.miner.generation
+	bc.miner.mux.Unlock()
+	log.Println("action=start_mining")
+	go bc.miningLoop(generation)
+	return true
+}
+
+func (bc *BlockChain) miningLoop(generation int) {
+	bc.Mining()
+	bc.miner.mux.Lock()
+	defer bc.miner.mux.Unlock()
+	//已经停止或者被重新启动
+	if !bc.miner.running || bc.miner.generation != generation {
+		return
+	}
+	interval := bc.miningInterval()
+	//间隔为0时只挖一次
+	if interval == 0 {
+		bc.miner.running = false
+		return
+	}
+	bc.miner.timer = time.AfterFunc(time.Second*time.Duration(interval), func() {
+		bc.miningLoop(generation)
+	})
+}
+
+// 停止挖矿,正在进行的工作量证明会完成,返回是否之前在挖矿
+func (bc *BlockChain) StopMining() bool {
+	bc.miner.mux.Lock()
+	defer bc.miner.mux.Unlock()
+	if !bc.miner.running {
+		return false
+	}
+	bc.miner.running = false
+	if bc.miner.timer != nil {
+		bc.miner.timer.Stop()
+		bc.miner.timer = nil
+	}
+	log.Println("action=stop_mining")
+	return true
+}
+
+func (bc *BlockChain) MiningStatus() *MiningStatus {
+	address := bc.RewardAddress()
+	bc.miner.mux.Lock()
+	defer bc.miner.mux.Unlock()
+	return &MiningStatus{
+		Running:       bc.miner.running,
+		RewardAddress: address,
+		IntervalSec:   bc.miningInterval(),
+		BlocksFound:   bc.miner.blocksFound,
+		HashRate:      bc.miner.hashRate,
+		LastBlockTime: bc.miner.lastBlockTime,
+	}
+}
@@ -0,0 +1,129 @@
+package block
+
+import (
+	"GoProject/address"
+	"GoProject/params"
+	"testing"
+	"time"
+)
+
+// 等待挖矿循环停止
+func waitStopped(t *testing.T, bc *BlockChain) *MiningStatus {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		if s := bc.MiningStatus(); !s.Running {
+			return s
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("mining did not stop")
+	return nil
+}
+
+func TestStartStopMining(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	if err := bc.SetMiningInterval(3600); err != nil {
+		t.Fatal(err)
+	}
+	if !bc.StartMining() {
+		t.Fatal("mining not started")
+	}
+	//已经在挖矿时不会启动第二个循环
+	if bc.StartMining() {
+		t.Error("second mining loop started")
+	}
+	if s := bc.MiningStatus(); !s.Running || s.IntervalSec != 3600 {
+		t.Errorf("status %+v", s)
+	}
+	if !bc.StopMining() {
+		t.Error("running miner not stopped")
+	}
+	if bc.StopMining() {
+		t.Error("stopped miner stopped again")
+	}
+	if bc.MiningStatus().Running {
+		t.Error("status running after stop")
+	}
+}
+
+// 间隔为0时每次启动只挖一个区块
+func TestMineOnce(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice := newTestKey(t).address
+	if err := bc.SetRewardAddress(alice); err != nil {
+		t.Fatal(err)
+	}
+	if !bc.StartMining() {
+		t.Fatal("mining not started")
+	}
+	s := waitStopped(t, bc)
+	if s.BlocksFound != 1 || s.LastBlockTime == 0 || s.RewardAddress != alice {
+		t.Fatalf("status %+v", s)
+	}
+	if amount := bc.CalculateTotalAmount(alice); amount != MINING_REWARD {
+		t.Errorf("reward address has %v, want %v", amount, MINING_REWARD)
+	}
+	//停止后可以再次启动
+	if !bc.StartMining() {
+		t.Fatal("mining not restarted")
+	}
+	if s := waitStopped(t, bc); s.BlocksFound != 2 {
+		t.Errorf("blocks found %d, want 2", s.BlocksFound)
+	}
+}
+
+func TestMiningSettings(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.TestNet)
+	if s := bc.MiningStatus(); s.RewardAddress != testMiner || s.IntervalSec != params.TestNet.MiningTimerSec {
+		t.Errorf("default status %+v", s)
+	}
+	if err := bc.SetMiningInterval(-1); err == nil {
+		t.Error("negative interval accepted")
+	}
+	//奖励地址必须属于当前网络
+	for _, a := range []string{"", "alice", (&address.Address{Version: params.MainNet.AddressVersion}).String()} {
+		if err := bc.SetRewardAddress(a); err == nil {
+			t.Errorf("reward address %q accepted", a)
+		}
+	}
+	alice := address.FromPublicKey(&newTestKey(t).privateKey.PublicKey, params.TestNet.AddressVersion).String()
+	bc.SetMiningInterval(5)
+	if err := bc.SetRewardAddress(alice); err != nil {
+		t.Fatal(err)
+	}
+	if s := bc.MiningStatus(); s.RewardAddress != alice || s.IntervalSec != 5 {
+		t.Errorf("status %+v", s)
+	}
+	//0恢复使用网络参数中的间隔
+	bc.SetMiningInterval(0)
+	if s := bc.MiningStatus(); s.RewardAddress != alice || s.IntervalSec != params.TestNet.MiningTimerSec {
+		t.Errorf("reset status %+v", s)
+	}
+}
+
+// 工作量证明只使用候选区块,期间加入交易池的交易留到下一个区块
+func TestProofOfWorkOutsideLock(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice, bob := newTestKey(t), newTestKey(t)
+	bc.Generate(1, alice.address)
+	tmpl := bc.NewBlockTemplate(testMiner)
+
+	tx := NewTransaction(alice.address, bob.address, 0.5)
+	signTransaction(t, alice.privateKey, tx, params.RegTest.ChainId)
+	if err := bc.AcceptTransaction(alice.address, bob.address, 0.5, 0, 0, nil, tx.senderPublicKey, tx.signature); err != nil {
+		t.Fatal(err)
+	}
+	nonce := tmpl.ProofOfWork()
+	if !MeetsDifficulty(nonce, tmpl.PreviousHash, tmpl.StateRoot, tmpl.Transactions, tmpl.Difficulty) {
+		t.Fatalf("nonce %d does not meet the difficulty", nonce)
+	}
+	bc.mux.Lock()
+	b := bc.connectMined(tmpl, nonce, 0)
+	bc.mux.Unlock()
+	if bc.LastBlock().Hash() != b.Hash() || len(b.transactions) != 1 {
+		t.Fatalf("mined block not connected")
+	}
+	if pool := bc.TransactionPool(); len(pool) != 1 || pool[0].ID() != tx.ID() {
+		t.Errorf("pool has %d transactions, want the one accepted during mining", len(pool))
+	}
+}
@@ -0,0 +1,53 @@
+package block
+
+import (
+	"GoProject/address"
+	"fmt"
+	"time"
+)
+
+// 一次/generate最多生成的区块数
+const MAX_GENERATE_BLOCKS = 1000
+
+// 当前时间,设置了模拟时间时返回模拟时间
+func (bc *BlockChain) Now() time.Time {
+	if bc.mockTime != 0 {
+		return time.Unix(bc.mockTime, 0)
+	}
+	return time.Now()
+}
+
+// 设置模拟时间(unix秒),0表示恢复使用系统时间,只在回归测试网可用
+func (bc *BlockChain) SetMockTime(timestamp int64) error {
+	if !bc.params.RegTestMode {
+		return fmt.Errorf("mock time is only available on regtest")
+	}
+	if timestamp < 0 {
+		return fmt.Errorf("invalid timestamp %d", timestamp)
+	}
+	bc.mockTime = timestamp
+	return nil
+}
+
+// 立即挖出n个区块,奖励发给rewardAddress(为空时使用节点地址),只在回归测试网可用
+func (bc *BlockChain) Generate(n int, rewardAddress string) ([]*Block, error) {
+	if !bc.params.RegTestMode {
+		return nil, fmt.Errorf("generate is only available on regtest")
+	}
+	if n < 1 || n > MAX_GENERATE_BLOCKS {
+		return nil, fmt.Errorf("blocks must be between 1 and %d", MAX_GENERATE_BLOCKS)
+	}
+	if rewardAddress == "" {
+		rewardAddress = bc.blockChainAddress
+	} else if err := address.Validate(rewardAddress, bc.params); err != nil {
+		return nil, err
+	}
+	bc.mux.Lock()
+	blocks := make([]*Block, 0, n)
+	for i := 0; i < n; i++ {
+		blocks = append(blocks, bc.mineBlock(rewardAddress))
+	}
+	bc.mux.Unlock()
+	bc.notifyNeighbors()
+	return blocks, nil
+}
@@ -0,0 +1,76 @@
+package block
+
+import (
+	"GoProject/params"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice := newTestKey(t).address
+	blocks, err := bc.Generate(3, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 || len(bc.Chain()) != 4 || bc.LastBlock() != blocks[2] {
+		t.Fatalf("generated %d blocks, chain has %d", len(blocks), len(bc.Chain()))
+	}
+	for i, b := range blocks {
+		if b.PreviousHash() != bc.Chain()[i].Hash() {
+			t.Errorf("block %d does not link to its parent", i+1)
+		}
+	}
+	if amount := bc.CalculateTotalAmount(alice); amount != 3*MINING_REWARD {
+		t.Errorf("alice has %v, want %v", amount, 3*MINING_REWARD)
+	}
+	//地址为空时奖励发给节点地址
+	if _, err := bc.Generate(1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if amount := bc.CalculateTotalAmount(testMiner); amount != MINING_REWARD {
+		t.Errorf("miner has %v, want %v", amount, MINING_REWARD)
+	}
+	for _, n := range []int{0, -1, MAX_GENERATE_BLOCKS + 1} {
+		if _, err := bc.Generate(n, alice); err == nil {
+			t.Errorf("generate %d blocks accepted", n)
+		}
+	}
+	if _, err := bc.Generate(1, "alice"); err == nil {
+		t.Errorf("invalid reward address accepted")
+	}
+}
+
+func TestMockTime(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	if err := bc.SetMockTime(-1); err == nil {
+		t.Errorf("negative mock time accepted")
+	}
+	if err := bc.SetMockTime(1710000000); err != nil {
+		t.Fatal(err)
+	}
+	if now := bc.Now().Unix(); now != 1710000000 {
+		t.Errorf("now %d, want the mock time", now)
+	}
+	blocks, _ := bc.Generate(1, "")
+	if ts := blocks[0].timestamp; ts != 1710000000*1e9 {
+		t.Errorf("block timestamp %d, want the mock time", ts)
+	}
+	//0恢复使用系统时间
+	bc.SetMockTime(0)
+	if bc.Now().Unix() == 1710000000 {
+		t.Errorf("mock time not cleared")
+	}
+}
+
+// 其他网络不能按需挖矿或修改时间
+func TestRegTestOnly(t *testing.T) {
+	for _, p := range []*params.Params{params.MainNet, params.TestNet} {
+		bc := NewBlockChain(testMiner, 0, p)
+		if _, err := bc.Generate(1, ""); err == nil {
+			t.Errorf("%s: generate accepted", p.Name)
+		}
+		if err := bc.SetMockTime(1710000000); err == nil {
+			t.Errorf("%s: mock time accepted", p.Name)
+		}
+	}
+}
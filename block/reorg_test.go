@@ -0,0 +1,306 @@
+package block
+
+import (
+	"GoProject/params"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 签名的转账交易
+func (k *testKey) transfer(t *testing.T, recipient string, value float32, nonce uint64) *Transaction {
+	tx := NewTransaction(k.address, recipient, value)
+	tx.nonce = nonce
+	return signTransaction(t, k.privateKey, tx, params.RegTest.ChainId)
+}
+
+// 以邻居节点的方式提供bc的/chain
+func serveChain(t *testing.T, bc *BlockChain) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bc.mux.Lock()
+		m, _ := json.Marshal(bc)
+		bc.mux.Unlock()
+		w.Header().Set(NETWORK_MAGIC_HEADER, bc.params.MagicString())
+		w.Write(m)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// 从peer同步主链
+func syncFrom(t *testing.T, bc *BlockChain, peer *BlockChain) bool {
+	bc.neighbors = []string{serveChain(t, peer)}
+	return bc.ResolveConflicts()
+}
+
+func generate(t *testing.T, bc *BlockChain, n int, rewardAddress string) {
+	t.Helper()
+	if _, err := bc.Generate(n, rewardAddress); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 两个共享前两个区块的节点,alice在其中持有挖矿奖励
+func forkedChains(t *testing.T) (a *BlockChain, b *BlockChain, alice *testKey) {
+	alice = newTestKey(t)
+	a = NewBlockChain(alice.address, 0, params.RegTest)
+	b = NewBlockChain(alice.address, 0, params.RegTest)
+	generate(t, a, 1, alice.address)
+	if !syncFrom(t, b, a) {
+		t.Fatal("peer did not adopt the shared chain")
+	}
+	return a, b, alice
+}
+
+func TestFindTransaction(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice, bob := newTestKey(t), newTestKey(t)
+	generate(t, bc, 2, alice.address)
+	tx := alice.transfer(t, bob.address, 0.1, 0)
+	if err := bc.acceptTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	if info := bc.FindTransaction(tx.ID()); info == nil || info.Height != -1 {
+		t.Fatalf("pool transaction: %+v", info)
+	}
+	generate(t, bc, 2, testMiner)
+	info := bc.FindTransaction(tx.ID())
+	if info == nil || info.Height != 3 || info.Confirmations != 2 || info.Transaction.ID() != tx.ID() {
+		t.Fatalf("mined transaction: %+v", info)
+	}
+	hash := bc.Chain()[3].Hash()
+	if info.BlockHash != hex.EncodeToString(hash[:]) {
+		t.Errorf("block hash %s", info.BlockHash)
+	}
+	id, err := ParseTxID(info.ID)
+	if err != nil || id != tx.ID() {
+		t.Errorf("parse id %s: %v", info.ID, err)
+	}
+	for _, s := range []string{"", "zz", info.ID[2:]} {
+		if _, err := ParseTxID(s); err == nil {
+			t.Errorf("parsed invalid id %q", s)
+		}
+	}
+}
+
+// 交易ID不包含签名,同一地址在不同区块的挖矿奖励ID不同
+func TestTransactionID(t *testing.T) {
+	alice, bob := newTestKey(t), newTestKey(t)
+	tx := alice.transfer(t, bob.address, 0.1, 0)
+	id := tx.ID()
+	//ECDSA签名是随机的,重新签名得到不同的签名
+	signature := tx.signature
+	signTransaction(t, alice.privateKey, tx, params.RegTest.ChainId)
+	if tx.signature.String() == signature.String() {
+		t.Fatal("same signature")
+	}
+	if tx.ID() != id {
+		t.Error("id changed after signing again")
+	}
+	if alice.transfer(t, bob.address, 0.1, 1).ID() == id {
+		t.Error("same id for another nonce")
+	}
+
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	generate(t, bc, 2, alice.address)
+	first, second := bc.Chain()[1].transactions[0], bc.Chain()[2].transactions[0]
+	if first.ID() == second.ID() {
+		t.Fatal("coinbases of different blocks have the same id")
+	}
+	for height, coinbase := range []*Transaction{first, second} {
+		info := bc.FindTransaction(coinbase.ID())
+		if info == nil || info.Height != height+1 || len(bc.txIndex[coinbase.ID()]) != 1 {
+			t.Errorf("coinbase at height %d: %+v", height+1, info)
+		}
+	}
+}
+
+// 地址的交易从新到旧分页
+func TestAddressTransactions(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice, bob := newTestKey(t), newTestKey(t)
+	generate(t, bc, 1, alice.address)
+	var ids [][32]byte
+	for i, value := range []float32{0.1, 0.2, 0.3} {
+		tx := alice.transfer(t, bob.address, value, uint64(i))
+		if err := bc.acceptTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+		generate(t, bc, 1, testMiner)
+		ids = append(ids, tx.ID())
+	}
+	tests := []struct {
+		offset int
+		limit  int
+		want   [][32]byte
+	}{
+		{0, 10, [][32]byte{ids[2], ids[1], ids[0]}},
+		{0, 2, [][32]byte{ids[2], ids[1]}},
+		{2, 2, [][32]byte{ids[0]}},
+		{3, 2, nil},
+	}
+	for _, tt := range tests {
+		infos, n := bc.AddressTransactions(bob.address, tt.offset, tt.limit)
+		if n != 3 || len(infos) != len(tt.want) {
+			t.Errorf("offset %d limit %d: %d of %d", tt.offset, tt.limit, len(infos), n)
+			continue
+		}
+		for i, info := range infos {
+			if info.Transaction.ID() != tt.want[i] {
+				t.Errorf("offset %d limit %d: transaction %d out of order", tt.offset, tt.limit, i)
+			}
+		}
+	}
+}
+
+// 被替换的分支上的交易从索引中移除
+func TestReorgDisconnectsIndexes(t *testing.T) {
+	a, b, alice := forkedChains(t)
+	bob := newTestKey(t)
+	tx := alice.transfer(t, bob.address, 0.1, 0)
+	tx.data = []byte("memo")
+	signTransaction(t, alice.privateKey, tx, params.RegTest.ChainId)
+	if err := a.acceptTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	generate(t, a, 1, alice.address)
+	if info := a.FindTransaction(tx.ID()); info == nil || info.Height != 2 {
+		t.Fatalf("mined transaction not indexed at height 2: %+v", info)
+	}
+
+	//另一条分支更长且不包含tx
+	generate(t, b, 2, alice.address)
+	if !syncFrom(t, a, b) {
+		t.Fatal("longer chain not adopted")
+	}
+	if a.LastBlock().Hash() != b.LastBlock().Hash() {
+		t.Fatal("tip differs from the longer chain")
+	}
+	if _, ok := a.txIndex[tx.ID()]; ok {
+		t.Errorf("orphaned transaction still in the transaction index")
+	}
+	if infos, n := a.AddressTransactions(bob.address, 0, 10); n != 0 {
+		t.Errorf("orphaned transaction still in the address index: %+v", infos)
+	}
+	if locations := a.FindData([]byte("memo")); len(locations) != 0 {
+		t.Errorf("orphaned transaction still in the data index: %+v", locations)
+	}
+	if balance := a.CalculateTotalAmount(bob.address); balance != 0 {
+		t.Errorf("bob balance %v after reorg", balance)
+	}
+}
+
+// 新分支在不同的区块中打包了同一笔交易
+func TestReorgReindexesTransactions(t *testing.T) {
+	a, b, alice := forkedChains(t)
+	bob := newTestKey(t)
+	tx := alice.transfer(t, bob.address, 0.1, 0)
+	if err := a.acceptTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	generate(t, a, 1, alice.address)
+
+	generate(t, b, 1, bob.address)
+	if err := b.acceptTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	generate(t, b, 1, bob.address)
+	if !syncFrom(t, a, b) {
+		t.Fatal("longer chain not adopted")
+	}
+	hash := b.Chain()[3].Hash()
+	locations := a.txIndex[tx.ID()]
+	if len(locations) != 1 || locations[0].Height != 3 || locations[0].BlockHash != hex.EncodeToString(hash[:]) {
+		t.Errorf("transaction not reindexed in the new block: %+v", locations)
+	}
+	if infos, n := a.AddressTransactions(bob.address, 0, 10); n != 3 || infos[0].Height != 3 {
+		t.Errorf("address index has %d transactions: %+v", n, infos)
+	}
+}
+
+// 旧分支上的交易放回交易池,排在原有交易之前
+func TestReorgReturnsOrphanedTransactions(t *testing.T) {
+	a, b, alice := forkedChains(t)
+	bob := newTestKey(t)
+	tx1 := alice.transfer(t, bob.address, 0.1, 0)
+	tx2 := alice.transfer(t, bob.address, 0.2, 1)
+	if err := a.acceptTransaction(tx1); err != nil {
+		t.Fatal(err)
+	}
+	generate(t, a, 1, alice.address)
+	if err := a.acceptTransaction(tx2); err != nil {
+		t.Fatal(err)
+	}
+	if info := a.FindTransaction(tx1.ID()); info == nil || info.Height != 2 {
+		t.Fatalf("mined transaction not indexed at height 2: %+v", info)
+	}
+
+	//另一条分支更长且不包含tx1
+	generate(t, b, 2, alice.address)
+	if !syncFrom(t, a, b) {
+		t.Fatal("longer chain not adopted")
+	}
+	if a.LastBlock().Hash() != b.LastBlock().Hash() {
+		t.Fatal("tip differs from the longer chain")
+	}
+	if _, ok := a.txIndex[tx1.ID()]; ok {
+		t.Errorf("orphaned transaction still in the transaction index")
+	}
+	if infos, n := a.AddressTransactions(bob.address, 0, 10); n != 0 {
+		t.Errorf("orphaned transaction still in the address index: %+v", infos)
+	}
+	if info := a.FindTransaction(tx1.ID()); info == nil || info.Height != -1 {
+		t.Errorf("orphaned transaction not found in the pool: %+v", info)
+	}
+	pool := a.TransactionPool()
+	if len(pool) != 2 || pool[0].ID() != tx1.ID() || pool[1].ID() != tx2.ID() {
+		t.Fatalf("pool has %d transactions, want tx1 then tx2", len(pool))
+	}
+	if balance := a.CalculateTotalAmount(bob.address); balance != 0 {
+		t.Errorf("bob balance %v after reorg", balance)
+	}
+
+	//放回交易池的交易可以重新打包
+	generate(t, a, 1, alice.address)
+	if len(a.TransactionPool()) != 0 {
+		t.Errorf("pool not emptied by mining")
+	}
+	if info := a.FindTransaction(tx2.ID()); info == nil || info.Height != 4 {
+		t.Errorf("re-mined transaction not indexed at height 4: %+v", info)
+	}
+}
+
+// 已打包进新主链的交易不再放回交易池
+func TestReorgDropsIncludedTransactions(t *testing.T) {
+	a, b, alice := forkedChains(t)
+	bob := newTestKey(t)
+	tx1 := alice.transfer(t, bob.address, 0.1, 0)
+	tx2 := alice.transfer(t, bob.address, 0.2, 1)
+	if err := a.acceptTransaction(tx1); err != nil {
+		t.Fatal(err)
+	}
+	generate(t, a, 1, alice.address)
+	if err := a.acceptTransaction(tx2); err != nil {
+		t.Fatal(err)
+	}
+
+	//另一条分支在不同的区块中也打包了tx1
+	if err := b.acceptTransaction(tx1); err != nil {
+		t.Fatal(err)
+	}
+	generate(t, b, 2, bob.address)
+	if !syncFrom(t, a, b) {
+		t.Fatal("longer chain not adopted")
+	}
+	locations := a.txIndex[tx1.ID()]
+	if len(locations) != 1 || locations[0].Height != 2 || locations[0].BlockHash != b.blockInfo(2).Hash {
+		t.Errorf("transaction not reindexed in the new block: %+v", locations)
+	}
+	pool := a.TransactionPool()
+	if len(pool) != 1 || pool[0].ID() != tx2.ID() {
+		t.Fatalf("pool has %d transactions, want only tx2", len(pool))
+	}
+}
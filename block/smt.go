@@ -0,0 +1,95 @@
+package block
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+)
+
+// 稀疏默克尔树的深度: 键为32字节哈希,每一位决定一层的方向(0向左,1向右),从最高位开始
+const SMT_DEPTH = 256
+
+// emptyHashes[i]为高度i的空子树的哈希,空叶子为全0
+var emptyHashes = func() [SMT_DEPTH + 1][32]byte {
+	var h [SMT_DEPTH + 1][32]byte
+	for i := 1; i <= SMT_DEPTH; i++ {
+		h[i] = smtNode(h[i-1], h[i-1])
+	}
+	return h
+}()
+
+// 内部节点的哈希,前缀0x01与叶子区分
+func smtNode(left [32]byte, right [32]byte) [32]byte {
+	b := make([]byte, 0, 1+2*32)
+	b = append(b, 0x01)
+	b = append(b, left[:]...)
+	b = append(b, right[:]...)
+	return sha256.Sum256(b)
+}
+
+// 键的第i位(从最高位开始)
+func smtBit(key [32]byte, i int) byte {
+	return (key[i/8] >> (7 - uint(i%8))) & 1
+}
+
+func sortedKeys(leaves map[[32]byte][32]byte) [][32]byte {
+	keys := make([][32]byte, 0, len(leaves))
+	for k := range leaves {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i][:], keys[j][:]) < 0
+	})
+	return keys
+}
+
+// 深度为depth的子树的哈希,keys为该子树中已排序的非空叶子
+func smtSubtree(keys [][32]byte, leaves map[[32]byte][32]byte, depth int) [32]byte {
+	if len(keys) == 0 {
+		return emptyHashes[SMT_DEPTH-depth]
+	}
+	if depth == SMT_DEPTH {
+		return leaves[keys[0]]
+	}
+	split := sort.Search(len(keys), func(i int) bool {
+		return smtBit(keys[i], depth) == 1
+	})
+	return smtNode(smtSubtree(keys[:split], leaves, depth+1), smtSubtree(keys[split:], leaves, depth+1))
+}
+
+// leaves: 键 -> 叶子哈希,不包含空叶子
+func smtRoot(leaves map[[32]byte][32]byte) [32]byte {
+	return smtSubtree(sortedKeys(leaves), leaves, 0)
+}
+
+// 从根到叶子路径上每一层的兄弟节点哈希
+func smtSiblings(leaves map[[32]byte][32]byte, key [32]byte) [SMT_DEPTH][32]byte {
+	var siblings [SMT_DEPTH][32]byte
+	keys := sortedKeys(leaves)
+	for depth := 0; depth < SMT_DEPTH; depth++ {
+		split := sort.Search(len(keys), func(i int) bool {
+			return smtBit(keys[i], depth) == 1
+		})
+		if smtBit(key, depth) == 0 {
+			siblings[depth] = smtSubtree(keys[split:], leaves, depth+1)
+			keys = keys[:split]
+		} else {
+			siblings[depth] = smtSubtree(keys[:split], leaves, depth+1)
+			keys = keys[split:]
+		}
+	}
+	return siblings
+}
+
+// 由叶子哈希和兄弟节点计算根哈希
+func smtComputeRoot(key [32]byte, leaf [32]byte, siblings [SMT_DEPTH][32]byte) [32]byte {
+	h := leaf
+	for depth := SMT_DEPTH - 1; depth >= 0; depth-- {
+		if smtBit(key, depth) == 0 {
+			h = smtNode(h, siblings[depth])
+		} else {
+			h = smtNode(siblings[depth], h)
+		}
+	}
+	return h
+}
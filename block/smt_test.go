@@ -0,0 +1,129 @@
+package block
+
+import (
+	"GoProject/params"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// 两个挖矿奖励和一笔转账之后的状态,另有一个合约
+func testState() *State {
+	s := NewState()
+	s.Apply([]*Transaction{
+		NewTransaction(MINING_SENDER, "alice", MINING_REWARD),
+		NewTransaction(MINING_SENDER, "bob", MINING_REWARD),
+		NewTransaction("alice", "carol", 0.5),
+	})
+	s.contracts["contract"] = &Contract{code: []byte{0x01}, storage: map[string][]byte{"k": []byte("v")}}
+	s.root = nil
+	return s
+}
+
+func TestEmptyRoot(t *testing.T) {
+	if root := NewState().Root(); root != emptyHashes[SMT_DEPTH] {
+		t.Fatalf("empty state root %x, want %x", root, emptyHashes[SMT_DEPTH])
+	}
+}
+
+// 根与叶子的插入顺序无关
+func TestRootOrderIndependent(t *testing.T) {
+	a := NewState()
+	a.Apply([]*Transaction{
+		NewTransaction(MINING_SENDER, "alice", MINING_REWARD),
+		NewTransaction(MINING_SENDER, "bob", MINING_REWARD),
+	})
+	b := NewState()
+	b.Apply([]*Transaction{
+		NewTransaction(MINING_SENDER, "bob", MINING_REWARD),
+		NewTransaction(MINING_SENDER, "alice", MINING_REWARD),
+	})
+	if a.Root() != b.Root() {
+		t.Fatalf("roots differ: %x != %x", a.Root(), b.Root())
+	}
+}
+
+func TestStateProof(t *testing.T) {
+	s := testState()
+	root := s.Root()
+	//存在的账户、合约账户和不存在的账户都能证明
+	for _, a := range []string{"alice", "bob", "carol", "contract", "nobody"} {
+		p := s.Proof(a)
+		if err := p.Verify(root); err != nil {
+			t.Errorf("%s: %v", a, err)
+		}
+	}
+	p := s.Proof("alice")
+	if p.Balance != MINING_REWARD-0.5 || p.Nonce != 1 {
+		t.Errorf("alice balance %v nonce %d", p.Balance, p.Nonce)
+	}
+	if p := s.Proof("nobody"); p.Balance != 0 || p.Nonce != 0 {
+		t.Errorf("nobody balance %v nonce %d", p.Balance, p.Nonce)
+	}
+	if p := s.Proof("contract"); p.CodeHash == "" || p.StorageRoot == "" {
+		t.Errorf("contract proof without code hash or storage root")
+	}
+}
+
+func TestStateProofTampered(t *testing.T) {
+	s := testState()
+	root := s.Root()
+	tests := []struct {
+		name   string
+		tamper func(p *StateProof)
+	}{
+		{"balance", func(p *StateProof) { p.Balance += 1 }},
+		{"nonce", func(p *StateProof) { p.Nonce += 1 }},
+		{"address", func(p *StateProof) { p.Address = "carol" }},
+		{"sibling", func(p *StateProof) {
+			h, _ := hex.DecodeString(p.Siblings[0])
+			h[0] ^= 0xff
+			p.Siblings[0] = hex.EncodeToString(h)
+		}},
+		{"missing sibling", func(p *StateProof) { p.Siblings = p.Siblings[1:] }},
+		{"extra sibling", func(p *StateProof) { p.Siblings = append(p.Siblings, p.Siblings[0]) }},
+		{"bitmap", func(p *StateProof) { p.Bitmap = p.Bitmap[:len(p.Bitmap)-2] }},
+		{"contract", func(p *StateProof) { p.CodeHash, p.StorageRoot = p.StateRoot, p.StateRoot }},
+	}
+	for _, tt := range tests {
+		p := s.Proof("alice")
+		tt.tamper(p)
+		if err := p.Verify(root); err == nil {
+			t.Errorf("%s: tampered proof verified", tt.name)
+		}
+	}
+	var other [32]byte
+	if err := s.Proof("alice").Verify(other); err == nil {
+		t.Errorf("proof verified against another root")
+	}
+}
+
+// 区块头中的状态根必须与应用交易后的状态一致
+func TestBlockStateRoot(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice := newTestKey(t)
+	bc.Generate(2, alice.address)
+	for height, b := range bc.Chain() {
+		if root := stateOf(bc.Chain()[:height+1]).Root(); root != b.StateRoot() {
+			t.Errorf("block %d state root %x, want %x", height, b.StateRoot(), root)
+		}
+	}
+	p, err := bc.StateProof(alice.address, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Balance != MINING_REWARD || p.Verify(bc.Chain()[1].StateRoot()) != nil {
+		t.Errorf("proof at height 1 %+v", p)
+	}
+	if _, err := bc.StateProof(alice.address, 3); err == nil {
+		t.Error("proof above the tip")
+	}
+
+	tmpl := bc.NewBlockTemplate(alice.address)
+	b := tmpl.Block(0)
+	b.stateRoot = bc.LastBlock().StateRoot()
+	solve(b, tmpl.Difficulty)
+	if err := bc.SubmitBlock(b); err == nil || !strings.Contains(err.Error(), "state root") {
+		t.Fatalf("block with a stale state root: %v", err)
+	}
+}
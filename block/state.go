@@ -0,0 +1,286 @@
+package block
+
+import (
+	"GoProject/address"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+// 账户状态
+type Account struct {
+	Balance float32 `json:"balance"`
+	Nonce   uint64  `json:"nonce"` //已打包进区块的发出交易数
+}
+
+// 所有账户的状态,连接区块时更新,根哈希记录在区块头中
+// 余额和nonce都为0的账户视为不存在,对应空叶子
+type State struct {
+	accounts  map[string]Account
+	contracts map[string]*Contract //合约地址 -> 合约
+
+	tokens        map[string]*Token            //代币符号 -> 代币
+	tokenBalances map[string]map[string]uint64 //代币符号 -> 地址 -> 数量
+
+	root *[32]byte //缓存的根哈希,修改后清空
+}
+
+func NewState() *State {
+	return &State{
+		accounts:      make(map[string]Account),
+		contracts:     make(map[string]*Contract),
+		tokens:        make(map[string]*Token),
+		tokenBalances: make(map[string]map[string]uint64),
+	}
+}
+
+// 从创世区块开始依次应用chain中的区块
+func stateOf(chain []*Block) *State {
+	s := NewState()
+	for _, b := range chain {
+		s.Apply(b.transactions)
+	}
+	return s
+}
+
+func (s *State) Account(blockChainAddress string) Account {
+	return s.accounts[blockChainAddress]
+}
+
+// 交易的nonce必须等于发送方账户当前的nonce,已打包的交易不能再次执行
+func (s *State) checkNonce(t *Transaction) error {
+	if n := s.accounts[t.senderBlockchainAddress].Nonce; t.nonce != n {
+		return fmt.Errorf("invalid nonce %d for %s, expected %d", t.nonce, t.senderBlockchainAddress, n)
+	}
+	return nil
+}
+
+func (s *State) Copy() *State {
+	c := NewState()
+	for a, acc := range s.accounts {
+		c.accounts[a] = acc
+	}
+	for a, contract := range s.contracts {
+		c.contracts[a] = contract.copy()
+	}
+	for symbol, tk := range s.tokens {
+		c.tokens[symbol] = tk
+		balances := make(map[string]uint64, len(s.tokenBalances[symbol]))
+		for a, amount := range s.tokenBalances[symbol] {
+			balances[a] = amount
+		}
+		c.tokenBalances[symbol] = balances
+	}
+	c.root = s.root
+	return c
+}
+
+// 按顺序应用一个区块的交易: 接收方增加余额,发送方减少余额并增加nonce
+// 挖矿奖励的发送方不记录状态;合约交易执行合约并扣除手续费;代币交易只修改代币余额
+// 携带数据的交易另外扣除数据的手续费
+func (s *State) Apply(transactions []*Transaction) {
+	for _, t := range transactions {
+		if fee := t.dataFee(); fee > 0 {
+			sender := s.accounts[t.senderBlockchainAddress]
+			sender.Balance -= fee
+			s.accounts[t.senderBlockchainAddress] = sender
+		}
+		if t.isContract() {
+			s.applyContract(t)
+			continue
+		}
+		if t.isToken() {
+			s.applyToken(t)
+			continue
+		}
+		recipient := s.accounts[t.recipientBlockchainAddress]
+		recipient.Balance += t.value
+		s.accounts[t.recipientBlockchainAddress] = recipient
+		if t.senderBlockchainAddress == MINING_SENDER {
+			continue
+		}
+		sender := s.accounts[t.senderBlockchainAddress]
+		sender.Balance -= t.value
+		sender.Nonce += 1
+		s.accounts[t.senderBlockchainAddress] = sender
+	}
+	s.root = nil
+}
+
+// 叶子的键: 地址的SHA-256
+func stateKey(blockChainAddress string) [32]byte {
+	return sha256.Sum256([]byte(blockChainAddress))
+}
+
+// 叶子哈希: SHA-256(0x00 || 键 || 余额(float32,大端) || nonce(大端)),空账户为全0
+// 合约账户再追加 字节码的SHA-256 || 存储根,contract为nil时表示普通账户
+func (acc Account) leafHash(key [32]byte, contract *[2][32]byte) [32]byte {
+	if acc == (Account{}) && contract == nil {
+		return [32]byte{}
+	}
+	b := make([]byte, 0, 1+32+4+8+2*32)
+	b = append(b, 0x00)
+	b = append(b, key[:]...)
+	b = binary.BigEndian.AppendUint32(b, math.Float32bits(acc.Balance))
+	b = binary.BigEndian.AppendUint64(b, acc.Nonce)
+	if contract != nil {
+		b = append(b, contract[0][:]...)
+		b = append(b, contract[1][:]...)
+	}
+	return sha256.Sum256(b)
+}
+
+// 合约的字节码哈希和存储根,不是合约时返回nil
+func (s *State) contractHashes(a string) *[2][32]byte {
+	c := s.contracts[a]
+	if c == nil {
+		return nil
+	}
+	return &[2][32]byte{sha256.Sum256(c.code), c.storageRoot()}
+}
+
+func (s *State) leaves() map[[32]byte][32]byte {
+	leaves := make(map[[32]byte][32]byte)
+	for a, acc := range s.accounts {
+		if acc == (Account{}) || s.contracts[a] != nil {
+			continue
+		}
+		key := stateKey(a)
+		leaves[key] = acc.leafHash(key, nil)
+	}
+	for a := range s.contracts {
+		key := stateKey(a)
+		leaves[key] = s.accounts[a].leafHash(key, s.contractHashes(a))
+	}
+	s.tokenLeaves(leaves)
+	return leaves
+}
+
+// 状态的稀疏默克尔树根
+func (s *State) Root() [32]byte {
+	if s.root == nil {
+		root := smtRoot(s.leaves())
+		s.root = &root
+	}
+	return *s.root
+}
+
+// 账户状态证明: 客户端用区块头中的state_root验证余额和nonce
+type StateProof struct {
+	Address   string   `json:"blockchain_address"`
+	Balance   float32  `json:"balance"`
+	Nonce     uint64   `json:"nonce"`
+	Height    int      `json:"height"`
+	BlockHash string   `json:"block_hash"`
+	StateRoot string   `json:"state_root"`
+	Bitmap    string   `json:"bitmap"`   //第i位(从最高位开始)为1表示深度i的兄弟节点非空
+	Siblings  []string `json:"siblings"` //非空的兄弟节点,从根到叶子
+
+	CodeHash    string `json:"code_hash,omitempty"` //合约账户的字节码哈希和存储根
+	StorageRoot string `json:"storage_root,omitempty"`
+}
+
+// 生成账户的状态证明,不存在的账户证明其余额和nonce为0
+func (s *State) Proof(blockChainAddress string) *StateProof {
+	acc := s.Account(blockChainAddress)
+	root := s.Root()
+	p := &StateProof{
+		Address:   blockChainAddress,
+		Balance:   acc.Balance,
+		Nonce:     acc.Nonce,
+		StateRoot: hex.EncodeToString(root[:]),
+		Siblings:  []string{},
+	}
+	if h := s.contractHashes(blockChainAddress); h != nil {
+		p.CodeHash = hex.EncodeToString(h[0][:])
+		p.StorageRoot = hex.EncodeToString(h[1][:])
+	}
+	var bitmap [SMT_DEPTH / 8]byte
+	siblings := smtSiblings(s.leaves(), stateKey(blockChainAddress))
+	for depth, h := range siblings {
+		if h == emptyHashes[SMT_DEPTH-depth-1] {
+			continue
+		}
+		bitmap[depth/8] |= 1 << (7 - uint(depth%8))
+		p.Siblings = append(p.Siblings, hex.EncodeToString(h[:]))
+	}
+	p.Bitmap = hex.EncodeToString(bitmap[:])
+	return p
+}
+
+// 验证证明中的余额和nonce属于根哈希为stateRoot的状态
+func (p *StateProof) Verify(stateRoot [32]byte) error {
+	bitmap, err := hex.DecodeString(p.Bitmap)
+	if err != nil || len(bitmap) != SMT_DEPTH/8 {
+		return fmt.Errorf("invalid bitmap")
+	}
+	var bits [32]byte
+	copy(bits[:], bitmap)
+	var siblings [SMT_DEPTH][32]byte
+	next := 0
+	for depth := 0; depth < SMT_DEPTH; depth++ {
+		if smtBit(bits, depth) == 0 {
+			siblings[depth] = emptyHashes[SMT_DEPTH-depth-1]
+			continue
+		}
+		if next >= len(p.Siblings) {
+			return fmt.Errorf("missing sibling at depth %d", depth)
+		}
+		h, err := decodeHash(p.Siblings[next])
+		if err != nil {
+			return fmt.Errorf("sibling %d: %v", next, err)
+		}
+		siblings[depth] = h
+		next += 1
+	}
+	if next != len(p.Siblings) {
+		return fmt.Errorf("unexpected %d extra siblings", len(p.Siblings)-next)
+	}
+	var contract *[2][32]byte
+	if p.CodeHash != "" {
+		contract = new([2][32]byte)
+		if contract[0], err = decodeHash(p.CodeHash); err != nil {
+			return fmt.Errorf("code_hash: %v", err)
+		}
+		if contract[1], err = decodeHash(p.StorageRoot); err != nil {
+			return fmt.Errorf("storage_root: %v", err)
+		}
+	}
+	key := stateKey(p.Address)
+	acc := Account{Balance: p.Balance, Nonce: p.Nonce}
+	if smtComputeRoot(key, acc.leafHash(key, contract), siblings) != stateRoot {
+		return fmt.Errorf("state proof of %s does not match state root %x", p.Address, stateRoot)
+	}
+	return nil
+}
+
+// 账户在指定高度的区块之后的状态证明,height为-1时使用主链末端
+func (bc *BlockChain) StateProof(blockChainAddress string, height int) (*StateProof, error) {
+	if err := address.Validate(blockChainAddress, bc.params); err != nil {
+		return nil, err
+	}
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	chain := bc.chain
+	if height == -1 {
+		height = len(chain) - 1
+	}
+	if height < 0 || height >= len(chain) {
+		return nil, fmt.Errorf("height %d out of range [0, %d]", height, len(chain)-1)
+	}
+	state := bc.state
+	if height != len(chain)-1 {
+		state = stateOf(chain[:height+1])
+	}
+	b := chain[height]
+	if state.Root() != b.stateRoot {
+		return nil, fmt.Errorf("state root mismatch at height %d", height)
+	}
+	p := state.Proof(blockChainAddress)
+	p.Height = height
+	hash := b.Hash()
+	p.BlockHash = hex.EncodeToString(hash[:])
+	return p, nil
+}
@@ -0,0 +1,140 @@
+package block
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 区块模板: 外部矿工只需要寻找nonce
+type BlockTemplate struct {
+	Height        int
+	PreviousHash  [32]byte
+	StateRoot     [32]byte       //应用这些交易后的账户状态根
+	Transactions  []*Transaction //交易池中的交易,最后一笔是挖矿奖励
+	Difficulty    int
+	CoinbaseValue float32
+	Timestamp     int64
+}
+
+// 用交易池中的交易组装候选区块,奖励发给rewardAddress
+func (bc *BlockChain) NewBlockTemplate(rewardAddress string) *BlockTemplate {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.newBlockTemplate(rewardAddress)
+}
+
+// 调用方需持有bc.mux
+func (bc *BlockChain) newBlockTemplate(rewardAddress string) *BlockTemplate {
+	transactions := bc.CopyTransactionPool()
+	transactions = append(transactions, newCoinbase(rewardAddress, len(bc.chain)))
+	state := bc.state.Copy()
+	state.Apply(transactions)
+	return &BlockTemplate{
+		Height:        len(bc.chain),
+		PreviousHash:  bc.LastBlock().Hash(),
+		StateRoot:     state.Root(),
+		Transactions:  transactions,
+		Difficulty:    bc.params.Difficulty,
+		CoinbaseValue: MINING_REWARD,
+		Timestamp:     nextBlockTime(bc.Now(), bc.chain),
+	}
+}
+
+// 哈希需要小于等于的目标值
+func (tmpl *BlockTemplate) Target() string {
+	return strings.Repeat("0", tmpl.Difficulty) + strings.Repeat("f", 64-tmpl.Difficulty)
+}
+
+func (tmpl *BlockTemplate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Height        int            `json:"height"`
+		PreviousHash  string         `json:"previous_hash"`
+		StateRoot     string         `json:"state_root"`
+		Timestamp     int64          `json:"timestamp"`
+		Difficulty    int            `json:"difficulty"`
+		Target        string         `json:"target"`
+		CoinbaseValue float32        `json:"coinbase_value"`
+		Transactions  []*Transaction `json:"transactions"`
+	}{
+		Height:        tmpl.Height,
+		PreviousHash:  fmt.Sprintf("%x", tmpl.PreviousHash),
+		StateRoot:     fmt.Sprintf("%x", tmpl.StateRoot),
+		Timestamp:     tmpl.Timestamp,
+		Difficulty:    tmpl.Difficulty,
+		Target:        tmpl.Target(),
+		CoinbaseValue: tmpl.CoinbaseValue,
+		Transactions:  tmpl.Transactions,
+	})
+}
+
+// 从0开始寻找满足难度的nonce,只读取候选区块,不需要持有bc.mux
+func (tmpl *BlockTemplate) ProofOfWork() int {
+	nonce := 0
+	for !MeetsDifficulty(nonce, tmpl.PreviousHash, tmpl.StateRoot, tmpl.Transactions, tmpl.Difficulty) {
+		nonce += 1
+	}
+	return nonce
+}
+
+// 用找到的nonce生成完整区块
+func (tmpl *BlockTemplate) Block(nonce int) *Block {
+	return &Block{
+		timestamp:    tmpl.Timestamp,
+		nonce:        nonce,
+		previousHash: tmpl.PreviousHash,
+		stateRoot:    tmpl.StateRoot,
+		transactions: tmpl.Transactions,
+	}
+}
+
+// 验证外部矿工提交的区块,有效时连接到主链末端并通知邻居节点
+func (bc *BlockChain) SubmitBlock(b *Block) error {
+	bc.mux.Lock()
+	state, err := bc.checkSubmittedBlock(b)
+	if err != nil {
+		bc.mux.Unlock()
+		return err
+	}
+	bc.connectBlock(b, state)
+	bc.mux.Unlock()
+	log.Println("action=submit_block, status=success")
+	bc.notifyNeighbors()
+	return nil
+}
+
+// 验证通过时返回应用该区块后的账户状态,调用方需持有bc.mux
+func (bc *BlockChain) checkSubmittedBlock(b *Block) (*State, error) {
+	if b.previousHash != bc.LastBlock().Hash() {
+		return nil, fmt.Errorf("stale block: previous hash %x is not the chain tip", b.previousHash)
+	}
+	return bc.validBlock(b, bc.chain, bc.state, true)
+}
+
+// 把区块连接到主链末端并更新账户状态,从交易池中移除已打包的交易,调用方需持有bc.mux
+func (bc *BlockChain) connectBlock(b *Block, state *State) {
+	bc.chain = append(bc.chain, b)
+	bc.state = state
+	bc.connectIndexes(len(bc.chain)-1, b)
+	if _, err := bc.index.AddBlock(b, bc.params.Difficulty, StatusValid, false); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+	included := make(map[string]bool)
+	for _, t := range b.transactions {
+		included[t.key()] = true
+	}
+	pool := make([]*Transaction, 0, len(bc.transactionPool))
+	for _, t := range bc.transactionPool {
+		if !included[t.key()] {
+			pool = append(pool, t)
+		}
+	}
+	bc.transactionPool = pool
+}
+
+// 用于在交易池中识别同一笔交易
+func (t *Transaction) key() string {
+	m, _ := t.MarshalJSON()
+	return string(m)
+}
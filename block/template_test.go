@@ -0,0 +1,107 @@
+package block
+
+import (
+	"GoProject/params"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 为区块寻找满足难度的nonce
+func solve(b *Block, difficulty int) *Block {
+	b.nonce = 0
+	for !MeetsDifficulty(b.nonce, b.previousHash, b.stateRoot, b.transactions, difficulty) {
+		b.nonce += 1
+	}
+	return b
+}
+
+func TestSubmitBlock(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice := newTestKey(t).address
+	tmpl := bc.NewBlockTemplate(alice)
+	last := tmpl.Transactions[len(tmpl.Transactions)-1]
+	if tmpl.Height != 1 || tmpl.PreviousHash != bc.LastBlock().Hash() || last.senderBlockchainAddress != MINING_SENDER ||
+		last.recipientBlockchainAddress != alice || tmpl.CoinbaseValue != MINING_REWARD {
+		t.Fatalf("template %+v", tmpl)
+	}
+	if err := bc.SubmitBlock(solve(tmpl.Block(0), tmpl.Difficulty)); err != nil {
+		t.Fatal(err)
+	}
+	if len(bc.Chain()) != 2 || bc.CalculateTotalAmount(alice) != MINING_REWARD {
+		t.Fatalf("block not connected")
+	}
+	//同一个模板的区块已经过期
+	if err := bc.SubmitBlock(solve(tmpl.Block(0), tmpl.Difficulty)); err == nil {
+		t.Fatal("stale block accepted")
+	}
+}
+
+func TestSubmitBlockRejected(t *testing.T) {
+	alice, bob, carol := newTestKey(t), newTestKey(t), newTestKey(t)
+	coinbase := func(value float32) *Transaction {
+		tx := newCoinbase(alice.address, 2)
+		tx.value = value
+		return tx
+	}
+	tests := []struct {
+		name         string
+		want         string
+		transactions func(t *testing.T) []*Transaction
+		mutate       func(b *Block)
+	}{
+		{"proof of work", "proof of work", nil, func(b *Block) {
+			for MeetsDifficulty(b.nonce, b.previousHash, b.stateRoot, b.transactions, params.RegTest.Difficulty) {
+				b.nonce += 1
+			}
+		}},
+		{"future timestamp", "future", nil, func(b *Block) {
+			b.timestamp = time.Now().Add(time.Second * (MAX_FUTURE_BLOCK_SEC + 60)).UnixNano()
+		}},
+		{"past timestamp", "median time past", nil, func(b *Block) {
+			b.timestamp = params.RegTest.GenesisTimestamp
+		}},
+		{"no coinbase", "exactly one coinbase", func(t *testing.T) []*Transaction { return []*Transaction{} }, nil},
+		{"two coinbases", "exactly one coinbase", func(t *testing.T) []*Transaction {
+			return []*Transaction{coinbase(MINING_REWARD), coinbase(MINING_REWARD)}
+		}, nil},
+		{"coinbase value", "coinbase value", func(t *testing.T) []*Transaction { return []*Transaction{coinbase(100)} }, nil},
+		{"overspend", "not enough balance", func(t *testing.T) []*Transaction {
+			tx := signTransaction(t, bob.privateKey, NewTransaction(bob.address, carol.address, 5), params.RegTest.ChainId)
+			return []*Transaction{tx, coinbase(MINING_REWARD)}
+		}, nil},
+		{"unsigned", "missing or invalid sender public key", func(t *testing.T) []*Transaction {
+			return []*Transaction{NewTransaction(alice.address, carol.address, 0.5), coinbase(MINING_REWARD)}
+		}, nil},
+		{"wrong key", "does not match address", func(t *testing.T) []*Transaction {
+			tx := signTransaction(t, bob.privateKey, NewTransaction(alice.address, carol.address, 0.5), params.RegTest.ChainId)
+			return []*Transaction{tx, coinbase(MINING_REWARD)}
+		}, nil},
+		{"bad signature", "invalid signature", func(t *testing.T) []*Transaction {
+			tx := signTransaction(t, alice.privateKey, NewTransaction(alice.address, carol.address, 0.5), params.MainNet.ChainId)
+			return []*Transaction{tx, coinbase(MINING_REWARD)}
+		}, nil},
+	}
+	for _, tt := range tests {
+		bc := NewBlockChain(testMiner, 0, params.RegTest)
+		bc.Generate(1, alice.address)
+		tmpl := bc.NewBlockTemplate(alice.address)
+		b := tmpl.Block(0)
+		if tt.transactions != nil {
+			b.transactions = tt.transactions(t)
+			state := bc.state.Copy()
+			state.Apply(b.transactions)
+			b.stateRoot = state.Root()
+		}
+		solve(b, tmpl.Difficulty)
+		if tt.mutate != nil {
+			tt.mutate(b)
+		}
+		if err := bc.SubmitBlock(b); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+		if len(bc.Chain()) != 2 {
+			t.Errorf("%s: chain changed", tt.name)
+		}
+	}
+}
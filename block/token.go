@@ -0,0 +1,269 @@
+package block
+
+import (
+	"GoProject/address"
+	"GoProject/utils"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 代币操作
+const (
+	TOKEN_CREATE   = "create"
+	TOKEN_TRANSFER = "transfer"
+
+	MAX_TOKEN_DECIMALS = 18
+)
+
+// 代币符号: 大写字母开头,2到10个大写字母或数字
+var tokenSymbolPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,9}$`)
+
+// 代币的发行信息,发行总量以最小单位计
+type Token struct {
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	Supply   uint64 `json:"supply"`
+	Issuer   string `json:"issuer"`
+}
+
+// 按小数位数显示数量,例如 FormatTokenAmount(150, 2) == "1.50"
+func FormatTokenAmount(amount uint64, decimals uint8) string {
+	s := strconv.FormatUint(amount, 10)
+	if decimals == 0 {
+		return s
+	}
+	d := int(decimals)
+	if len(s) <= d {
+		s = strings.Repeat("0", d-len(s)+1) + s
+	}
+	return s[:len(s)-d] + "." + s[len(s)-d:]
+}
+
+// 把带小数的数量转换为最小单位,小数位数不能超过decimals
+func ParseTokenAmount(s string, decimals uint8) (uint64, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > int(decimals) {
+		return 0, fmt.Errorf("amount %q has more than %d decimals", s, decimals)
+	}
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	if digits == "" || strings.ContainsAny(digits, "+-") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return n, nil
+}
+
+func (t *Transaction) isToken() bool {
+	return t.token != nil
+}
+
+// 代币交易的格式,金额为0,与账户状态无关的检查
+func (t *Transaction) checkToken() error {
+	op := t.token
+	if op == nil {
+		return nil
+	}
+	if t.senderBlockchainAddress == MINING_SENDER {
+		return fmt.Errorf("coinbase cannot be a token transaction")
+	}
+	if t.isContract() {
+		return fmt.Errorf("token transaction cannot call a contract")
+	}
+	if t.value != 0 {
+		return fmt.Errorf("token transaction must have value 0, got %v", t.value)
+	}
+	if !tokenSymbolPattern.MatchString(op.Symbol) {
+		return fmt.Errorf("invalid token symbol %q", op.Symbol)
+	}
+	if op.Amount == 0 {
+		return fmt.Errorf("invalid token amount 0")
+	}
+	switch op.Op {
+	case TOKEN_CREATE:
+		if op.Decimals > MAX_TOKEN_DECIMALS {
+			return fmt.Errorf("token decimals %d exceed %d", op.Decimals, MAX_TOKEN_DECIMALS)
+		}
+	case TOKEN_TRANSFER:
+		if op.Decimals != 0 {
+			return fmt.Errorf("decimals can only be set when creating a token")
+		}
+	default:
+		return fmt.Errorf("unknown token operation %q", op.Op)
+	}
+	return nil
+}
+
+// 检查代币交易能否应用到状态上: 创建时代币不存在,转账时发送方余额足够
+func (s *State) checkToken(t *Transaction) error {
+	op := t.token
+	if op == nil {
+		return nil
+	}
+	if op.Op == TOKEN_CREATE {
+		if s.tokens[op.Symbol] != nil {
+			return fmt.Errorf("token %s already exists", op.Symbol)
+		}
+		return nil
+	}
+	if s.tokens[op.Symbol] == nil {
+		return fmt.Errorf("unknown token %s", op.Symbol)
+	}
+	if s.tokenBalances[op.Symbol][t.senderBlockchainAddress] < op.Amount {
+		return fmt.Errorf("not enough %s in %s", op.Symbol, t.senderBlockchainAddress)
+	}
+	return nil
+}
+
+// 应用代币交易: 创建时发行总量归接收方,转账时从发送方转给接收方,发送方nonce加1
+// 不能应用的交易被忽略,区块验证时会拒绝包含它们的区块
+func (s *State) applyToken(t *Transaction) {
+	if s.checkToken(t) != nil {
+		return
+	}
+	op := t.token
+	if op.Op == TOKEN_CREATE {
+		s.tokens[op.Symbol] = &Token{Symbol: op.Symbol, Decimals: op.Decimals, Supply: op.Amount, Issuer: t.senderBlockchainAddress}
+		s.tokenBalances[op.Symbol] = make(map[string]uint64)
+	}
+	balances := s.tokenBalances[op.Symbol]
+	if op.Op == TOKEN_TRANSFER {
+		balances[t.senderBlockchainAddress] -= op.Amount
+		if balances[t.senderBlockchainAddress] == 0 {
+			delete(balances, t.senderBlockchainAddress)
+		}
+	}
+	balances[t.recipientBlockchainAddress] += op.Amount
+	sender := s.accounts[t.senderBlockchainAddress]
+	sender.Nonce += 1
+	s.accounts[t.senderBlockchainAddress] = sender
+}
+
+// 代币信息和余额在状态树中的键,地址不含"/",不会与账户的键冲突
+func tokenKey(symbol string) [32]byte {
+	return stateKey("token/" + symbol)
+}
+
+func tokenBalanceKey(symbol string, blockChainAddress string) [32]byte {
+	return stateKey("token/" + symbol + "/" + blockChainAddress)
+}
+
+// 代币信息的叶子哈希: SHA-256(0x02 || 键 || 符号 || 小数位数 || 发行总量 || 发行方)
+func (tk *Token) leafHash(key [32]byte) [32]byte {
+	b := []byte{0x02}
+	b = append(b, key[:]...)
+	b = append(b, byte(len(tk.Symbol)))
+	b = append(b, tk.Symbol...)
+	b = append(b, tk.Decimals)
+	b = binary.BigEndian.AppendUint64(b, tk.Supply)
+	b = append(b, byte(len(tk.Issuer)))
+	b = append(b, tk.Issuer...)
+	return sha256.Sum256(b)
+}
+
+// 代币余额的叶子哈希: SHA-256(0x03 || 键 || 数量(大端))
+func tokenBalanceLeaf(key [32]byte, amount uint64) [32]byte {
+	b := append([]byte{0x03}, key[:]...)
+	b = binary.BigEndian.AppendUint64(b, amount)
+	return sha256.Sum256(b)
+}
+
+// 把代币信息和余额加入状态树的叶子
+func (s *State) tokenLeaves(leaves map[[32]byte][32]byte) {
+	for symbol, tk := range s.tokens {
+		key := tokenKey(symbol)
+		leaves[key] = tk.leafHash(key)
+		for a, amount := range s.tokenBalances[symbol] {
+			key := tokenBalanceKey(symbol, a)
+			leaves[key] = tokenBalanceLeaf(key, amount)
+		}
+	}
+}
+
+// 验证代币交易并加入交易池
+func (bc *BlockChain) AcceptTokenTransaction(sender string, recipient string, nonce uint64, lockTime int64, data []byte,
+	op *utils.TokenOp, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) error {
+	if op == nil {
+		return fmt.Errorf("missing token operation")
+	}
+	t := NewTransaction(sender, recipient, 0)
+	t.nonce = nonce
+	t.lockTime = lockTime
+	t.data = data
+	t.token = op
+	t.senderPublicKey = senderPublicKey
+	t.signature = s
+	return bc.acceptTransaction(t)
+}
+
+// 主链末端的所有代币,按符号排序
+func (bc *BlockChain) Tokens() []*Token {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	tokens := make([]*Token, 0, len(bc.state.tokens))
+	for _, tk := range bc.state.tokens {
+		c := *tk
+		tokens = append(tokens, &c)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Symbol < tokens[j].Symbol })
+	return tokens
+}
+
+// 主链末端的代币,不存在时返回nil
+func (bc *BlockChain) Token(symbol string) *Token {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	tk := bc.state.tokens[symbol]
+	if tk == nil {
+		return nil
+	}
+	c := *tk
+	return &c
+}
+
+// 地址持有的一种代币
+type TokenBalance struct {
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	Amount   uint64 `json:"amount"`  //最小单位
+	Balance  string `json:"balance"` //按小数位数显示
+}
+
+// 地址在主链末端持有的代币,symbol不为空时只查询该代币(不存在时返回错误)
+func (bc *BlockChain) TokenBalances(blockChainAddress string, symbol string) ([]*TokenBalance, error) {
+	if err := address.Validate(blockChainAddress, bc.params); err != nil {
+		return nil, err
+	}
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if symbol != "" && bc.state.tokens[symbol] == nil {
+		return nil, fmt.Errorf("unknown token %s", symbol)
+	}
+	balances := []*TokenBalance{}
+	for sym, tk := range bc.state.tokens {
+		amount, ok := bc.state.tokenBalances[sym][blockChainAddress]
+		if symbol != "" {
+			if sym != symbol {
+				continue
+			}
+		} else if !ok {
+			continue
+		}
+		balances = append(balances, &TokenBalance{
+			Symbol:   sym,
+			Decimals: tk.Decimals,
+			Amount:   amount,
+			Balance:  FormatTokenAmount(amount, tk.Decimals),
+		})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Symbol < balances[j].Symbol })
+	return balances, nil
+}
@@ -0,0 +1,160 @@
+package block
+
+import (
+	"GoProject/params"
+	"GoProject/utils"
+	"strings"
+	"testing"
+)
+
+func TestTokenAmount(t *testing.T) {
+	tests := []struct {
+		s        string
+		decimals uint8
+		amount   uint64
+		format   string
+	}{
+		{"1.5", 2, 150, "1.50"},
+		{"0.01", 2, 1, "0.01"},
+		{"12", 0, 12, "12"},
+		{".5", 1, 5, "0.5"},
+		{"18446744073709551615", 0, 1<<64 - 1, "18446744073709551615"},
+	}
+	for _, tt := range tests {
+		n, err := ParseTokenAmount(tt.s, tt.decimals)
+		if err != nil || n != tt.amount {
+			t.Errorf("parse %q: %d, %v", tt.s, n, err)
+		}
+		if s := FormatTokenAmount(tt.amount, tt.decimals); s != tt.format {
+			t.Errorf("format %d: %q, want %q", tt.amount, s, tt.format)
+		}
+	}
+	for _, s := range []string{"1.234", "-1", "+1", "1e3", "18446744073709551616", "1.2.3"} {
+		if _, err := ParseTokenAmount(s, 2); err == nil {
+			t.Errorf("parse %q accepted", s)
+		}
+	}
+}
+
+// 使用发送方下一个nonce签名代币交易
+func tokenTransaction(t *testing.T, bc *BlockChain, k *testKey, recipient string, op *utils.TokenOp) *Transaction {
+	tx := NewTransaction(k.address, recipient, 0)
+	tx.nonce = bc.NextNonce(k.address)
+	tx.token = op
+	return signTransaction(t, k.privateKey, tx, params.RegTest.ChainId)
+}
+
+func acceptToken(bc *BlockChain, tx *Transaction) error {
+	return bc.AcceptTokenTransaction(tx.senderBlockchainAddress, tx.recipientBlockchainAddress, tx.nonce, tx.lockTime, tx.data, tx.token,
+		tx.senderPublicKey, tx.signature)
+}
+
+func TestTokenTransfer(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice, bob, carol := newTestKey(t), newTestKey(t), newTestKey(t)
+	create := tokenTransaction(t, bc, alice, alice.address, &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "GOLD", Decimals: 2, Amount: 1000})
+	if err := acceptToken(bc, create); err != nil {
+		t.Fatal(err)
+	}
+	//交易池中已有同名代币
+	again := tokenTransaction(t, bc, bob, bob.address, &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "GOLD", Amount: 1})
+	if err := acceptToken(bc, again); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("duplicate token: %v", err)
+	}
+	bc.Generate(1, "")
+	if tk := bc.Token("GOLD"); tk == nil || tk.Supply != 1000 || tk.Issuer != alice.address {
+		t.Fatalf("token %+v", tk)
+	}
+
+	transfer := func(from *testKey, to string, amount uint64) error {
+		return acceptToken(bc, tokenTransaction(t, bc, from, to, &utils.TokenOp{Op: TOKEN_TRANSFER, Symbol: "GOLD", Amount: amount}))
+	}
+	if err := transfer(alice, bob.address, 600); err != nil {
+		t.Fatal(err)
+	}
+	//交易池中的转账已经扣除,余下400
+	if err := transfer(alice, carol.address, 401); err == nil || !strings.Contains(err.Error(), "not enough GOLD") {
+		t.Errorf("overdraft against the pool: %v", err)
+	}
+	if err := transfer(alice, carol.address, 400); err != nil {
+		t.Fatal(err)
+	}
+	if err := transfer(newTestKey(t), carol.address, 1); err == nil {
+		t.Error("transfer without balance accepted")
+	}
+	if err := acceptToken(bc, tokenTransaction(t, bc, alice, bob.address, &utils.TokenOp{Op: TOKEN_TRANSFER, Symbol: "SILVER", Amount: 1})); err == nil {
+		t.Error("transfer of an unknown token accepted")
+	}
+	bc.Generate(1, "")
+
+	for _, want := range []struct {
+		k       *testKey
+		balance string
+	}{{bob, "6.00"}, {carol, "4.00"}} {
+		balances, err := bc.TokenBalances(want.k.address, "GOLD")
+		if err != nil || len(balances) != 1 || balances[0].Balance != want.balance {
+			t.Errorf("balances %v, err %v", balances, err)
+		}
+	}
+	//余额为0时不再列出
+	if balances, _ := bc.TokenBalances(alice.address, ""); len(balances) != 0 {
+		t.Errorf("alice balances %+v", balances)
+	}
+	if _, err := bc.TokenBalances(alice.address, "SILVER"); err == nil {
+		t.Error("balance of an unknown token")
+	}
+}
+
+// 区块中透支的代币转账使整个区块无效
+func TestTokenOverdraftBlock(t *testing.T) {
+	bc := NewBlockChain(testMiner, 0, params.RegTest)
+	alice, bob := newTestKey(t), newTestKey(t)
+	acceptToken(bc, tokenTransaction(t, bc, alice, alice.address, &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "GOLD", Amount: 10}))
+	bc.Generate(1, "")
+
+	tmpl := bc.NewBlockTemplate(testMiner)
+	b := tmpl.Block(0)
+	//第一笔进入交易池,使第二笔使用下一个nonce
+	first := tokenTransaction(t, bc, alice, bob.address, &utils.TokenOp{Op: TOKEN_TRANSFER, Symbol: "GOLD", Amount: 6})
+	if err := acceptToken(bc, first); err != nil {
+		t.Fatal(err)
+	}
+	second := tokenTransaction(t, bc, alice, bob.address, &utils.TokenOp{Op: TOKEN_TRANSFER, Symbol: "GOLD", Amount: 6})
+	b.transactions = append([]*Transaction{first, second}, b.transactions...)
+	state := bc.state.Copy()
+	state.Apply(b.transactions)
+	b.stateRoot = state.Root()
+	solve(b, tmpl.Difficulty)
+	if err := bc.SubmitBlock(b); err == nil || !strings.Contains(err.Error(), "not enough GOLD") {
+		t.Fatalf("overdraft block: %v", err)
+	}
+}
+
+func TestTokenFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		value float32
+		op    *utils.TokenOp
+	}{
+		{"lowercase symbol", 0, &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "gold", Amount: 1}},
+		{"symbol too long", 0, &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "ABCDEFGHIJK", Amount: 1}},
+		{"symbol too short", 0, &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "A", Amount: 1}},
+		{"zero amount", 0, &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "GOLD"}},
+		{"too many decimals", 0, &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "GOLD", Decimals: MAX_TOKEN_DECIMALS + 1, Amount: 1}},
+		{"decimals in transfer", 0, &utils.TokenOp{Op: TOKEN_TRANSFER, Symbol: "GOLD", Decimals: 2, Amount: 1}},
+		{"unknown operation", 0, &utils.TokenOp{Op: "burn", Symbol: "GOLD", Amount: 1}},
+		{"value", 1, &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "GOLD", Amount: 1}},
+	}
+	for _, tt := range tests {
+		tx := NewTransaction("alice", "bob", tt.value)
+		tx.token = tt.op
+		if err := tx.checkToken(); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+	tx := NewTransaction(MINING_SENDER, "bob", 0)
+	tx.token = &utils.TokenOp{Op: TOKEN_CREATE, Symbol: "GOLD", Amount: 1}
+	if err := tx.checkToken(); err == nil {
+		t.Error("coinbase token transaction accepted")
+	}
+}
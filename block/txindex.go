@@ -0,0 +1,133 @@
+package block
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// 交易ID: 签名内容的SHA-256,不包含签名和解锁数据,重新签名不会改变ID
+// 地址的版本字节已经区分网络,chain id固定为0
+// 同一笔交易在不同分支上打包时,索引中记录其所有位置
+func (t *Transaction) ID() [32]byte {
+	return t.signingPayload(0).Hash()
+}
+
+// 解析十六进制的交易ID
+func ParseTxID(s string) ([32]byte, error) {
+	return parseHash("transaction id", s)
+}
+
+// 解析十六进制的32字节哈希,kind用于错误信息
+func parseHash(kind string, s string) ([32]byte, error) {
+	var hash [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(hash) {
+		return hash, fmt.Errorf("invalid %s %q", kind, s)
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+// 交易在主链上的位置
+type TxLocation struct {
+	ID        string `json:"id"`
+	Height    int    `json:"height"`
+	BlockHash string `json:"block_hash"`
+	Index     int    `json:"index"` //在区块交易中的序号
+}
+
+// 带位置和确认数的交易,交易池中的交易高度为-1
+type TxInfo struct {
+	*TxLocation
+	Confirmations int          `json:"confirmations"`
+	Transaction   *Transaction `json:"transaction"`
+}
+
+// 交易涉及的地址: 发送方和接收方
+func (t *Transaction) addresses() []string {
+	if t.recipientBlockchainAddress == t.senderBlockchainAddress {
+		return []string{t.senderBlockchainAddress}
+	}
+	return []string{t.senderBlockchainAddress, t.recipientBlockchainAddress}
+}
+
+// 区块连接到主链时更新交易索引、地址索引和数据索引,调用方需持有bc.mux
+func (bc *BlockChain) connectIndexes(height int, b *Block) {
+	hash := b.Hash()
+	blockHash := hex.EncodeToString(hash[:])
+	for i, t := range b.transactions {
+		id := t.ID()
+		loc := &TxLocation{ID: hex.EncodeToString(id[:]), Height: height, BlockHash: blockHash, Index: i}
+		bc.txIndex[id] = append(bc.txIndex[id], loc)
+		for _, a := range t.addresses() {
+			bc.addressIndex[a] = append(bc.addressIndex[a], loc)
+		}
+	}
+	bc.indexData(height, b)
+}
+
+// 区块从主链末端断开时撤销其索引,必须按高度从高到低调用,调用方需持有bc.mux
+func (bc *BlockChain) disconnectIndexes(height int, b *Block) {
+	for i := len(b.transactions) - 1; i >= 0; i-- {
+		t := b.transactions[i]
+		id := t.ID()
+		bc.txIndex[id] = dropHeight(bc.txIndex[id], height)
+		if len(bc.txIndex[id]) == 0 {
+			delete(bc.txIndex, id)
+		}
+		for _, a := range t.addresses() {
+			bc.addressIndex[a] = dropHeight(bc.addressIndex[a], height)
+			if len(bc.addressIndex[a]) == 0 {
+				delete(bc.addressIndex, a)
+			}
+		}
+	}
+	bc.unindexData(height, b)
+}
+
+// 去掉末尾高度为height的位置;位置按高度递增追加,断开的区块总在末尾
+func dropHeight(locations []*TxLocation, height int) []*TxLocation {
+	n := len(locations)
+	for n > 0 && locations[n-1].Height == height {
+		n--
+	}
+	return locations[:n]
+}
+
+// 按ID查找交易,主链上找不到时查找交易池;ID相同的多笔交易返回最早的一笔
+func (bc *BlockChain) FindTransaction(id [32]byte) *TxInfo {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if locations := bc.txIndex[id]; len(locations) > 0 {
+		return bc.txInfo(locations[0])
+	}
+	for _, t := range bc.transactionPool {
+		if t.ID() == id {
+			return &TxInfo{
+				TxLocation:  &TxLocation{ID: hex.EncodeToString(id[:]), Height: -1, Index: -1},
+				Transaction: t,
+			}
+		}
+	}
+	return nil
+}
+
+// 地址在主链上的交易,从新到旧排列,跳过offset笔后最多返回limit笔,同时返回总数
+func (bc *BlockChain) AddressTransactions(blockChainAddress string, offset int, limit int) ([]*TxInfo, int) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	locations := bc.addressIndex[blockChainAddress]
+	infos := []*TxInfo{}
+	for i := len(locations) - 1 - offset; i >= 0 && len(infos) < limit; i-- {
+		infos = append(infos, bc.txInfo(locations[i]))
+	}
+	return infos, len(locations)
+}
+
+func (bc *BlockChain) txInfo(loc *TxLocation) *TxInfo {
+	return &TxInfo{
+		TxLocation:    loc,
+		Confirmations: len(bc.chain) - loc.Height,
+		Transaction:   bc.chain[loc.Height].transactions[loc.Index],
+	}
+}
@@ -1,24 +1,72 @@
 package main
 
 import (
+	"GoProject/address"
 	"GoProject/block"
+	"GoProject/discovery"
+	"GoProject/params"
+	"GoProject/script"
+	"GoProject/stratum"
 	"GoProject/utils"
+	"GoProject/vm"
 	wallet "GoProject/wallet"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
 )
 
+// 分页查询的默认和最大条数
+const (
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+)
+
 var cache map[string]*block.BlockChain = make(map[string]*block.BlockChain)
 
 type BlockChainServer struct {
-	port uint16
+	port       uint16
+	params     *params.Params          //所在网络
+	chainSpec  *block.ChainSpec        //检查点配置,可以为空
+	discoverer discovery.Discoverer    //节点发现方式,为空时扫描本机网段
+	registry   *discovery.PeerRegistry //作为引导节点时的节点注册表
+
+	minerAddress string           //挖矿奖励地址,为空时使用密钥库中的钱包
+	keystore     *wallet.KeyStore //保存节点钱包,为空时生成一个临时钱包
+	passphrase   string           //节点钱包的密码
+
+	pool *stratum.Server //外部矿工使用的矿池服务,未启用时为空
+}
+
+func NewBlockChainServer(port uint16, p *params.Params, chainSpec *block.ChainSpec, discoverer discovery.Discoverer, minerAddress string) *BlockChainServer {
+	return &BlockChainServer{port, p, chainSpec, discoverer, discovery.NewPeerRegistry(), minerAddress, nil, "", nil}
 }
 
-func NewBlockChainServer(port uint16) *BlockChainServer {
-	return &BlockChainServer{port}
+// 设置保存节点钱包的密钥库,需要在GetBlockChain之前调用
+func (bcs *BlockChainServer) SetKeyStore(ks *wallet.KeyStore, passphrase string) {
+	bcs.keystore = ks
+	bcs.passphrase = passphrase
+}
+
+// 节点钱包: 从密钥库加载,密钥库为空时创建并保存
+func (bcs *BlockChainServer) minerWallet() *wallet.Wallet {
+	if bcs.keystore == nil {
+		log.Println("WARNING: keystore is disabled, mining rewards go to a temporary wallet")
+		return wallet.NewWallet(bcs.params)
+	}
+	minersWallet, created, err := bcs.keystore.LoadOrCreate(elliptic.P256(), bcs.passphrase)
+	if err != nil {
+		log.Fatalf("ERROR: keystore %s: %v", bcs.keystore.Dir(), err)
+	}
+	if created {
+		log.Printf("created miner wallet in keystore %s", bcs.keystore.Dir())
+	}
+	return minersWallet
 }
 
 func (bcs *BlockChainServer) Port() uint16 {
@@ -29,14 +77,23 @@ func (bcs *BlockChainServer) Port() uint16 {
 func (bcs *BlockChainServer) GetBlockChain() *block.BlockChain {
 	bc, ok := cache["blockchain"]
 	if !ok {
-		//创建当前节点钱包
-		minersWallet := wallet.NewWallet()
+		minerAddress := bcs.minerAddress
+		if minerAddress == "" {
+			//没有配置奖励地址时使用当前节点钱包
+			minersWallet := bcs.minerWallet()
+			minerAddress = minersWallet.BlockChainAddress()
+			log.Printf("public_key %v", minersWallet.PublicKeyStr())
+		}
 		//使用当前钱包地址作为节点,加上端口创建区块链
-		bc = block.NewBlockChain(minersWallet.BlockChainAddress(), bcs.Port())
+		bc = block.NewBlockChain(minerAddress, bcs.Port(), bcs.params)
+		if err := bc.ApplyChainSpec(bcs.chainSpec); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if bcs.discoverer != nil {
+			bc.SetDiscoverer(bcs.discoverer)
+		}
 		cache["blockchain"] = bc
-		log.Printf("private_key %v", minersWallet.PrivateKeyStr())
-		log.Printf("public_key %v", minersWallet.PublicKeyStr())
-		log.Printf("blockchain_address %v", minersWallet.BlockChainAddress())
+		log.Printf("blockchain_address %v", minerAddress)
 	}
 	return bc
 }
@@ -46,6 +103,7 @@ func (bcs *BlockChainServer) GetChain(w http.ResponseWriter, req *http.Request)
 	switch req.Method {
 	case http.MethodGet:
 		w.Header().Add("Content-Type", "application/json")
+		w.Header().Set(block.NETWORK_MAGIC_HEADER, bcs.params.MagicString())
 		bc := bcs.GetBlockChain()
 		m, _ := bc.MarshalJSON()
 		io.WriteString(w, string(m[:]))
@@ -54,6 +112,120 @@ func (bcs *BlockChainServer) GetChain(w http.ResponseWriter, req *http.Request)
 	}
 }
 
+// 主链上指定高度的区块: /block/{height}
+func (bcs *BlockChainServer) Block(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		height, err := strconv.Atoi(req.PathValue("height"))
+		if err != nil || height < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid height")))
+			return
+		}
+		b := bcs.GetBlockChain().BlockByHeight(height)
+		if b == nil {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("block not found")))
+			return
+		}
+		m, _ := json.Marshal(b)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 按哈希查找区块: /block/hash/{hash},侧链区块的确认数为-1
+func (bcs *BlockChainServer) BlockByHash(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		hash, err := block.ParseBlockHash(req.PathValue("hash"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		b := bcs.GetBlockChain().BlockByHash(hash)
+		if b == nil {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("block not found")))
+			return
+		}
+		m, _ := json.Marshal(b)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 主链上一段高度的区块: /blocks?from=&to=,包含两端,最多MAX_PAGE_SIZE个
+// to省略时为主链末端,from省略时返回到to为止的最近DEFAULT_PAGE_SIZE个区块
+func (bcs *BlockChainServer) Blocks(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockChain()
+		q := req.URL.Query()
+		to := bc.Tip().Height
+		if t := q.Get("to"); t != "" {
+			var err error
+			if to, err = strconv.Atoi(t); err != nil || to < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("invalid to")))
+				return
+			}
+		}
+		from := max(to-DEFAULT_PAGE_SIZE+1, 0)
+		if f := q.Get("from"); f != "" {
+			var err error
+			if from, err = strconv.Atoi(f); err != nil || from < 0 || from > to {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("invalid from")))
+				return
+			}
+		}
+		if to-from+1 > MAX_PAGE_SIZE {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(fmt.Sprintf("at most %d blocks per request", MAX_PAGE_SIZE))))
+			return
+		}
+		blocks := bc.Blocks(from, to)
+		if len(blocks) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("block not found")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Blocks []*block.BlockInfo `json:"blocks"`
+			Length int                `json:"length"`
+		}{
+			Blocks: blocks,
+			Length: len(blocks),
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 主链末端的高度、哈希、时间戳和难度,不需要下载整条链
+func (bcs *BlockChainServer) Tip(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		m, _ := json.Marshal(bcs.GetBlockChain().Tip())
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
 func HelloWord(w http.ResponseWriter, req *http.Request) {
 	io.WriteString(w, "hello block chain")
 }
@@ -89,15 +261,48 @@ func (bcs *BlockChainServer) Transactions(w http.ResponseWriter, req *http.Reque
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		signature := utils.SignatureFromString(*t.Signature)
 		bc := bcs.GetBlockChain()
-		isCreated := bc.CreateTransaction(*t.SenderBlockChainAddress, *t.ReceiverBlockChainAddress, *t.Value, publicKey, signature)
+		var data []byte
+		if data, err = hex.DecodeString(t.Data); err != nil {
+			err = fmt.Errorf("invalid data: %v", err)
+		} else if t.LockScript != nil {
+			var lock, unlock script.Script
+			if lock, err = script.FromHex(*t.LockScript); err == nil {
+				unlock, err = script.FromHex(*t.UnlockScript)
+			}
+			if err == nil {
+				err = bc.AcceptScriptTransaction(*t.SenderBlockChainAddress, *t.ReceiverBlockChainAddress, *t.Value, t.Nonce, t.LockTime, data, lock, unlock)
+			}
+		} else if t.Token != nil {
+			publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
+			signature := utils.SignatureFromString(*t.Signature)
+			err = bc.AcceptTokenTransaction(*t.SenderBlockChainAddress, *t.ReceiverBlockChainAddress, t.Nonce, t.LockTime, data,
+				t.Token, publicKey, signature)
+		} else if t.GasLimit > 0 {
+			var code, input []byte
+			if code, err = hex.DecodeString(t.Code); err == nil {
+				input, err = hex.DecodeString(t.Input)
+			}
+			if err == nil {
+				publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
+				signature := utils.SignatureFromString(*t.Signature)
+				err = bc.AcceptContractTransaction(*t.SenderBlockChainAddress, *t.ReceiverBlockChainAddress, t.Nonce, t.LockTime, data,
+					code, input, t.GasLimit, publicKey, signature)
+			}
+		} else if t.Multisig != nil {
+			signatures := utils.SignaturesFromStrings(t.Signatures)
+			err = bc.AcceptMultisigTransaction(*t.SenderBlockChainAddress, *t.ReceiverBlockChainAddress, *t.Value, t.Nonce, t.LockTime, data, t.Multisig, signatures)
+		} else {
+			publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
+			signature := utils.SignatureFromString(*t.Signature)
+			err = bc.AcceptTransaction(*t.SenderBlockChainAddress, *t.ReceiverBlockChainAddress, *t.Value, t.Nonce, t.LockTime, data, publicKey, signature)
+		}
 		w.Header().Add("Content-Type", "application/json")
 		var m []byte
-		if !isCreated {
+		if err != nil {
+			log.Printf("ERROR: %v", err)
 			w.WriteHeader(http.StatusBadRequest)
-			m = utils.JsonStatus("fail")
+			m = utils.JsonStatus(err.Error())
 		} else {
 			w.WriteHeader(http.StatusOK)
 			m = utils.JsonStatus("success")
@@ -109,16 +314,62 @@ func (bcs *BlockChainServer) Transactions(w http.ResponseWriter, req *http.Reque
 	}
 }
 
-// 开始挖矿
+// 开始挖矿,可以通过interval和address参数修改挖矿间隔和奖励地址
+// 已经在挖矿时只更新配置,不会启动第二个挖矿循环
 func (bcs *BlockChainServer) StartMine(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
-	case http.MethodGet:
+	case http.MethodGet, http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
 		bc := bcs.GetBlockChain()
+		if interval := req.URL.Query().Get("interval"); interval != "" {
+			sec, err := strconv.Atoi(interval)
+			if err == nil {
+				err = bc.SetMiningInterval(sec)
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("invalid interval")))
+				return
+			}
+		}
+		if rewardAddress := req.URL.Query().Get("address"); rewardAddress != "" {
+			if err := bc.SetRewardAddress(rewardAddress); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus(err.Error())))
+				return
+			}
+		}
 		bc.StartMining()
-		m := utils.JsonStatus("success")
+		m, _ := json.Marshal(bc.MiningStatus())
+		io.WriteString(w, string(m))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 停止挖矿
+func (bcs *BlockChainServer) StopMine(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet, http.MethodPost:
+		bc := bcs.GetBlockChain()
+		bc.StopMining()
+		m, _ := json.Marshal(bc.MiningStatus())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 查看挖矿状态和统计
+func (bcs *BlockChainServer) MineStatus(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.GetBlockChain().MiningStatus())
 		w.Header().Add("Content-Type", "application/json")
 		io.WriteString(w, string(m))
-	case http.MethodPost:
 	default:
 		log.Println("ERROR: Invalid HTTP Method")
 		w.WriteHeader(http.StatusBadRequest)
@@ -142,9 +393,39 @@ func (bcs *BlockChainServer) Amount(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// 发送方下一笔交易应签名的nonce,包含交易池中的交易: /nonce?blockchain_address=
+func (bcs *BlockChainServer) Nonce(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		blockChainAddress := req.URL.Query().Get("blockchain_address")
+		if err := address.Validate(blockChainAddress, bcs.params); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			BlockChainAddress string `json:"blockchain_address"`
+			Nonce             uint64 `json:"nonce"`
+		}{
+			BlockChainAddress: blockChainAddress,
+			Nonce:             bcs.GetBlockChain().NextNonce(blockChainAddress),
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
 func (bcs *BlockChainServer) Consensus(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPut:
+		if req.Header.Get(block.NETWORK_MAGIC_HEADER) != bcs.params.MagicString() {
+			log.Println("ERROR: consensus request from another network")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 		bc := bcs.GetBlockChain()
 		resolved := bc.ResolveConflicts()
 
@@ -160,12 +441,492 @@ func (bcs *BlockChainServer) Consensus(w http.ResponseWriter, req *http.Request)
 	}
 }
 
+// 查找赎回哈希时间锁合约时公开的原像
+func (bcs *BlockChainServer) Preimage(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		b, err := hex.DecodeString(req.URL.Query().Get("hash"))
+		if err != nil || len(b) != sha256.Size {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid hash")))
+			return
+		}
+		var hash [32]byte
+		copy(hash[:], b)
+		r := bcs.GetBlockChain().FindPreimage(hash)
+		if r == nil {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("preimage not revealed")))
+			return
+		}
+		m, _ := json.Marshal(r)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 账户余额和nonce的状态证明: /state/proof?blockchain_address=&height=
+// height省略时使用主链末端,客户端用该高度区块头中的state_root验证
+func (bcs *BlockChainServer) StateProof(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		height := -1
+		if h := req.URL.Query().Get("height"); h != "" {
+			var err error
+			if height, err = strconv.Atoi(h); err != nil || height < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("invalid height")))
+				return
+			}
+		}
+		p, err := bcs.GetBlockChain().StateProof(req.URL.Query().Get("blockchain_address"), height)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(p)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 查找携带数据的主链交易: /data?hex= 或 /data?text=
+func (bcs *BlockChainServer) FindData(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		q := req.URL.Query()
+		data := []byte(q.Get("text"))
+		if q.Has("hex") {
+			var err error
+			if data, err = hex.DecodeString(q.Get("hex")); err != nil {
+				data = nil
+			}
+		}
+		if len(data) == 0 || len(data) > block.MAX_DATA_SIZE {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid data")))
+			return
+		}
+		locations := bcs.GetBlockChain().FindData(data)
+		if len(locations) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("data not found")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Data         string                `json:"data"`
+			Transactions []*block.DataLocation `json:"transactions"`
+			Length       int                   `json:"length"`
+		}{
+			Data:         hex.EncodeToString(data),
+			Transactions: locations,
+			Length:       len(locations),
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 按ID查找交易: /tx/{id},尚未打包的交易高度为-1
+func (bcs *BlockChainServer) Transaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		id, err := block.ParseTxID(req.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		t := bcs.GetBlockChain().FindTransaction(id)
+		if t == nil {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("transaction not found")))
+			return
+		}
+		m, _ := json.Marshal(t)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 地址的交易历史,从新到旧分页: /address/{addr}/transactions?offset=&limit=
+func (bcs *BlockChainServer) AddressTransactions(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockChain()
+		addr := req.PathValue("addr")
+		if err := address.Validate(addr, bc.Params()); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		q := req.URL.Query()
+		offset, limit := 0, DEFAULT_PAGE_SIZE
+		if o := q.Get("offset"); o != "" {
+			var err error
+			if offset, err = strconv.Atoi(o); err != nil || offset < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("invalid offset")))
+				return
+			}
+		}
+		if l := q.Get("limit"); l != "" {
+			var err error
+			if limit, err = strconv.Atoi(l); err != nil || limit <= 0 || limit > MAX_PAGE_SIZE {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus(fmt.Sprintf("limit must be 1-%d", MAX_PAGE_SIZE))))
+				return
+			}
+		}
+		transactions, total := bc.AddressTransactions(addr, offset, limit)
+		m, _ := json.Marshal(struct {
+			Address      string          `json:"blockchain_address"`
+			Transactions []*block.TxInfo `json:"transactions"`
+			Offset       int             `json:"offset"`
+			Limit        int             `json:"limit"`
+			Total        int             `json:"total"`
+		}{
+			Address:      addr,
+			Transactions: transactions,
+			Offset:       offset,
+			Limit:        limit,
+			Total:        total,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 列出所有代币,给出symbol时只查看该代币: /tokens?symbol=
+func (bcs *BlockChainServer) Tokens(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockChain()
+		if symbol := req.URL.Query().Get("symbol"); symbol != "" {
+			tk := bc.Token(symbol)
+			if tk == nil {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, string(utils.JsonStatus("token not found")))
+				return
+			}
+			m, _ := json.Marshal(tk)
+			io.WriteString(w, string(m[:]))
+			return
+		}
+		tokens := bc.Tokens()
+		m, _ := json.Marshal(struct {
+			Tokens []*block.Token `json:"tokens"`
+			Length int            `json:"length"`
+		}{
+			Tokens: tokens,
+			Length: len(tokens),
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 地址持有的代币: /tokens/balance?blockchain_address=&symbol=,symbol省略时列出所有持有的代币
+func (bcs *BlockChainServer) TokenBalances(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		q := req.URL.Query()
+		bc := bcs.GetBlockChain()
+		symbol := q.Get("symbol")
+		if symbol != "" && bc.Token(symbol) == nil {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("token not found")))
+			return
+		}
+		balances, err := bc.TokenBalances(q.Get("blockchain_address"), symbol)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Address  string                `json:"blockchain_address"`
+			Balances []*block.TokenBalance `json:"balances"`
+		}{
+			Address:  q.Get("blockchain_address"),
+			Balances: balances,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 查看主链末端的合约: /contract?address=
+func (bcs *BlockChainServer) Contract(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		c := bcs.GetBlockChain().Contract(req.URL.Query().Get("address"))
+		if c == nil {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("contract not found")))
+			return
+		}
+		m, _ := json.Marshal(c)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 只读调用合约,不产生交易: /contract/call?address=&caller=&arg=...&gas=
+// arg可以重复,格式同汇编中的值(数字、0x十六进制或"字符串")
+func (bcs *BlockChainServer) CallContract(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		q := req.URL.Query()
+		gas := bcs.params.BlockGasLimit
+		if g := q.Get("gas"); g != "" {
+			var err error
+			if gas, err = strconv.ParseUint(g, 10, 64); err != nil || gas == 0 || gas > bcs.params.BlockGasLimit {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus(fmt.Sprintf("gas must be between 1 and %d", bcs.params.BlockGasLimit))))
+				return
+			}
+		}
+		var args [][]byte
+		for _, a := range q["arg"] {
+			b, err := vm.ParseArg(a)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus(err.Error())))
+				return
+			}
+			args = append(args, b)
+		}
+		r, err := bcs.GetBlockChain().CallContract(q.Get("address"), q.Get("caller"), args, gas)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(r)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 查看区块树中的所有分支
+func (bcs *BlockChainServer) Forks(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		forks := bcs.GetBlockChain().Forks()
+		m, _ := json.Marshal(struct {
+			Forks  []*block.ForkInfo `json:"forks"`
+			Length int               `json:"length"`
+		}{
+			Forks:  forks,
+			Length: len(forks),
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 回归测试网: 立即挖出指定数量的区块
+func (bcs *BlockChainServer) Generate(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		n, err := strconv.Atoi(req.URL.Query().Get("blocks"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid blocks")))
+			return
+		}
+		bc := bcs.GetBlockChain()
+		blocks, err := bc.Generate(n, req.URL.Query().Get("address"))
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		hashes := make([]string, 0, len(blocks))
+		for _, b := range blocks {
+			hashes = append(hashes, fmt.Sprintf("%x", b.Hash()))
+		}
+		m, _ := json.Marshal(struct {
+			Blocks []string `json:"blocks"`
+			Height int      `json:"height"`
+		}{
+			Blocks: hashes,
+			Height: len(bc.Chain()) - 1,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 回归测试网: 设置模拟时间,timestamp为0时恢复系统时间
+func (bcs *BlockChainServer) SetMockTime(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		timestamp, err := strconv.ParseInt(req.URL.Query().Get("timestamp"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid timestamp")))
+			return
+		}
+		if err := bcs.GetBlockChain().SetMockTime(timestamp); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 外部矿工获取区块模板,address参数指定挖矿奖励地址
+func (bcs *BlockChainServer) BlockTemplate(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockChain()
+		w.Header().Add("Content-Type", "application/json")
+		rewardAddress := req.URL.Query().Get("address")
+		if rewardAddress == "" {
+			rewardAddress = bc.RewardAddress()
+		} else if err := address.Validate(rewardAddress, bcs.params); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := bc.NewBlockTemplate(rewardAddress).MarshalJSON()
+		io.WriteString(w, string(m[:]))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 外部矿工提交已找到nonce的区块
+func (bcs *BlockChainServer) SubmitBlock(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var b block.Block
+		if err := json.NewDecoder(req.Body).Decode(&b); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid block")))
+			return
+		}
+		if err := bcs.GetBlockChain().SubmitBlock(&b); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Message string `json:"message"`
+			Hash    string `json:"hash"`
+		}{
+			Message: "success",
+			Hash:    fmt.Sprintf("%x", b.Hash()),
+		})
+		io.WriteString(w, string(m))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// 启动Stratum矿池服务
+func (bcs *BlockChainServer) StartStratum(address string, shareDifficulty int) {
+	bcs.pool = stratum.NewServer(bcs.GetBlockChain(), address, shareDifficulty)
+	go func() {
+		log.Fatal(bcs.pool.ListenAndServe())
+	}()
+}
+
+// 查看矿池统计
+func (bcs *BlockChainServer) PoolStats(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		if bcs.pool == nil {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("stratum is not enabled")))
+			return
+		}
+		m, _ := json.Marshal(bcs.pool.Stats())
+		io.WriteString(w, string(m))
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
 func (bsc *BlockChainServer) Run() {
 	bsc.GetBlockChain().Run()
 	http.HandleFunc("/", bsc.GetChain)
+	http.HandleFunc("/block/{height}", bsc.Block)
+	http.HandleFunc("/block/hash/{hash}", bsc.BlockByHash)
+	http.HandleFunc("/blocks", bsc.Blocks)
+	http.HandleFunc("/tip", bsc.Tip)
 	http.HandleFunc("/transactions", bsc.Transactions)
 	http.HandleFunc("/mine/start", bsc.StartMine)
+	http.HandleFunc("/mine/stop", bsc.StopMine)
+	http.HandleFunc("/mine/status", bsc.MineStatus)
+	http.HandleFunc("/pool/stats", bsc.PoolStats)
+	http.HandleFunc("/blocktemplate", bsc.BlockTemplate)
+	http.HandleFunc("/submitblock", bsc.SubmitBlock)
 	http.HandleFunc("/amount", bsc.Amount)
+	http.HandleFunc("/nonce", bsc.Nonce)
 	http.HandleFunc("/consensus", bsc.Consensus)
+	http.HandleFunc("/forks", bsc.Forks)
+	http.HandleFunc("/htlc/preimage", bsc.Preimage)
+	http.HandleFunc("/state/proof", bsc.StateProof)
+	http.HandleFunc("/contract", bsc.Contract)
+	http.HandleFunc("/contract/call", bsc.CallContract)
+	http.HandleFunc("/tokens", bsc.Tokens)
+	http.HandleFunc("/tokens/balance", bsc.TokenBalances)
+	http.HandleFunc("/data", bsc.FindData)
+	http.HandleFunc("/tx/{id}", bsc.Transaction)
+	http.HandleFunc("/address/{addr}/transactions", bsc.AddressTransactions)
+	http.HandleFunc("/peers", bsc.registry.Handler)
+	http.HandleFunc("/generate", bsc.Generate)
+	http.HandleFunc("/setmocktime", bsc.SetMockTime)
 	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(bsc.Port())), nil))
 }
@@ -1,19 +1,119 @@
 package main
 
 import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/discovery"
+	"GoProject/params"
+	"GoProject/utils"
+	"GoProject/wallet"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
+// 节点钱包密码的环境变量,避免出现在命令行参数中
+const KEYSTORE_PASSPHRASE_ENV = "KEYSTORE_PASSPHRASE"
+
 func init() {
 	log.SetPrefix("Blockchain: ")
 
 }
 
+// 根据命令行参数组合节点发现方式
+func newDiscoverer(p *params.Params, methods string, port uint16, advertise string, seeds string, rendezvous string, broadcast string) discovery.Discoverer {
+	discoverers := make([]discovery.Discoverer, 0)
+	for _, m := range strings.Split(methods, ",") {
+		switch strings.TrimSpace(m) {
+		case "scan":
+			discoverers = append(discoverers, discovery.NewScanDiscoverer(
+				utils.GetHost(), port,
+				block.NEIGHBOR_IP_RANGE_START, block.NEIGHBOR_IP_RANGE_END,
+				p.PortRangeStart, p.PortRangeEnd))
+		case "seed":
+			discoverers = append(discoverers, discovery.NewSeedDiscoverer(seeds, p.DefaultPort))
+		case "rendezvous":
+			discoverers = append(discoverers, discovery.NewRendezvousDiscoverer(rendezvous, advertise))
+		case "broadcast":
+			bd := discovery.NewBroadcastDiscoverer(broadcast, advertise)
+			go func() {
+				if err := bd.Serve(); err != nil {
+					log.Printf("ERROR: broadcast discovery: %v", err)
+				}
+			}()
+			discoverers = append(discoverers, bd)
+		case "":
+		default:
+			log.Fatalf("ERROR: unknown discovery method %q", m)
+		}
+	}
+	return discovery.NewMultiDiscoverer(advertise, discoverers...)
+}
+
 func main() {
-	port := flag.Uint("port", 5000, "TCP port number for Blockchain Server")
+	network := flag.String("network", "mainnet", "Network: mainnet, testnet or regtest")
+	port := flag.Uint("port", 0, "TCP port number for Blockchain Server (default from network)")
+	chainSpecPath := flag.String("chainspec", "", "Chain spec file with checkpoints and assume-valid block")
+	methods := flag.String("discovery", "scan", "Peer discovery methods: scan,seed,rendezvous,broadcast")
+	advertise := flag.String("advertise", "", "Address announced to other peers (default host:port)")
+	seeds := flag.String("seeds", "seeds.txt", "Seed file with one host[:port] per line")
+	rendezvous := flag.String("rendezvous", "", "Bootstrap node serving /peers (default 127.0.0.1 on the network port)")
+	broadcast := flag.String("broadcast", "", "UDP broadcast address for LAN discovery")
+	minerAddress := flag.String("miner-address", "", "Address receiving mining rewards (default the keystore wallet)")
+	keystoreDir := flag.String("keystore", "keystore/miner", "Directory of the encrypted miner key, passphrase from $"+KEYSTORE_PASSPHRASE_ENV+" (disabled when empty)")
+	miningInterval := flag.Int("mining-interval", 0, "Seconds between mined blocks (default from network)")
+	stratumAddress := flag.String("stratum", "", "Listen address of the Stratum mining pool, e.g. :3333 (disabled when empty)")
+	shareDifficulty := flag.Int("share-difficulty", 0, "Share difficulty for pool miners, at most the block difficulty (default block difficulty - 1)")
 	flag.Parse()
-	server := NewBlockChainServer(uint16(*port))
+	p, err := params.ByName(*network)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if *port == 0 {
+		*port = uint(p.DefaultPort)
+	}
+	if *rendezvous == "" {
+		*rendezvous = fmt.Sprintf("127.0.0.1:%d", p.DefaultPort)
+	}
+	var chainSpec *block.ChainSpec
+	if *chainSpecPath != "" {
+		spec, err := block.LoadChainSpec(*chainSpecPath)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		chainSpec = spec
+	}
+	if *advertise == "" {
+		*advertise = fmt.Sprintf("%s:%d", utils.GetHost(), *port)
+	}
+	discoverer := newDiscoverer(p, *methods, uint16(*port), *advertise, *seeds, *rendezvous, *broadcast)
+	if *minerAddress != "" {
+		if err := address.Validate(*minerAddress, p); err != nil {
+			log.Fatalf("ERROR: -miner-address: %v", err)
+		}
+	}
+	server := NewBlockChainServer(uint16(*port), p, chainSpec, discoverer, *minerAddress)
+	if *keystoreDir != "" && *minerAddress == "" {
+		//空口令加密的私钥等于明文保存
+		passphrase := os.Getenv(KEYSTORE_PASSPHRASE_ENV)
+		if passphrase == "" {
+			log.Fatalf("ERROR: -keystore requires $%s, or pass -keystore= or -miner-address", KEYSTORE_PASSPHRASE_ENV)
+		}
+		ks, err := wallet.NewKeyStore(filepath.Join(*keystoreDir, p.Name), p)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		server.SetKeyStore(ks, passphrase)
+	}
+	if err := server.GetBlockChain().SetMiningInterval(*miningInterval); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if *stratumAddress != "" {
+		server.StartStratum(*stratumAddress, *shareDifficulty)
+	}
 	server.Run()
 
 }
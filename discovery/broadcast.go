@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	BROADCAST_PORT     = 5999 //局域网发现使用的UDP端口
+	BROADCAST_WAIT_SEC = 2    //发出探测后等待应答的时间
+
+	broadcastProbe = "GOBLOCKCHAIN DISCOVER"
+	broadcastReply = "GOBLOCKCHAIN PEER "
+)
+
+// 通过UDP广播在局域网内发现节点
+// 每个节点用Serve应答探测,FindPeers发出探测并收集应答
+// 同一台主机上只能有一个节点监听广播端口
+type BroadcastDiscoverer struct {
+	broadcastAddr string //探测目标,默认255.255.255.255:5999,测试时可以使用127.0.0.1
+	advertise     string //应答中的本节点地址,host为空时由对方使用UDP来源IP
+	wait          time.Duration
+}
+
+func NewBroadcastDiscoverer(broadcastAddr string, advertise string) *BroadcastDiscoverer {
+	if broadcastAddr == "" {
+		broadcastAddr = fmt.Sprintf("255.255.255.255:%d", BROADCAST_PORT)
+	}
+	return &BroadcastDiscoverer{broadcastAddr, advertise, time.Second * BROADCAST_WAIT_SEC}
+}
+
+// 监听广播端口并应答探测,会一直阻塞
+func (d *BroadcastDiscoverer) Serve() error {
+	_, port, err := net.SplitHostPort(d.broadcastAddr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenPacket("udp4", ":"+port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	buf := make([]byte, 512)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if string(buf[:n]) != broadcastProbe {
+			continue
+		}
+		if _, err := conn.WriteTo([]byte(broadcastReply+d.advertise), from); err != nil {
+			log.Printf("ERROR: broadcast reply: %v", err)
+		}
+	}
+}
+
+func (d *BroadcastDiscoverer) FindPeers() ([]string, error) {
+	target, err := net.ResolveUDPAddr("udp4", d.broadcastAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP([]byte(broadcastProbe), target); err != nil {
+		return nil, err
+	}
+
+	peers := make([]string, 0)
+	buf := make([]byte, 512)
+	_ = conn.SetReadDeadline(time.Now().Add(d.wait))
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			//超时后返回已收集的节点
+			break
+		}
+		msg := string(buf[:n])
+		if !strings.HasPrefix(msg, broadcastReply) {
+			continue
+		}
+		host, port, err := net.SplitHostPort(strings.TrimPrefix(msg, broadcastReply))
+		if err != nil {
+			continue
+		}
+		if host == "" || host == "0.0.0.0" {
+			host = from.IP.String()
+		}
+		peer := net.JoinHostPort(host, port)
+		if peer != d.advertise {
+			peers = append(peers, peer)
+		}
+	}
+	return peers, nil
+}
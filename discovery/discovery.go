@@ -0,0 +1,63 @@
+package discovery
+
+import (
+	"GoProject/utils"
+	"log"
+	"sort"
+)
+
+// 节点发现接口,返回可连接的邻居节点地址(host:port)
+type Discoverer interface {
+	FindPeers() ([]string, error)
+}
+
+// 在本机网段内按IP和端口范围扫描(原FindNeighbors的实现)
+type ScanDiscoverer struct {
+	host      string
+	port      uint16
+	startIp   uint8
+	endIp     uint8
+	startPort uint16
+	endPort   uint16
+}
+
+func NewScanDiscoverer(host string, port uint16, startIp uint8, endIp uint8, startPort uint16, endPort uint16) *ScanDiscoverer {
+	return &ScanDiscoverer{host, port, startIp, endIp, startPort, endPort}
+}
+
+func (d *ScanDiscoverer) FindPeers() ([]string, error) {
+	return utils.FindNeighbors(d.host, d.port, d.startIp, d.endIp, d.startPort, d.endPort), nil
+}
+
+// 组合多个发现方式,结果去重并排除自身地址
+type MultiDiscoverer struct {
+	self        string
+	discoverers []Discoverer
+}
+
+func NewMultiDiscoverer(self string, discoverers ...Discoverer) *MultiDiscoverer {
+	return &MultiDiscoverer{self, discoverers}
+}
+
+func (d *MultiDiscoverer) FindPeers() ([]string, error) {
+	found := make(map[string]bool)
+	for _, ds := range d.discoverers {
+		peers, err := ds.FindPeers()
+		//单个发现方式失败不影响其他方式
+		if err != nil {
+			log.Printf("ERROR: discovery: %v", err)
+			continue
+		}
+		for _, p := range peers {
+			if p != d.self {
+				found[p] = true
+			}
+		}
+	}
+	peers := make([]string, 0, len(found))
+	for p := range found {
+		peers = append(peers, p)
+	}
+	sort.Strings(peers)
+	return peers, nil
+}
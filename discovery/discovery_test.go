@@ -0,0 +1,153 @@
+package discovery
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticDiscoverer struct {
+	peers []string
+	err   error
+}
+
+func (d *staticDiscoverer) FindPeers() ([]string, error) {
+	return d.peers, d.err
+}
+
+func TestMultiDiscoverer(t *testing.T) {
+	d := NewMultiDiscoverer("10.0.0.1:5000",
+		&staticDiscoverer{peers: []string{"10.0.0.3:5000", "10.0.0.1:5000"}},
+		&staticDiscoverer{err: errors.New("unreachable")},
+		&staticDiscoverer{peers: []string{"10.0.0.2:5000", "10.0.0.3:5000"}},
+	)
+	peers, err := d.FindPeers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.2:5000", "10.0.0.3:5000"}
+	if !reflect.DeepEqual(peers, want) {
+		t.Fatalf("peers %v, want %v", peers, want)
+	}
+}
+
+func TestSeedDiscoverer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seeds.txt")
+	seeds := strings.Join([]string{
+		"# seed nodes",
+		"",
+		"10.0.0.1:6000",
+		"10.0.0.2",
+		"  seed.example  ",
+		"missing.example:7000",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(seeds), 0600); err != nil {
+		t.Fatal(err)
+	}
+	d := NewSeedDiscoverer(path, 5000)
+	//本地替代DNS解析
+	d.Resolver = func(host string) ([]string, error) {
+		if host == "seed.example" {
+			return []string{"10.0.1.1", "10.0.1.2"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	peers, err := d.FindPeers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1:6000", "10.0.0.2:5000", "10.0.1.1:5000", "10.0.1.2:5000"}
+	if !reflect.DeepEqual(peers, want) {
+		t.Fatalf("peers %v, want %v", peers, want)
+	}
+	if _, err := NewSeedDiscoverer(filepath.Join(t.TempDir(), "missing"), 5000).FindPeers(); err == nil {
+		t.Fatal("missing seed file accepted")
+	}
+}
+
+func TestRendezvous(t *testing.T) {
+	registry := NewPeerRegistry()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/peers", registry.Handler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	server := strings.TrimPrefix(srv.URL, "http://")
+
+	a := NewRendezvousDiscoverer(server, "10.0.0.1:5000")
+	b := NewRendezvousDiscoverer(server, "10.0.0.2:5000")
+	if _, err := a.FindPeers(); err != nil {
+		t.Fatal(err)
+	}
+	peers, err := b.FindPeers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//引导节点本身也是邻居,结果不包含自身
+	if want := []string{server, "10.0.0.1:5000"}; !reflect.DeepEqual(peers, want) {
+		t.Fatalf("peers %v, want %v", peers, want)
+	}
+	//只查询时不注册
+	if _, err := NewRendezvousDiscoverer(server, "").FindPeers(); err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.Peers(); len(got) != 2 {
+		t.Fatalf("registry has %v", got)
+	}
+
+	resp, err := http.Post(srv.URL+"/peers", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("register without address: status %d", resp.StatusCode)
+	}
+}
+
+func TestPeerRegistryExpiry(t *testing.T) {
+	registry := NewPeerRegistry()
+	registry.Register("10.0.0.1:5000")
+	registry.Register("10.0.0.2:5000")
+	registry.peers["10.0.0.1:5000"] = time.Now().Add(-registry.ttl - time.Second)
+	if got := registry.Peers(); !reflect.DeepEqual(got, []string{"10.0.0.2:5000"}) {
+		t.Fatalf("peers %v after expiry", got)
+	}
+}
+
+// 空闲的本地UDP端口
+func freeUDPPort(t *testing.T) string {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+// 在回环地址上代替局域网广播
+func TestBroadcastDiscoverer(t *testing.T) {
+	addr := "127.0.0.1:" + freeUDPPort(t)
+	go NewBroadcastDiscoverer(addr, ":5000").Serve()
+
+	d := NewBroadcastDiscoverer(addr, "127.0.0.1:6000")
+	d.wait = 200 * time.Millisecond
+	//Serve在后台启动,重试直到收到应答
+	var peers []string
+	for i := 0; i < 10 && len(peers) == 0; i++ {
+		var err error
+		if peers, err = d.FindPeers(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"127.0.0.1:5000"}; !reflect.DeepEqual(peers, want) {
+		t.Fatalf("peers %v, want %v", peers, want)
+	}
+}
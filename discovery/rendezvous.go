@@ -0,0 +1,130 @@
+package discovery
+
+import (
+	"GoProject/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 节点注册的有效期
+const PEER_TTL_SEC = 120
+
+// 注册请求
+type RegisterRequest struct {
+	Address *string `json:"address"`
+}
+
+// 节点列表响应
+type PeersResponse struct {
+	Peers []string `json:"peers"`
+}
+
+// 引导节点上的注册表,节点定期注册自己并取回其他节点
+type PeerRegistry struct {
+	peers map[string]time.Time //节点地址 -> 最后注册时间
+	ttl   time.Duration
+	mux   sync.Mutex
+}
+
+func NewPeerRegistry() *PeerRegistry {
+	return &PeerRegistry{
+		peers: make(map[string]time.Time),
+		ttl:   time.Second * PEER_TTL_SEC,
+	}
+}
+
+func (r *PeerRegistry) Register(address string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.peers[address] = time.Now()
+}
+
+// 返回未过期的节点
+func (r *PeerRegistry) Peers() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	peers := make([]string, 0, len(r.peers))
+	for p, seen := range r.peers {
+		if time.Since(seen) > r.ttl {
+			delete(r.peers, p)
+			continue
+		}
+		peers = append(peers, p)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// GET 返回已知节点, POST 注册节点并返回已知节点
+func (r *PeerRegistry) Handler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var rr RegisterRequest
+		if err := json.NewDecoder(req.Body).Decode(&rr); err != nil || rr.Address == nil {
+			log.Println("ERROR: missing field(s)")
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		r.Register(*rr.Address)
+	default:
+		log.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	m, _ := json.Marshal(PeersResponse{Peers: r.Peers()})
+	w.Header().Add("Content-Type", "application/json")
+	io.WriteString(w, string(m[:]))
+}
+
+// 通过引导节点的 /peers 接口发现节点
+type RendezvousDiscoverer struct {
+	server string //引导节点地址 host:port
+	self   string //注册到引导节点的本节点地址,为空时只查询不注册
+	client *http.Client
+}
+
+func NewRendezvousDiscoverer(server string, self string) *RendezvousDiscoverer {
+	return &RendezvousDiscoverer{server, self, &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (d *RendezvousDiscoverer) FindPeers() ([]string, error) {
+	endpoint := fmt.Sprintf("http://%s/peers", d.server)
+	var resp *http.Response
+	var err error
+	if d.self != "" {
+		m, _ := json.Marshal(RegisterRequest{Address: &d.self})
+		resp, err = d.client.Post(endpoint, "application/json", bytes.NewBuffer(m))
+	} else {
+		resp, err = d.client.Get(endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rendezvous %s: status %d", d.server, resp.StatusCode)
+	}
+	var pr PeersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return nil, err
+	}
+	peers := make([]string, 0, len(pr.Peers)+1)
+	//引导节点本身也是一个邻居
+	peers = append(peers, d.server)
+	for _, p := range pr.Peers {
+		if p != d.self {
+			peers = append(peers, p)
+		}
+	}
+	return peers, nil
+}
@@ -0,0 +1,56 @@
+package discovery
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 从种子文件读取节点,每行一个 host[:port],#开头为注释
+// host为域名时像DNS种子一样解析出所有IP
+type SeedDiscoverer struct {
+	path        string
+	defaultPort uint16
+	Resolver    func(host string) ([]string, error) //域名解析,默认为net.LookupHost
+}
+
+func NewSeedDiscoverer(path string, defaultPort uint16) *SeedDiscoverer {
+	return &SeedDiscoverer{path: path, defaultPort: defaultPort, Resolver: net.LookupHost}
+}
+
+func (d *SeedDiscoverer) FindPeers() ([]string, error) {
+	f, err := os.Open(d.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	peers := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		host, port, err := net.SplitHostPort(line)
+		if err != nil {
+			//没有端口时使用默认端口
+			host = line
+			port = strconv.Itoa(int(d.defaultPort))
+		}
+		if net.ParseIP(host) != nil {
+			peers = append(peers, net.JoinHostPort(host, port))
+			continue
+		}
+		addrs, err := d.Resolver(host)
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			peers = append(peers, net.JoinHostPort(a, port))
+		}
+	}
+	return peers, scanner.Err()
+}
@@ -3,6 +3,8 @@ module GoProject
 go 1.22
 
 require (
-	github.com/btcsuite/btcutil v1.0.2 // indirect
-	golang.org/x/crypto v0.25.0 // indirect
+	github.com/btcsuite/btcutil v1.0.2
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.1
+	github.com/tyler-smith/go-bip39 v1.1.0
+	golang.org/x/crypto v0.25.0
 )
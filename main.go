@@ -1,6 +1,7 @@
 package main
 
 import (
+	"GoProject/params"
 	"GoProject/wallet"
 	"fmt"
 	"log"
@@ -15,7 +16,7 @@ type Wallet struct {
 
 func main() {
 	//创建钱包
-	w := wallet.NewWallet()
+	w := wallet.NewWallet(params.MainNet)
 	fmt.Println("私钥：", w.PrivateKey())
 	fmt.Println("私钥：", w.PublicKey())
 
@@ -25,7 +26,7 @@ func main() {
 
 	fmt.Println("节点地址", w.BlockChainAddress())
 
-	t := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockChainAddress(), "B", 3.0)
+	t := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockChainAddress(), "B", 3.0, 0, params.MainNet.ChainId)
 	fmt.Printf("signature %s\n", t.GenerateSignature())
 
 	/*//初始化区块链
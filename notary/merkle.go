@@ -0,0 +1,101 @@
+package notary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// 默克尔树: 叶子为SHA-256(0x00 || 摘要),内部节点为SHA-256(0x01 || 左 || 右)
+// 某一层节点数为奇数时,最后一个节点直接提升到上一层,不与自身配对
+
+func leafHash(digest [32]byte) [32]byte {
+	return sha256.Sum256(append([]byte{0x00}, digest[:]...))
+}
+
+func nodeHash(l, r [32]byte) [32]byte {
+	b := make([]byte, 0, 1+2*32)
+	b = append(b, 0x01)
+	b = append(b, l[:]...)
+	b = append(b, r[:]...)
+	return sha256.Sum256(b)
+}
+
+// 默克尔路径中的一步: 兄弟节点及其位置
+type PathStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"` //兄弟节点在左边
+}
+
+// 计算摘要列表的默克尔根
+func MerkleRoot(digests [][32]byte) [32]byte {
+	root, _ := merkle(digests, -1)
+	return root
+}
+
+// 第index个摘要到根的路径,从叶子到根
+func MerklePath(digests [][32]byte, index int) []PathStep {
+	_, path := merkle(digests, index)
+	return path
+}
+
+// 逐层计算,index不为-1时记录该叶子的路径
+func merkle(digests [][32]byte, index int) ([32]byte, []PathStep) {
+	if len(digests) == 0 {
+		return [32]byte{}, nil
+	}
+	level := make([][32]byte, len(digests))
+	for i, d := range digests {
+		level[i] = leafHash(d)
+	}
+	path := []PathStep{}
+	for len(level) > 1 {
+		next := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			switch index {
+			case i:
+				path = append(path, PathStep{Hash: hex.EncodeToString(level[i+1][:])})
+			case i + 1:
+				path = append(path, PathStep{Hash: hex.EncodeToString(level[i][:]), Left: true})
+			}
+			next = append(next, nodeHash(level[i], level[i+1]))
+		}
+		if index >= 0 {
+			index /= 2
+		}
+		level = next
+	}
+	return level[0], path
+}
+
+// 沿路径计算摘要所在树的根
+func ComputeRoot(digest [32]byte, path []PathStep) ([32]byte, error) {
+	h := leafHash(digest)
+	for i, step := range path {
+		sibling, err := DecodeDigest(step.Hash)
+		if err != nil {
+			return [32]byte{}, fmt.Errorf("path step %d: %v", i, err)
+		}
+		if step.Left {
+			h = nodeHash(sibling, h)
+		} else {
+			h = nodeHash(h, sibling)
+		}
+	}
+	return h, nil
+}
+
+// 解析十六进制的SHA-256摘要
+func DecodeDigest(s string) ([32]byte, error) {
+	var d [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(d) {
+		return d, fmt.Errorf("invalid sha-256 digest %q", s)
+	}
+	copy(d[:], b)
+	return d, nil
+}
@@ -0,0 +1,87 @@
+package notary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func testDigests(n int) [][32]byte {
+	digests := make([][32]byte, n)
+	for i := range digests {
+		digests[i] = sha256.Sum256([]byte(fmt.Sprintf("document %d", i)))
+	}
+	return digests
+}
+
+// 每个叶子沿自己的路径都能算出同一个根,包括奇数个节点提升的情况
+func TestMerklePath(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		digests := testDigests(n)
+		root := MerkleRoot(digests)
+		for i, d := range digests {
+			computed, err := ComputeRoot(d, MerklePath(digests, i))
+			if err != nil {
+				t.Fatalf("n=%d i=%d: %v", n, i, err)
+			}
+			if computed != root {
+				t.Errorf("n=%d i=%d: path leads to %x, want %x", n, i, computed, root)
+			}
+		}
+	}
+}
+
+func TestMerkleRoot(t *testing.T) {
+	if root := MerkleRoot(nil); root != [32]byte{} {
+		t.Errorf("empty root %x", root)
+	}
+	d := testDigests(3)
+	if root := MerkleRoot(d[:1]); root != leafHash(d[0]) {
+		t.Errorf("single leaf root %x, want leaf hash", root)
+	}
+	//第三个叶子不与自身配对,直接提升
+	want := nodeHash(nodeHash(leafHash(d[0]), leafHash(d[1])), leafHash(d[2]))
+	if root := MerkleRoot(d); root != want {
+		t.Errorf("root %x, want %x", root, want)
+	}
+	if path := MerklePath(d, 2); len(path) != 1 || !path[0].Left {
+		t.Errorf("path of promoted leaf %+v", path)
+	}
+}
+
+func TestMerklePathTampered(t *testing.T) {
+	digests := testDigests(5)
+	root := MerkleRoot(digests)
+	path := MerklePath(digests, 1)
+
+	if computed, _ := ComputeRoot(digests[0], path); computed == root {
+		t.Errorf("path of leaf 1 proves leaf 0")
+	}
+	flipped := append([]PathStep{}, path...)
+	flipped[0].Left = !flipped[0].Left
+	if computed, _ := ComputeRoot(digests[1], flipped); computed == root {
+		t.Errorf("path with flipped side verified")
+	}
+	if computed, _ := ComputeRoot(digests[1], path[:len(path)-1]); computed == root {
+		t.Errorf("truncated path verified")
+	}
+	bad := append([]PathStep{}, path...)
+	bad[0].Hash = "zz"
+	if _, err := ComputeRoot(digests[1], bad); err == nil {
+		t.Errorf("invalid sibling hash accepted")
+	}
+}
+
+func TestDecodeDigest(t *testing.T) {
+	d := testDigests(1)[0]
+	got, err := DecodeDigest(hex.EncodeToString(d[:]))
+	if err != nil || got != d {
+		t.Fatalf("decode %x: %x %v", d, got, err)
+	}
+	for _, s := range []string{"", "zz", hex.EncodeToString(d[:31]), hex.EncodeToString(append(d[:], 0))} {
+		if _, err := DecodeDigest(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
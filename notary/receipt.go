@@ -0,0 +1,74 @@
+package notary
+
+import (
+	"GoProject/block"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// 锚定交易的数据: ANCHOR_PREFIX || 默克尔根
+const ANCHOR_PREFIX = "NTRY"
+
+func AnchorData(root [32]byte) []byte {
+	return append([]byte(ANCHOR_PREFIX), root[:]...)
+}
+
+// 公证回执: 摘要到默克尔根的路径,以及记录了该根的区块
+// 只需该高度的区块即可离线验证,不依赖公证服务
+type Receipt struct {
+	Digest    string     `json:"digest"`
+	Root      string     `json:"merkle_root"`
+	Path      []PathStep `json:"merkle_path"`
+	Height    int        `json:"height"`
+	BlockHash string     `json:"block_hash"`
+	TxIndex   int        `json:"tx_index"` //锚定交易在区块中的序号
+}
+
+// 验证摘要属于默克尔根,且根记录在哈希为BlockHash的区块b的第TxIndex笔交易中
+func (r *Receipt) Verify(b *block.Block) error {
+	digest, err := DecodeDigest(r.Digest)
+	if err != nil {
+		return err
+	}
+	root, err := DecodeDigest(r.Root)
+	if err != nil {
+		return fmt.Errorf("merkle_root: %v", err)
+	}
+	computed, err := ComputeRoot(digest, r.Path)
+	if err != nil {
+		return err
+	}
+	if computed != root {
+		return fmt.Errorf("merkle path of %s leads to %x, not %s", r.Digest, computed, r.Root)
+	}
+	hash := b.Hash()
+	if hex.EncodeToString(hash[:]) != r.BlockHash {
+		return fmt.Errorf("block hash %x does not match receipt %s", hash, r.BlockHash)
+	}
+	transactions := b.Transactions()
+	if r.TxIndex < 0 || r.TxIndex >= len(transactions) {
+		return fmt.Errorf("block has no transaction %d", r.TxIndex)
+	}
+	if !bytes.Equal(transactions[r.TxIndex].Data(), AnchorData(root)) {
+		return fmt.Errorf("transaction %d does not anchor merkle root %s", r.TxIndex, r.Root)
+	}
+	return nil
+}
+
+// 在主链chain中验证回执: 回执的区块必须在主链的对应高度上
+func (r *Receipt) VerifyChain(chain []*block.Block) error {
+	if r.Height <= 0 || r.Height >= len(chain) {
+		return fmt.Errorf("height %d is not in the chain of %d blocks", r.Height, len(chain))
+	}
+	if err := r.Verify(chain[r.Height]); err != nil {
+		return err
+	}
+	//后续区块必须依次链接到该区块
+	for i := r.Height + 1; i < len(chain); i++ {
+		if chain[i].PreviousHash() != chain[i-1].Hash() {
+			return fmt.Errorf("block %d does not link to block %d", i, i-1)
+		}
+	}
+	return nil
+}
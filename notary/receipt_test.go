@@ -0,0 +1,85 @@
+package notary
+
+import (
+	"GoProject/block"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 由JSON构造区块,与从节点获取的区块相同
+func testBlock(t *testing.T, previousHash [32]byte, data []byte) *block.Block {
+	t.Helper()
+	js := fmt.Sprintf(`{"timestamp":1,"nonce":0,"previous_hash":"%x","state_root":"%s","transactions":[
+		{"sender_blockchain_address":"THE BLOCKCHAIN","recipient_blockchain_address":"miner","value":1},
+		{"sender_blockchain_address":"notary","recipient_blockchain_address":"notary","value":0,"data":"%x"}]}`,
+		previousHash, strings.Repeat("00", 32), data)
+	b := new(block.Block)
+	if err := json.Unmarshal([]byte(js), b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func testReceipt(t *testing.T) (*Receipt, []*block.Block) {
+	digests := testDigests(4)
+	root := MerkleRoot(digests)
+	genesis := testBlock(t, [32]byte{}, nil)
+	anchor := testBlock(t, genesis.Hash(), AnchorData(root))
+	next := testBlock(t, anchor.Hash(), nil)
+	hash := anchor.Hash()
+	r := &Receipt{
+		Digest:    hex.EncodeToString(digests[2][:]),
+		Root:      hex.EncodeToString(root[:]),
+		Path:      MerklePath(digests, 2),
+		Height:    1,
+		BlockHash: hex.EncodeToString(hash[:]),
+		TxIndex:   1,
+	}
+	return r, []*block.Block{genesis, anchor, next}
+}
+
+func TestReceiptVerify(t *testing.T) {
+	r, chain := testReceipt(t)
+	if err := r.Verify(chain[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.VerifyChain(chain); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReceiptTampered(t *testing.T) {
+	other := testDigests(5)[4]
+	tests := []struct {
+		name   string
+		tamper func(r *Receipt)
+	}{
+		{"digest", func(r *Receipt) { r.Digest = hex.EncodeToString(other[:]) }},
+		{"root", func(r *Receipt) { r.Root = hex.EncodeToString(other[:]) }},
+		{"path", func(r *Receipt) { r.Path = r.Path[1:] }},
+		{"block hash", func(r *Receipt) { r.BlockHash = hex.EncodeToString(other[:]) }},
+		{"coinbase", func(r *Receipt) { r.TxIndex = 0 }},
+		{"tx index", func(r *Receipt) { r.TxIndex = 2 }},
+		{"height", func(r *Receipt) { r.Height = 2 }},
+		{"genesis", func(r *Receipt) { r.Height = 0 }},
+	}
+	for _, tt := range tests {
+		r, chain := testReceipt(t)
+		tt.tamper(r)
+		if err := r.VerifyChain(chain); err == nil {
+			t.Errorf("%s: tampered receipt verified", tt.name)
+		}
+	}
+}
+
+// 回执区块之后的区块必须链接到它
+func TestReceiptBrokenChain(t *testing.T) {
+	r, chain := testReceipt(t)
+	chain[2] = testBlock(t, [32]byte{}, nil)
+	if err := r.VerifyChain(chain); err == nil {
+		t.Fatalf("receipt verified on a broken chain")
+	}
+}
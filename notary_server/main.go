@@ -0,0 +1,66 @@
+package main
+
+import (
+	"GoProject/params"
+	"GoProject/wallet"
+	"crypto/elliptic"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 公证钱包密钥库的密码所在的环境变量
+const NOTARY_PASSPHRASE_ENV = "NOTARY_PASSPHRASE"
+
+func init() {
+	log.SetPrefix("Notary Server: ")
+}
+
+func main() {
+	network := flag.String("network", "mainnet", "Network: mainnet, testnet or regtest")
+	port := flag.Uint("port", 0, "TCP port number for Notary Server (default from network)")
+	gateway := flag.String("gateway", "", "Blockchain Gateway (default 127.0.0.1 on the network port)")
+	keystoreDir := flag.String("keystore", "keystore/notary", "Directory of the encrypted key paying for anchor transactions, passphrase from $"+NOTARY_PASSPHRASE_ENV)
+	storePath := flag.String("store", "notary.json", "File keeping pending digests and anchored batches")
+	interval := flag.Int("interval", 10, "Seconds between anchor transactions")
+	flag.Parse()
+	p, err := params.ByName(*network)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if *port == 0 {
+		*port = uint(p.DefaultNotaryPort)
+	}
+	if *gateway == "" {
+		*gateway = fmt.Sprintf("127.0.0.1:%d", p.DefaultPort)
+	}
+	if *interval <= 0 {
+		log.Fatalf("ERROR: -interval must be positive")
+	}
+	//空口令加密的私钥等于明文保存
+	passphrase := os.Getenv(NOTARY_PASSPHRASE_ENV)
+	if passphrase == "" {
+		log.Fatalf("ERROR: $%s is required to encrypt the notary key", NOTARY_PASSPHRASE_ENV)
+	}
+	ks, err := wallet.NewKeyStore(filepath.Join(*keystoreDir, p.Name), p)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	w, created, err := ks.LoadOrCreate(elliptic.P256(), passphrase)
+	if err != nil {
+		log.Fatalf("ERROR: keystore %s: %v", ks.Dir(), err)
+	}
+	if created {
+		log.Printf("created notary wallet in keystore %s", ks.Dir())
+	}
+	log.Printf("notary address %s pays the data fee of anchor transactions", w.BlockChainAddress())
+	server, err := NewNotaryServer(uint16(*port), *gateway, p, w, *storePath)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	server.Start(time.Duration(*interval) * time.Second)
+	server.Run()
+}
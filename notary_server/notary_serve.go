@@ -0,0 +1,425 @@
+package main
+
+import (
+	"GoProject/block"
+	"GoProject/notary"
+	"GoProject/params"
+	"GoProject/utils"
+	"GoProject/wallet"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	MAX_BATCH_SIZE = 10000 //每批最多的摘要数
+	RESUBMIT_SEC   = 600   //锚定交易超过该时间未确认时重新提交
+)
+
+// 一批摘要和记录其默克尔根的锚定交易
+type Batch struct {
+	Digests   []string `json:"digests"`
+	Root      string   `json:"merkle_root"`
+	Submitted int64    `json:"submitted"` //最近一次提交锚定交易的unix时间(秒)
+	Height    int      `json:"height"`    //锚定交易所在区块的高度,未确认为-1
+	BlockHash string   `json:"block_hash,omitempty"`
+	TxIndex   int      `json:"tx_index"`
+}
+
+func (b *Batch) confirmed() bool {
+	return b.Height >= 0
+}
+
+func (b *Batch) digests() [][32]byte {
+	digests := make([][32]byte, len(b.Digests))
+	for i, d := range b.Digests {
+		digests[i], _ = notary.DecodeDigest(d)
+	}
+	return digests
+}
+
+// 保存在store文件中的内容
+type notaryState struct {
+	Pending []string `json:"pending"`
+	Batches []*Batch `json:"batches"`
+}
+
+type NotaryServer struct {
+	port    uint16
+	gateway string
+	params  *params.Params //所在网络
+	wallet  *wallet.Wallet //签名锚定交易,余额用于支付数据手续费
+	store   string         //保存待提交摘要和批次的文件
+
+	mux     sync.Mutex
+	pending []string          //等待下一批提交的摘要
+	batches []*Batch          //已提交的批次,按提交顺序
+	index   map[string]*Batch //摘要 -> 所在批次,待提交时为nil
+}
+
+func NewNotaryServer(port uint16, gateway string, p *params.Params, w *wallet.Wallet, store string) (*NotaryServer, error) {
+	ns := &NotaryServer{port: port, gateway: gateway, params: p, wallet: w, store: store, index: make(map[string]*Batch)}
+	if err := ns.load(); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+func (ns *NotaryServer) Port() uint16 {
+	return ns.port
+}
+
+func (ns *NotaryServer) Gateway() string {
+	return ns.gateway
+}
+
+// 读取store文件,文件不存在时从空状态开始
+func (ns *NotaryServer) load() error {
+	m, err := os.ReadFile(ns.store)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var s notaryState
+	if err := json.Unmarshal(m, &s); err != nil {
+		return fmt.Errorf("%s: %v", ns.store, err)
+	}
+	ns.pending = s.Pending
+	ns.batches = s.Batches
+	for _, d := range ns.pending {
+		ns.index[d] = nil
+	}
+	for _, b := range ns.batches {
+		for _, d := range b.Digests {
+			ns.index[d] = b
+		}
+	}
+	return nil
+}
+
+// 写入store文件,先写临时文件再替换,调用方需持有ns.mux
+func (ns *NotaryServer) save() {
+	m, _ := json.MarshalIndent(notaryState{Pending: ns.pending, Batches: ns.batches}, "", "  ")
+	tmp := ns.store + ".tmp"
+	if err := os.WriteFile(tmp, m, 0600); err != nil {
+		log.Printf("ERROR: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, ns.store); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+}
+
+// 定期把待提交的摘要打包成一批并锚定到链上,然后检查已提交批次是否已打包进区块
+func (ns *NotaryServer) Start(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			ns.flush()
+			ns.confirm()
+		}
+	}()
+}
+
+// 把待提交的摘要组成一批,发送记录默克尔根的交易
+func (ns *NotaryServer) flush() {
+	ns.mux.Lock()
+	defer ns.mux.Unlock()
+	if len(ns.pending) == 0 {
+		return
+	}
+	n := len(ns.pending)
+	if n > MAX_BATCH_SIZE {
+		n = MAX_BATCH_SIZE
+	}
+	b := &Batch{Digests: ns.pending[:n:n], Height: -1}
+	root := notary.MerkleRoot(b.digests())
+	b.Root = hex.EncodeToString(root[:])
+	if err := ns.anchor(b); err != nil {
+		log.Printf("ERROR: anchor batch of %d digests: %v", n, err)
+		return
+	}
+	ns.pending = append([]string{}, ns.pending[n:]...)
+	ns.batches = append(ns.batches, b)
+	for _, d := range b.Digests {
+		ns.index[d] = b
+	}
+	ns.save()
+	log.Printf("action=anchor, digests=%d, root=%s", n, b.Root)
+}
+
+// 签名并发送锚定交易
+func (ns *NotaryServer) anchor(b *Batch) error {
+	root, _ := notary.DecodeDigest(b.Root)
+	nonce, err := ns.nextNonce()
+	if err != nil {
+		return err
+	}
+	bt := wallet.NewDataTransaction(ns.params, ns.wallet, nonce, notary.AnchorData(root))
+	m, _ := json.Marshal(bt)
+	resp, err := http.Post("http://"+ns.Gateway()+"/transactions", "application/json", bytes.NewBuffer(m))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var status struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&status)
+		return errors.New(status.Message)
+	}
+	b.Submitted = time.Now().Unix()
+	return nil
+}
+
+// 向区块链节点查询公证钱包下一笔交易的nonce
+func (ns *NotaryServer) nextNonce() (uint64, error) {
+	q := url.Values{"blockchain_address": {ns.wallet.BlockChainAddress()}}
+	resp, err := http.Get("http://" + ns.Gateway() + "/nonce?" + q.Encode())
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("gateway returned status %d", resp.StatusCode)
+	}
+	var r struct {
+		Nonce uint64 `json:"nonce"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return 0, err
+	}
+	return r.Nonce, nil
+}
+
+// 向区块链节点查询未确认批次的锚定交易,长时间未确认时重新提交
+func (ns *NotaryServer) confirm() {
+	ns.mux.Lock()
+	defer ns.mux.Unlock()
+	changed := false
+	for _, b := range ns.batches {
+		if b.confirmed() {
+			continue
+		}
+		loc, err := ns.findAnchor(b)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			continue
+		}
+		if loc == nil {
+			if time.Now().Unix()-b.Submitted > RESUBMIT_SEC {
+				if err := ns.anchor(b); err != nil {
+					log.Printf("ERROR: resubmit anchor %s: %v", b.Root, err)
+				} else {
+					changed = true
+				}
+			}
+			continue
+		}
+		b.Height, b.BlockHash, b.TxIndex = loc.Height, loc.BlockHash, loc.Index
+		changed = true
+		log.Printf("action=confirm, root=%s, height=%d", b.Root, b.Height)
+	}
+	if changed {
+		ns.save()
+	}
+}
+
+// 在主链上查找本服务发送的锚定交易,尚未打包时返回nil
+func (ns *NotaryServer) findAnchor(b *Batch) (*block.DataLocation, error) {
+	root, _ := notary.DecodeDigest(b.Root)
+	q := url.Values{"hex": {hex.EncodeToString(notary.AnchorData(root))}}
+	resp, err := http.Get("http://" + ns.Gateway() + "/data?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gateway returned status %d", resp.StatusCode)
+	}
+	var r struct {
+		Transactions []*block.DataLocation `json:"transactions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	for _, loc := range r.Transactions {
+		if loc.Sender == ns.wallet.BlockChainAddress() {
+			return loc, nil
+		}
+	}
+	return nil, nil
+}
+
+// 摘要的公证回执,未确认时返回状态
+func (ns *NotaryServer) receipt(digest string) (*notary.Receipt, string) {
+	ns.mux.Lock()
+	defer ns.mux.Unlock()
+	b, ok := ns.index[digest]
+	switch {
+	case !ok:
+		return nil, "unknown"
+	case b == nil:
+		return nil, "pending"
+	case !b.confirmed():
+		return nil, "submitted"
+	}
+	i := 0
+	for b.Digests[i] != digest {
+		i++
+	}
+	return &notary.Receipt{
+		Digest:    digest,
+		Root:      b.Root,
+		Path:      notary.MerklePath(b.digests(), i),
+		Height:    b.Height,
+		BlockHash: b.BlockHash,
+		TxIndex:   b.TxIndex,
+	}, "confirmed"
+}
+
+// 公证请求: 文件的SHA-256摘要(十六进制)
+type NotarizeRequest struct {
+	Digests []string `json:"digests"`
+}
+
+// 提交需要公证的摘要,在下一批中锚定;重复提交的摘要返回当前状态
+func (ns *NotaryServer) Notarize(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var nr NotarizeRequest
+		if err := json.NewDecoder(req.Body).Decode(&nr); err != nil || len(nr.Digests) == 0 {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		digests := make([]string, len(nr.Digests))
+		for i, s := range nr.Digests {
+			d, err := notary.DecodeDigest(s)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus(err.Error())))
+				return
+			}
+			digests[i] = hex.EncodeToString(d[:])
+		}
+		type digestStatus struct {
+			Digest string `json:"digest"`
+			Status string `json:"status"`
+		}
+		statuses := make([]digestStatus, len(digests))
+		ns.mux.Lock()
+		for i, d := range digests {
+			if _, ok := ns.index[d]; !ok {
+				ns.pending = append(ns.pending, d)
+				ns.index[d] = nil
+			}
+			statuses[i] = digestStatus{Digest: d, Status: "pending"}
+			if b := ns.index[d]; b != nil {
+				statuses[i].Status = "submitted"
+				if b.confirmed() {
+					statuses[i].Status = "confirmed"
+				}
+			}
+		}
+		ns.save()
+		ns.mux.Unlock()
+		m, _ := json.Marshal(struct {
+			Digests []digestStatus `json:"digests"`
+		}{
+			Digests: statuses,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 公证回执: /receipt?digest=,锚定交易打包进区块前返回202和当前状态
+func (ns *NotaryServer) Receipt(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		d, err := notary.DecodeDigest(req.URL.Query().Get("digest"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		r, status := ns.receipt(hex.EncodeToString(d[:]))
+		switch status {
+		case "unknown":
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("digest not found")))
+			return
+		case "pending", "submitted":
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, string(utils.JsonStatus(status)))
+			return
+		}
+		m, _ := json.Marshal(r)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 服务状态: 公证地址、待提交的摘要数和所有批次
+func (ns *NotaryServer) Status(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		type batchInfo struct {
+			Root      string `json:"merkle_root"`
+			Size      int    `json:"size"`
+			Height    int    `json:"height"`
+			BlockHash string `json:"block_hash,omitempty"`
+		}
+		ns.mux.Lock()
+		batches := make([]batchInfo, len(ns.batches))
+		for i, b := range ns.batches {
+			batches[i] = batchInfo{Root: b.Root, Size: len(b.Digests), Height: b.Height, BlockHash: b.BlockHash}
+		}
+		pending := len(ns.pending)
+		ns.mux.Unlock()
+		m, _ := json.Marshal(struct {
+			Address string      `json:"blockchain_address"`
+			Pending int         `json:"pending"`
+			Batches []batchInfo `json:"batches"`
+		}{
+			Address: ns.wallet.BlockChainAddress(),
+			Pending: pending,
+			Batches: batches,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+func (ns *NotaryServer) Run() {
+	http.HandleFunc("/notarize", ns.Notarize)
+	http.HandleFunc("/receipt", ns.Receipt)
+	http.HandleFunc("/status", ns.Status)
+	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(ns.Port())), nil))
+}
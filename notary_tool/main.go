@@ -0,0 +1,97 @@
+package main
+
+import (
+	"GoProject/block"
+	"GoProject/notary"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+func init() {
+	log.SetPrefix("Notary Tool: ")
+	log.SetFlags(0)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: notary_tool <command> [args]
+
+Commands:
+  digest <file>                 print the SHA-256 digest of a file to notarize
+  verify <receipt> <chain>      verify a receipt against a chain saved from GET / of a node
+  verify <receipt> <chain> <file>
+                                also check that the receipt is for the file
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func fileDigest(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// 计算文件摘要,离线验证公证回执
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch flag.Arg(0) {
+	case "digest":
+		if flag.NArg() != 2 {
+			usage()
+			os.Exit(2)
+		}
+		fmt.Println(fileDigest(flag.Arg(1)))
+	case "verify":
+		if flag.NArg() != 3 && flag.NArg() != 4 {
+			usage()
+			os.Exit(2)
+		}
+		m, err := os.ReadFile(flag.Arg(1))
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		var r notary.Receipt
+		if err := json.Unmarshal(m, &r); err != nil {
+			log.Fatalf("ERROR: receipt: %v", err)
+		}
+		if m, err = os.ReadFile(flag.Arg(2)); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		var bc block.BlockChain
+		if err := json.Unmarshal(m, &bc); err != nil {
+			log.Fatalf("ERROR: chain: %v", err)
+		}
+		if flag.NArg() == 4 {
+			if d := fileDigest(flag.Arg(3)); d != r.Digest {
+				log.Fatalf("FAIL: file digest %s does not match receipt %s", d, r.Digest)
+			}
+		}
+		chain := bc.Chain()
+		if err := r.VerifyChain(chain); err != nil {
+			log.Fatalf("FAIL: %v", err)
+		}
+		fmt.Printf("OK %s anchored at height %d (%d confirmations), block %s\n", r.Digest, r.Height, len(chain)-r.Height, r.BlockHash)
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
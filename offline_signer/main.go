@@ -0,0 +1,221 @@
+package main
+
+import (
+	"GoProject/params"
+	"GoProject/script"
+	"GoProject/utils"
+	"GoProject/wallet"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 密钥密码的环境变量,与区块链节点一致
+const KEYSTORE_PASSPHRASE_ENV = "KEYSTORE_PASSPHRASE"
+
+func init() {
+	log.SetPrefix("Offline Signer: ")
+	log.SetFlags(0)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: offline_signer [flags] <command>
+
+Commands:
+  new    create a new key in the keystore
+  list   list the addresses, curves and script public keys in the keystore
+  sign   sign an unsigned transaction from POST /transaction/build,
+         the result can be sent to POST /transaction/broadcast.
+         A partially signed transaction from POST /multisig/build is
+         signed with every keystore key of the multisig
+  sign-raw sign a script transaction from POST /script/build with the
+         key of -address, print the public key and signature for the
+         unlock script
+
+The passphrase is read from -passphrase-file or $%s.
+
+Flags:
+`, KEYSTORE_PASSPHRASE_ENV)
+	flag.PrintDefaults()
+}
+
+// 在离线机器上使用本地密钥库签名交易
+func main() {
+	network := flag.String("network", "mainnet", "Network: mainnet, testnet or regtest")
+	keystoreDir := flag.String("keystore", "keystore/offline", "Directory of encrypted keys")
+	curveName := flag.String("curve", "p256", "Curve of new keys: p256 or secp256k1")
+	passphraseFile := flag.String("passphrase-file", "", "File containing the keystore passphrase")
+	in := flag.String("in", "-", "Unsigned transaction file (- for stdin)")
+	out := flag.String("out", "-", "Signed transaction file (- for stdout)")
+	signer := flag.String("address", "", "Keystore address used by sign-raw")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	p, err := params.ByName(*network)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	ks, err := wallet.NewKeyStore(filepath.Join(*keystoreDir, p.Name), p)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	switch flag.Arg(0) {
+	case "new":
+		curve, err := utils.CurveByName(*curveName)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		w := wallet.NewWalletWithCurve(curve, p)
+		if err := ks.Store(w, passphrase(*passphraseFile)); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		fmt.Println(w.BlockChainAddress())
+	case "list":
+		keys, err := ks.List()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		//第三列为脚本中使用的公钥
+		for _, k := range keys {
+			pub, err := utils.DecodePublicKey(k.PublicKey)
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			b, _ := utils.EncodePublicKey(pub)
+			fmt.Printf("%s %s %x\n", k.Address, k.Curve, b)
+		}
+	case "sign":
+		data, err := readInput(*in)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if _, ok := fields["multisig"]; ok {
+			signMultisig(ks, p, data, passphrase(*passphraseFile), *out)
+			return
+		}
+		ut, err := wallet.ParseUnsignedTransaction(data, p)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		//签名前显示交易内容,以便核对
+		log.Printf("network=%s from=%s to=%s value=%v nonce=%d lock_time=%d data=%s hash=%s",
+			ut.Network, ut.SenderBlockChainAddress, ut.ReceiverBlockChainAddress, ut.Value, ut.Nonce, ut.LockTime, ut.Data, ut.SigningHash)
+		w, err := ks.Load(ut.SenderBlockChainAddress, passphrase(*passphraseFile))
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		signed, err := ut.Sign(w)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		m, _ := json.MarshalIndent(signed, "", "  ")
+		if err := writeOutput(*out, append(m, '\n')); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	case "sign-raw":
+		data, err := readInput(*in)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		st, err := wallet.ParseScriptTransaction(data, p)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		lock, _ := script.FromHex(st.LockScript)
+		log.Printf("network=%s from=%s to=%s value=%v nonce=%d lock_time=%d hash=%s",
+			st.Network, st.SenderBlockChainAddress, st.ReceiverBlockChainAddress, st.Value, st.Nonce, st.LockTime, st.SigningHash)
+		log.Printf("lock script: %s", lock)
+		w, err := ks.Load(*signer, passphrase(*passphraseFile))
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		sig, err := st.RawSignature(w)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		m, _ := json.MarshalIndent(sig, "", "  ")
+		if err := writeOutput(*out, append(m, '\n')); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+// 用密钥库中属于多重签名的所有密钥签名
+func signMultisig(ks *wallet.KeyStore, p *params.Params, data []byte, pass string, out string) {
+	pst, err := wallet.ParsePartiallySignedTransaction(data, p)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	log.Printf("network=%s from=%s to=%s value=%v nonce=%d lock_time=%d hash=%s signatures=%d/%d",
+		pst.Network, pst.SenderBlockChainAddress, pst.ReceiverBlockChainAddress, pst.Value, pst.Nonce, pst.LockTime, pst.SigningHash,
+		pst.SignatureCount(), pst.Multisig.Threshold())
+	keys, err := ks.List()
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	signed := 0
+	for _, k := range keys {
+		pub, err := utils.DecodePublicKey(k.PublicKey)
+		if err != nil || pst.Multisig.IndexOf(pub) < 0 {
+			continue
+		}
+		w, err := ks.Load(k.Address, pass)
+		if err == nil {
+			err = pst.Sign(w)
+		}
+		if err != nil {
+			log.Fatalf("ERROR: %s: %v", k.Address, err)
+		}
+		signed += 1
+	}
+	if signed == 0 {
+		log.Fatalf("ERROR: no key in the keystore belongs to multisig %s", pst.SenderBlockChainAddress)
+	}
+	log.Printf("signed with %d key(s), signatures=%d/%d", signed, pst.SignatureCount(), pst.Multisig.Threshold())
+	m, _ := json.MarshalIndent(pst, "", "  ")
+	if err := writeOutput(out, append(m, '\n')); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+}
+
+func passphrase(path string) string {
+	if path == "" {
+		return os.Getenv(KEYSTORE_PASSPHRASE_ENV)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	return strings.TrimRight(string(data), "\r\n")
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func writeOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
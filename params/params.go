@@ -0,0 +1,128 @@
+package params
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 网络参数,用于区分主网、测试网和回归测试网
+type Params struct {
+	Name            string
+	ChainId         uint32  //参与交易签名,防止交易在其他网络上重放
+	Magic           [4]byte //节点之间通信时携带,拒绝其他网络的节点
+	AddressVersion  byte    //地址的版本字节
+	MultisigVersion byte    //多重签名地址的版本字节
+	ScriptVersion   byte    //脚本地址的版本字节,与多重签名地址分开,避免公钥集合被当作锁定脚本
+	ContractVersion byte    //合约地址的版本字节
+	HDCoinType      uint32  //BIP44派生路径中的coin_type
+
+	DefaultPort       uint16 //区块链节点默认端口
+	DefaultWalletPort uint16 //钱包服务默认端口
+	DefaultNotaryPort uint16 //公证服务默认端口
+	PortRangeStart    uint16 //扫描邻居节点的端口范围
+	PortRangeEnd      uint16
+
+	GenesisTimestamp int64 //固定的创世区块时间戳,同一网络的节点共享创世区块
+	Difficulty       int   //挖矿难度(哈希前导0的个数)
+	MiningTimerSec   int   //自动挖矿间隔,0表示只按需挖矿
+	AllowEmptyBlocks bool  //交易池为空时也允许挖矿
+	RegTestMode      bool  //允许按需生成区块(/generate)和设置模拟时间
+
+	BlockGasLimit uint64 //每个区块中合约交易的gas上限之和
+
+	Checkpoints map[int]string //硬编码的检查点: 区块高度 -> 区块哈希
+}
+
+var MainNet = &Params{
+	Name:              "mainnet",
+	ChainId:           1,
+	Magic:             [4]byte{0xf9, 0xbe, 0xb4, 0xd9},
+	AddressVersion:    0x00,
+	MultisigVersion:   0x05,
+	ScriptVersion:     0x32,
+	ContractVersion:   0x1c,
+	HDCoinType:        0,
+	DefaultPort:       5000,
+	DefaultWalletPort: 8080,
+	DefaultNotaryPort: 9090,
+	PortRangeStart:    5000,
+	PortRangeEnd:      5003,
+	GenesisTimestamp:  1704067200000000000,
+	Difficulty:        3,
+	MiningTimerSec:    20,
+	BlockGasLimit:     1000000,
+	Checkpoints:       map[int]string{},
+}
+
+var TestNet = &Params{
+	Name:              "testnet",
+	ChainId:           2,
+	Magic:             [4]byte{0x0b, 0x11, 0x09, 0x07},
+	AddressVersion:    0x6f,
+	MultisigVersion:   0xc4,
+	ScriptVersion:     0x3a,
+	ContractVersion:   0x57,
+	HDCoinType:        1,
+	DefaultPort:       15000,
+	DefaultWalletPort: 18080,
+	DefaultNotaryPort: 19090,
+	PortRangeStart:    15000,
+	PortRangeEnd:      15003,
+	GenesisTimestamp:  1704067200000000001,
+	Difficulty:        2,
+	MiningTimerSec:    20,
+	BlockGasLimit:     1000000,
+	Checkpoints:       map[int]string{},
+}
+
+var RegTest = &Params{
+	Name:              "regtest",
+	ChainId:           3,
+	Magic:             [4]byte{0xfa, 0xbf, 0xb5, 0xda},
+	AddressVersion:    0x6f,
+	MultisigVersion:   0xc4,
+	ScriptVersion:     0x3a,
+	ContractVersion:   0x57,
+	HDCoinType:        1,
+	DefaultPort:       25000,
+	DefaultWalletPort: 28080,
+	DefaultNotaryPort: 29090,
+	PortRangeStart:    25000,
+	PortRangeEnd:      25003,
+	GenesisTimestamp:  1704067200000000002,
+	Difficulty:        1,
+	MiningTimerSec:    0,
+	AllowEmptyBlocks:  true,
+	RegTestMode:       true,
+	BlockGasLimit:     1000000,
+	Checkpoints:       map[int]string{},
+}
+
+var networks = map[string]*Params{
+	MainNet.Name: MainNet,
+	TestNet.Name: TestNet,
+	RegTest.Name: RegTest,
+}
+
+// 根据名称获取网络参数
+func ByName(name string) (*Params, error) {
+	p, ok := networks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown network %q (available: %v)", name, Names())
+	}
+	return p, nil
+}
+
+func Names() []string {
+	names := make([]string, 0, len(networks))
+	for n := range networks {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HTTP头中使用的magic字符串
+func (p *Params) MagicString() string {
+	return fmt.Sprintf("%x", p.Magic)
+}
@@ -0,0 +1,49 @@
+package params
+
+import "testing"
+
+func TestByName(t *testing.T) {
+	for _, name := range Names() {
+		p, err := ByName(name)
+		if err != nil || p.Name != name {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := ByName("simnet"); err == nil {
+		t.Errorf("unknown network accepted")
+	}
+}
+
+// 不同网络的chain id、magic和创世区块必须不同,否则交易和节点会跨网络
+// 同一网络中不同类型地址的版本字节必须不同
+func TestNetworksDistinct(t *testing.T) {
+	chainIds := make(map[uint32]string)
+	magics := make(map[string]string)
+	genesis := make(map[int64]string)
+	for _, name := range Names() {
+		p, _ := ByName(name)
+		if other, ok := chainIds[p.ChainId]; ok {
+			t.Errorf("%s and %s share chain id %d", name, other, p.ChainId)
+		}
+		if other, ok := magics[p.MagicString()]; ok {
+			t.Errorf("%s and %s share magic %s", name, other, p.MagicString())
+		}
+		if other, ok := genesis[p.GenesisTimestamp]; ok {
+			t.Errorf("%s and %s share the genesis timestamp", name, other)
+		}
+		chainIds[p.ChainId] = name
+		magics[p.MagicString()] = name
+		genesis[p.GenesisTimestamp] = name
+		versions := []byte{p.AddressVersion, p.MultisigVersion, p.ScriptVersion, p.ContractVersion}
+		for i := range versions {
+			for j := i + 1; j < len(versions); j++ {
+				if versions[i] == versions[j] {
+					t.Errorf("%s: address versions %d and %d are both 0x%02x", name, i, j, versions[i])
+				}
+			}
+		}
+		if p.PortRangeStart > p.DefaultPort || p.DefaultPort > p.PortRangeEnd {
+			t.Errorf("%s: default port %d outside the scan range", name, p.DefaultPort)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package script
+
+import (
+	"GoProject/utils"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// 原像长度固定为32字节,避免两条链的元素大小限制不同导致只能在一条链上赎回
+const PREIMAGE_LEN = 32
+
+var ErrNotHTLC = errors.New("not a hash time-locked contract")
+
+// 哈希时间锁合约: 接收方在超时前用原像赎回,超时后退款方取回
+//
+//	OP_IF
+//	    OP_SIZE 32 OP_EQUALVERIFY OP_SHA256 <hash> OP_EQUALVERIFY <recipient>
+//	OP_ELSE
+//	    <timeout> OP_CHECKLOCKTIMEVERIFY OP_DROP <refund>
+//	OP_ENDIF
+//	OP_CHECKSIG
+type HTLC struct {
+	Hash      [32]byte         //原像的SHA-256
+	Recipient *ecdsa.PublicKey //赎回方
+	Refund    *ecdsa.PublicKey //退款方
+	Timeout   int64            //区块高度或unix时间(秒),与时间锁相同
+}
+
+func (h *HTLC) Script() (Script, error) {
+	if h.Timeout <= 0 {
+		return nil, fmt.Errorf("invalid timeout %d", h.Timeout)
+	}
+	if h.Recipient == nil || h.Refund == nil {
+		return nil, errors.New("missing public key")
+	}
+	recipient, err := utils.EncodePublicKey(h.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	refund, err := utils.EncodePublicKey(h.Refund)
+	if err != nil {
+		return nil, err
+	}
+	s := Script{byte(OP_IF), byte(OP_SIZE)}
+	s = AppendNumber(s, PREIMAGE_LEN)
+	s = append(s, byte(OP_EQUALVERIFY), byte(OP_SHA256))
+	s = AppendData(s, h.Hash[:])
+	s = append(s, byte(OP_EQUALVERIFY))
+	s = AppendData(s, recipient)
+	s = append(s, byte(OP_ELSE))
+	s = AppendNumber(s, h.Timeout)
+	s = append(s, byte(OP_CHECKLOCKTIMEVERIFY), byte(OP_DROP))
+	s = AppendData(s, refund)
+	s = append(s, byte(OP_ENDIF), byte(OP_CHECKSIG))
+	return s, nil
+}
+
+// 识别哈希时间锁合约的锁定脚本
+func ParseHTLC(s Script) (*HTLC, error) {
+	ins, err := s.instructions()
+	if err != nil || len(ins) != 15 {
+		return nil, ErrNotHTLC
+	}
+	h := &HTLC{}
+	if len(ins[5].data) != len(h.Hash) {
+		return nil, ErrNotHTLC
+	}
+	copy(h.Hash[:], ins[5].data)
+	if h.Recipient, err = utils.ParsePublicKey(ins[7].data); err != nil {
+		return nil, ErrNotHTLC
+	}
+	timeout, err := ins[9].number(MAX_LOCKTIME_SIZE)
+	if err != nil {
+		return nil, ErrNotHTLC
+	}
+	h.Timeout = int64(timeout)
+	if h.Refund, err = utils.ParsePublicKey(ins[12].data); err != nil {
+		return nil, ErrNotHTLC
+	}
+	//按模板重新生成,确认其余的操作码完全一致
+	rebuilt, err := h.Script()
+	if err != nil || !bytes.Equal(rebuilt, s) {
+		return nil, ErrNotHTLC
+	}
+	return h, nil
+}
+
+// 赎回的解锁脚本: <sig> <preimage> 1
+func RedeemHTLC(signature []byte, preimage []byte) Script {
+	s := AppendData(nil, signature)
+	s = AppendData(s, preimage)
+	return AppendNumber(s, 1)
+}
+
+// 退款的解锁脚本: <sig> 0
+func RefundHTLC(signature []byte) Script {
+	s := AppendData(nil, signature)
+	return AppendNumber(s, 0)
+}
+
+// 从赎回的解锁脚本中取出原像,不是赎回脚本或原像与哈希不符时返回false
+func (h *HTLC) Preimage(unlock Script) ([]byte, bool) {
+	ins, err := unlock.instructions()
+	if err != nil || len(ins) != 3 || ins[2].op != OP_1 {
+		return nil, false
+	}
+	preimage := ins[1].data
+	if len(preimage) != PREIMAGE_LEN || sha256.Sum256(preimage) != h.Hash {
+		return nil, false
+	}
+	return preimage, true
+}
@@ -0,0 +1,409 @@
+package script
+
+import (
+	"GoProject/utils"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// 资源限制
+const (
+	MAX_SCRIPT_SIZE          = 10000 //单个脚本的字节数
+	MAX_ELEMENT_SIZE         = 520   //单个栈元素的字节数
+	MAX_OPS                  = 201   //非压入操作码的数量
+	MAX_STACK_SIZE           = 1000  //栈中元素的数量
+	MAX_PUBKEYS_PER_MULTISIG = 20
+
+	SIGNATURE_LEN = 64 //R||S,各32字节
+
+	//小于该值的时间锁表示区块高度,否则表示unix时间(秒)
+	LOCKTIME_THRESHOLD = 500000000
+)
+
+var (
+	ErrScriptSize       = errors.New("script too large")
+	ErrElementSize      = errors.New("push exceeds element size limit")
+	ErrOpCount          = errors.New("too many operations")
+	ErrStackSize        = errors.New("stack size limit exceeded")
+	ErrPushOnly         = errors.New("unlock script is not push only")
+	ErrLockPushOnly     = errors.New("lock script has no operations")
+	ErrBadOpcode        = errors.New("unknown opcode")
+	ErrStackUnderflow   = errors.New("operation not valid with the current stack size")
+	ErrUnbalancedIf     = errors.New("unbalanced conditional")
+	ErrOpReturn         = errors.New("OP_RETURN was encountered")
+	ErrVerify           = errors.New("OP_VERIFY failed")
+	ErrEqualVerify      = errors.New("OP_EQUALVERIFY failed")
+	ErrCheckSigVerify   = errors.New("OP_CHECKSIGVERIFY failed")
+	ErrCheckMultisig    = errors.New("OP_CHECKMULTISIGVERIFY failed")
+	ErrPubKeyCount      = errors.New("invalid public key count")
+	ErrSigCount         = errors.New("invalid signature count")
+	ErrPubKey           = errors.New("invalid public key encoding")
+	ErrSignature        = errors.New("invalid signature encoding")
+	ErrNullFail         = errors.New("failing signature must be empty")
+	ErrNegativeLockTime = errors.New("negative locktime")
+	ErrLockTime         = errors.New("locktime requirement not satisfied")
+	ErrEvalFalse        = errors.New("script evaluated to false")
+)
+
+// 脚本执行的环境
+type Context struct {
+	SigHash [32]byte //签名的哈希值
+	Height  int64    //交易所在区块的高度
+	Time    int64    //交易所在区块之前的中位时间(unix秒)
+}
+
+type stack [][]byte
+
+func (st *stack) push(b []byte) {
+	*st = append(*st, b)
+}
+
+func (st *stack) pop() ([]byte, error) {
+	n := len(*st)
+	if n == 0 {
+		return nil, ErrStackUnderflow
+	}
+	b := (*st)[n-1]
+	*st = (*st)[:n-1]
+	return b, nil
+}
+
+func (st *stack) peek() ([]byte, error) {
+	if len(*st) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	return (*st)[len(*st)-1], nil
+}
+
+func (st *stack) popNum() (scriptNum, error) {
+	b, err := st.pop()
+	if err != nil {
+		return 0, err
+	}
+	return parseNum(b, MAX_NUM_SIZE)
+}
+
+// 先执行解锁脚本,再以其结果栈执行锁定脚本,栈顶为真时验证通过
+// 只有压入操作的锁定脚本任何人都能满足,不能用来锁定资金
+func Verify(unlock, lock Script, ctx *Context) error {
+	if _, err := unlock.instructions(); err != nil {
+		return fmt.Errorf("unlock script: %w", err)
+	}
+	if !unlock.IsPushOnly() {
+		return ErrPushOnly
+	}
+	if _, err := lock.instructions(); err == nil && lock.IsPushOnly() {
+		return ErrLockPushOnly
+	}
+	var st stack
+	if err := execute(unlock, &st, ctx); err != nil {
+		return fmt.Errorf("unlock script: %w", err)
+	}
+	if err := execute(lock, &st, ctx); err != nil {
+		return fmt.Errorf("lock script: %w", err)
+	}
+	top, err := st.peek()
+	if err != nil || !asBool(top) {
+		return ErrEvalFalse
+	}
+	return nil
+}
+
+func execute(s Script, st *stack, ctx *Context) error {
+	if len(s) > MAX_SCRIPT_SIZE {
+		return ErrScriptSize
+	}
+	ins, err := s.instructions()
+	if err != nil {
+		return err
+	}
+	var cond []bool //IF/ELSE的条件栈
+	ops := 0
+	for _, in := range ins {
+		op := in.op
+		if !op.valid() {
+			return fmt.Errorf("%w 0x%02x", ErrBadOpcode, byte(op))
+		}
+		if len(in.data) > MAX_ELEMENT_SIZE {
+			return ErrElementSize
+		}
+		if !op.isPush() {
+			ops += 1
+			if ops > MAX_OPS {
+				return ErrOpCount
+			}
+		}
+		executing := true
+		for _, c := range cond {
+			executing = executing && c
+		}
+		//未执行的分支只处理条件语句
+		if !executing && (op < OP_IF || op > OP_ENDIF) {
+			continue
+		}
+		if err := step(in, st, &cond, executing, &ops, ctx); err != nil {
+			return err
+		}
+		if len(*st) > MAX_STACK_SIZE {
+			return ErrStackSize
+		}
+	}
+	if len(cond) != 0 {
+		return ErrUnbalancedIf
+	}
+	return nil
+}
+
+func step(in instruction, st *stack, cond *[]bool, executing bool, ops *int, ctx *Context) error {
+	switch op := in.op; {
+	case op <= OP_PUSHDATA2:
+		st.push(in.data)
+	case op == OP_1NEGATE:
+		st.push(scriptNum(-1).Bytes())
+	case op >= OP_1 && op <= OP_16:
+		st.push(scriptNum(op - OP_1 + 1).Bytes())
+	case op == OP_NOP:
+	case op == OP_IF || op == OP_NOTIF:
+		v := false
+		if executing {
+			b, err := st.pop()
+			if err != nil {
+				return err
+			}
+			v = asBool(b) == (op == OP_IF)
+		}
+		*cond = append(*cond, v)
+	case op == OP_ELSE:
+		if len(*cond) == 0 {
+			return ErrUnbalancedIf
+		}
+		(*cond)[len(*cond)-1] = !(*cond)[len(*cond)-1]
+	case op == OP_ENDIF:
+		if len(*cond) == 0 {
+			return ErrUnbalancedIf
+		}
+		*cond = (*cond)[:len(*cond)-1]
+	case op == OP_VERIFY:
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		if !asBool(b) {
+			return ErrVerify
+		}
+	case op == OP_RETURN:
+		return ErrOpReturn
+	case op == OP_DROP:
+		_, err := st.pop()
+		return err
+	case op == OP_DUP:
+		b, err := st.peek()
+		if err != nil {
+			return err
+		}
+		st.push(b)
+	case op == OP_SWAP:
+		a, err := st.pop()
+		if err != nil {
+			return err
+		}
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		st.push(a)
+		st.push(b)
+	case op == OP_SIZE:
+		b, err := st.peek()
+		if err != nil {
+			return err
+		}
+		st.push(scriptNum(len(b)).Bytes())
+	case op == OP_EQUAL || op == OP_EQUALVERIFY:
+		a, err := st.pop()
+		if err != nil {
+			return err
+		}
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		equal := bytes.Equal(a, b)
+		if op == OP_EQUALVERIFY {
+			if !equal {
+				return ErrEqualVerify
+			}
+			return nil
+		}
+		st.push(fromBool(equal))
+	case op == OP_SHA256:
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(b)
+		st.push(h[:])
+	case op == OP_HASH160:
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(b)
+		r := ripemd160.New()
+		r.Write(h[:])
+		st.push(r.Sum(nil))
+	case op == OP_CHECKSIG || op == OP_CHECKSIGVERIFY:
+		pub, err := st.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := st.pop()
+		if err != nil {
+			return err
+		}
+		ok, err := checkSig(sig, pub, ctx)
+		if err != nil {
+			return err
+		}
+		if op == OP_CHECKSIGVERIFY {
+			if !ok {
+				return ErrCheckSigVerify
+			}
+			return nil
+		}
+		st.push(fromBool(ok))
+	case op == OP_CHECKMULTISIG || op == OP_CHECKMULTISIGVERIFY:
+		ok, err := checkMultisig(st, ops, ctx)
+		if err != nil {
+			return err
+		}
+		if op == OP_CHECKMULTISIGVERIFY {
+			if !ok {
+				return ErrCheckMultisig
+			}
+			return nil
+		}
+		st.push(fromBool(ok))
+	case op == OP_CHECKLOCKTIMEVERIFY:
+		b, err := st.peek()
+		if err != nil {
+			return err
+		}
+		return checkLockTime(b, ctx)
+	default:
+		return fmt.Errorf("%w 0x%02x", ErrBadOpcode, byte(op))
+	}
+	return nil
+}
+
+// 验证签名,签名为空时返回false,签名非空但无效时返回ErrNullFail
+func checkSig(sig, pub []byte, ctx *Context) (bool, error) {
+	key, err := utils.ParsePublicKey(pub)
+	if err != nil {
+		return false, ErrPubKey
+	}
+	if len(sig) == 0 {
+		return false, nil
+	}
+	if len(sig) != SIGNATURE_LEN {
+		return false, ErrSignature
+	}
+	r := new(big.Int).SetBytes(sig[:SIGNATURE_LEN/2])
+	s := new(big.Int).SetBytes(sig[SIGNATURE_LEN/2:])
+	if !ecdsa.Verify(key, ctx.SigHash[:], r, s) {
+		return false, ErrNullFail
+	}
+	return true, nil
+}
+
+// 栈: sig_1 ... sig_m m pub_1 ... pub_n n
+// 与比特币不同,没有多余的空元素;签名必须按公钥的顺序排列
+func checkMultisig(st *stack, ops *int, ctx *Context) (bool, error) {
+	n, err := st.popNum()
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > MAX_PUBKEYS_PER_MULTISIG {
+		return false, ErrPubKeyCount
+	}
+	*ops += int(n)
+	if *ops > MAX_OPS {
+		return false, ErrOpCount
+	}
+	pubs := make([][]byte, n)
+	for i := int(n) - 1; i >= 0; i-- {
+		if pubs[i], err = st.pop(); err != nil {
+			return false, err
+		}
+	}
+	m, err := st.popNum()
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, ErrSigCount
+	}
+	sigs := make([][]byte, m)
+	for i := int(m) - 1; i >= 0; i-- {
+		if sigs[i], err = st.pop(); err != nil {
+			return false, err
+		}
+	}
+	//每个签名依次与剩余的公钥匹配
+	ok := true
+	k := 0
+	for _, sig := range sigs {
+		matched := false
+		for k < len(pubs) && !matched {
+			key, err := utils.ParsePublicKey(pubs[k])
+			if err != nil {
+				return false, ErrPubKey
+			}
+			k += 1
+			if len(sig) == SIGNATURE_LEN {
+				r := new(big.Int).SetBytes(sig[:SIGNATURE_LEN/2])
+				s := new(big.Int).SetBytes(sig[SIGNATURE_LEN/2:])
+				matched = ecdsa.Verify(key, ctx.SigHash[:], r, s)
+			}
+		}
+		if !matched {
+			ok = false
+			break
+		}
+	}
+	if !ok {
+		for _, sig := range sigs {
+			if len(sig) != 0 {
+				return false, ErrNullFail
+			}
+		}
+	}
+	return ok, nil
+}
+
+// 栈顶的时间锁不出栈,需要时由OP_DROP移除
+func checkLockTime(b []byte, ctx *Context) error {
+	lockTime, err := parseNum(b, MAX_LOCKTIME_SIZE)
+	if err != nil {
+		return err
+	}
+	if lockTime < 0 {
+		return ErrNegativeLockTime
+	}
+	if lockTime < LOCKTIME_THRESHOLD {
+		if int64(lockTime) > ctx.Height {
+			return fmt.Errorf("%w: height %d < %d", ErrLockTime, ctx.Height, lockTime)
+		}
+		return nil
+	}
+	if int64(lockTime) > ctx.Time {
+		return fmt.Errorf("%w: time %d < %d", ErrLockTime, ctx.Time, lockTime)
+	}
+	return nil
+}
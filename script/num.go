@@ -0,0 +1,92 @@
+package script
+
+import "errors"
+
+const (
+	MAX_NUM_SIZE      = 4 //算术运算的数字最多4个字节
+	MAX_LOCKTIME_SIZE = 5 //时间锁的数字最多5个字节
+)
+
+var ErrNumber = errors.New("invalid script number")
+
+// 脚本中的数字: 小端序,最高字节的最高位为符号位,必须是最短编码
+type scriptNum int64
+
+func (n scriptNum) Bytes() []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	negative := n < 0
+	abs := int64(n)
+	if negative {
+		abs = -abs
+	}
+	var b []byte
+	for abs > 0 {
+		b = append(b, byte(abs&0xff))
+		abs >>= 8
+	}
+	//最高位已被占用时追加一个字节存放符号
+	if b[len(b)-1]&0x80 != 0 {
+		if negative {
+			b = append(b, 0x80)
+		} else {
+			b = append(b, 0x00)
+		}
+	} else if negative {
+		b[len(b)-1] |= 0x80
+	}
+	return b
+}
+
+func parseNum(b []byte, maxSize int) (scriptNum, error) {
+	if len(b) > maxSize {
+		return 0, ErrNumber
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	//最高字节只有符号位时,次高字节的最高位必须为1,否则不是最短编码
+	if b[len(b)-1]&0x7f == 0 && (len(b) == 1 || b[len(b)-2]&0x80 == 0) {
+		return 0, ErrNumber
+	}
+	var v int64
+	for i, c := range b {
+		v |= int64(c) << (8 * uint(i))
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		v &^= int64(0x80) << (8 * uint(len(b)-1))
+		v = -v
+	}
+	return scriptNum(v), nil
+}
+
+// 压入数字的指令的值
+func (in instruction) number(maxSize int) (scriptNum, error) {
+	switch {
+	case in.op == OP_1NEGATE:
+		return -1, nil
+	case in.op >= OP_1 && in.op <= OP_16:
+		return scriptNum(in.op-OP_1) + 1, nil
+	case in.op <= OP_PUSHDATA2:
+		return parseNum(in.data, maxSize)
+	}
+	return 0, ErrNumber
+}
+
+// 栈元素转换为布尔值: 全0(包括负0)为false
+func asBool(b []byte) bool {
+	for i, c := range b {
+		if c != 0 {
+			return !(i == len(b)-1 && c == 0x80)
+		}
+	}
+	return false
+}
+
+func fromBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return []byte{}
+}
@@ -0,0 +1,114 @@
+package script
+
+import "strconv"
+
+// 操作码,取值与比特币脚本相同
+type Opcode byte
+
+const (
+	//数据
+	OP_0         Opcode = 0x00 //压入空字节串
+	OP_DATA_75   Opcode = 0x4b //0x01~0x4b: 压入接下来的n个字节
+	OP_PUSHDATA1 Opcode = 0x4c //接下来1个字节为数据长度
+	OP_PUSHDATA2 Opcode = 0x4d //接下来2个字节(小端)为数据长度
+	OP_1NEGATE   Opcode = 0x4f
+	OP_1         Opcode = 0x51 //0x51~0x60: 压入数字1~16
+	OP_16        Opcode = 0x60
+
+	//流程控制
+	OP_NOP    Opcode = 0x61
+	OP_IF     Opcode = 0x63
+	OP_NOTIF  Opcode = 0x64
+	OP_ELSE   Opcode = 0x67
+	OP_ENDIF  Opcode = 0x68
+	OP_VERIFY Opcode = 0x69
+	OP_RETURN Opcode = 0x6a
+
+	//栈操作
+	OP_DROP Opcode = 0x75
+	OP_DUP  Opcode = 0x76
+	OP_SWAP Opcode = 0x7c
+	OP_SIZE Opcode = 0x82
+
+	//比较
+	OP_EQUAL       Opcode = 0x87
+	OP_EQUALVERIFY Opcode = 0x88
+
+	//哈希
+	OP_SHA256  Opcode = 0xa8
+	OP_HASH160 Opcode = 0xa9
+
+	//签名
+	OP_CHECKSIG            Opcode = 0xac
+	OP_CHECKSIGVERIFY      Opcode = 0xad
+	OP_CHECKMULTISIG       Opcode = 0xae
+	OP_CHECKMULTISIGVERIFY Opcode = 0xaf
+
+	//时间锁
+	OP_CHECKLOCKTIMEVERIFY Opcode = 0xb1
+)
+
+var opcodeNames = map[Opcode]string{
+	OP_0:                   "OP_0",
+	OP_PUSHDATA1:           "OP_PUSHDATA1",
+	OP_PUSHDATA2:           "OP_PUSHDATA2",
+	OP_1NEGATE:             "OP_1NEGATE",
+	OP_NOP:                 "OP_NOP",
+	OP_IF:                  "OP_IF",
+	OP_NOTIF:               "OP_NOTIF",
+	OP_ELSE:                "OP_ELSE",
+	OP_ENDIF:               "OP_ENDIF",
+	OP_VERIFY:              "OP_VERIFY",
+	OP_RETURN:              "OP_RETURN",
+	OP_DROP:                "OP_DROP",
+	OP_DUP:                 "OP_DUP",
+	OP_SWAP:                "OP_SWAP",
+	OP_SIZE:                "OP_SIZE",
+	OP_EQUAL:               "OP_EQUAL",
+	OP_EQUALVERIFY:         "OP_EQUALVERIFY",
+	OP_SHA256:              "OP_SHA256",
+	OP_HASH160:             "OP_HASH160",
+	OP_CHECKSIG:            "OP_CHECKSIG",
+	OP_CHECKSIGVERIFY:      "OP_CHECKSIGVERIFY",
+	OP_CHECKMULTISIG:       "OP_CHECKMULTISIG",
+	OP_CHECKMULTISIGVERIFY: "OP_CHECKMULTISIGVERIFY",
+	OP_CHECKLOCKTIMEVERIFY: "OP_CHECKLOCKTIMEVERIFY",
+}
+
+var opcodesByName = func() map[string]Opcode {
+	m := make(map[string]Opcode)
+	for op, name := range opcodeNames {
+		m[name] = op
+	}
+	for i := 1; i <= 16; i++ {
+		m["OP_"+strconv.Itoa(i)] = OP_1 + Opcode(i-1)
+	}
+	m["OP_FALSE"] = OP_0
+	m["OP_TRUE"] = OP_1
+	m["OP_CLTV"] = OP_CHECKLOCKTIMEVERIFY
+	return m
+}()
+
+func (op Opcode) String() string {
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+	if op >= OP_1 && op <= OP_16 {
+		return "OP_" + strconv.Itoa(int(op-OP_1)+1)
+	}
+	return "OP_UNKNOWN"
+}
+
+// 是否为压入数据或数字的操作码,不计入操作数限制
+func (op Opcode) isPush() bool {
+	return op <= OP_PUSHDATA2 || op == OP_1NEGATE || (op >= OP_1 && op <= OP_16)
+}
+
+// 是否为已定义的操作码
+func (op Opcode) valid() bool {
+	if op.isPush() {
+		return true
+	}
+	_, ok := opcodeNames[op]
+	return ok
+}
@@ -0,0 +1,172 @@
+package script
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrMalformed = errors.New("malformed push")
+
+// 锁定脚本或解锁脚本的字节码
+type Script []byte
+
+// 一条指令: 操作码和压入的数据
+type instruction struct {
+	op   Opcode
+	data []byte
+}
+
+// 由十六进制解析脚本
+func FromHex(s string) (Script, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script hex: %v", err)
+	}
+	return Script(b), nil
+}
+
+func (s Script) Hex() string {
+	return hex.EncodeToString(s)
+}
+
+// 拆分为指令,压入的数据超出脚本末尾时返回ErrMalformed
+func (s Script) instructions() ([]instruction, error) {
+	var ins []instruction
+	for i := 0; i < len(s); {
+		op := Opcode(s[i])
+		i += 1
+		n := 0
+		switch {
+		case op > OP_0 && op <= OP_DATA_75:
+			n = int(op)
+		case op == OP_PUSHDATA1:
+			if i+1 > len(s) {
+				return nil, ErrMalformed
+			}
+			n = int(s[i])
+			i += 1
+		case op == OP_PUSHDATA2:
+			if i+2 > len(s) {
+				return nil, ErrMalformed
+			}
+			n = int(binary.LittleEndian.Uint16(s[i:]))
+			i += 2
+		}
+		if i+n > len(s) {
+			return nil, ErrMalformed
+		}
+		ins = append(ins, instruction{op: op, data: s[i : i+n]})
+		i += n
+	}
+	return ins, nil
+}
+
+// 解锁脚本只能包含压入操作
+func (s Script) IsPushOnly() bool {
+	ins, err := s.instructions()
+	if err != nil {
+		return false
+	}
+	for _, in := range ins {
+		if !in.op.isPush() {
+			return false
+		}
+	}
+	return true
+}
+
+// 反汇编: 数字显示为十进制,数据显示为0x开头的十六进制
+func (s Script) String() string {
+	ins, err := s.instructions()
+	if err != nil {
+		return "[error: " + err.Error() + "]"
+	}
+	parts := make([]string, len(ins))
+	for i, in := range ins {
+		switch {
+		case in.op == OP_0:
+			parts[i] = "0"
+		case in.op == OP_1NEGATE:
+			parts[i] = "-1"
+		case in.op >= OP_1 && in.op <= OP_16:
+			parts[i] = strconv.Itoa(int(in.op-OP_1) + 1)
+		case in.op <= OP_PUSHDATA2:
+			parts[i] = "0x" + hex.EncodeToString(in.data)
+			//可以作为数字重新汇编的短数据显示为十进制
+			if n, err := parseNum(in.data, MAX_NUM_SIZE); err == nil &&
+				bytes.Equal(AppendNumber(nil, int64(n)), AppendData(nil, in.data)) {
+				parts[i] = strconv.FormatInt(int64(n), 10)
+			}
+		default:
+			parts[i] = in.op.String()
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// 汇编,例如 "OP_DUP OP_HASH160 0x89ab... OP_EQUALVERIFY OP_CHECKSIG"
+// 十进制数字压入脚本数字,0x开头为压入数据,操作码可以省略OP_前缀
+func Parse(asm string) (Script, error) {
+	var s Script
+	for _, tok := range strings.Fields(asm) {
+		if strings.HasPrefix(tok, "0x") || strings.HasPrefix(tok, "0X") {
+			data, err := hex.DecodeString(tok[2:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid data %q: %v", tok, err)
+			}
+			if len(data) > MAX_ELEMENT_SIZE {
+				return nil, fmt.Errorf("data %q: %w", tok, ErrElementSize)
+			}
+			s = AppendData(s, data)
+			continue
+		}
+		if n, err := strconv.ParseInt(tok, 10, 64); err == nil {
+			s = AppendNumber(s, n)
+			continue
+		}
+		name := strings.ToUpper(tok)
+		if !strings.HasPrefix(name, "OP_") {
+			name = "OP_" + name
+		}
+		op, ok := opcodesByName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown opcode %q", tok)
+		}
+		s = append(s, byte(op))
+	}
+	return s, nil
+}
+
+// 追加压入数据的指令,使用最短的压入方式
+func AppendData(s Script, data []byte) Script {
+	n := len(data)
+	switch {
+	case n == 0:
+		return append(s, byte(OP_0))
+	case n <= int(OP_DATA_75):
+		s = append(s, byte(n))
+	case n <= 0xff:
+		s = append(s, byte(OP_PUSHDATA1), byte(n))
+	default:
+		s = append(s, byte(OP_PUSHDATA2), byte(n), byte(n>>8))
+	}
+	return append(s, data...)
+}
+
+// 追加压入数字的指令,-1和0~16使用单字节操作码
+func AppendNumber(s Script, n int64) Script {
+	switch {
+	case n == 0:
+		return append(s, byte(OP_0))
+	case n == -1:
+		return append(s, byte(OP_1NEGATE))
+	case n >= 1 && n <= 16:
+		return append(s, byte(OP_1+Opcode(n-1)))
+	}
+	return AppendData(s, scriptNum(n).Bytes())
+}
@@ -0,0 +1,149 @@
+package script
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 测试向量: 解锁脚本、锁定脚本、执行环境和期望的结果
+// 脚本为汇编格式,无法用汇编表示的脚本使用十六进制(unlock_hex/lock_hex)
+type vector struct {
+	Unlock    string `json:"unlock"`
+	Lock      string `json:"lock"`
+	UnlockHex string `json:"unlock_hex,omitempty"`
+	LockHex   string `json:"lock_hex,omitempty"`
+	SigHash   string `json:"sighash,omitempty"`
+	Height    int64  `json:"height,omitempty"`
+	Time      int64  `json:"time,omitempty"`
+	Error     string `json:"error"` //OK表示验证通过
+	Comment   string `json:"comment"`
+}
+
+// 测试向量中的错误名
+var vectorErrors = map[string]error{
+	"EVAL_FALSE":              ErrEvalFalse,
+	"SCRIPT_SIZE":             ErrScriptSize,
+	"PUSH_SIZE":               ErrElementSize,
+	"OP_COUNT":                ErrOpCount,
+	"STACK_SIZE":              ErrStackSize,
+	"PUSH_ONLY":               ErrPushOnly,
+	"LOCK_PUSH_ONLY":          ErrLockPushOnly,
+	"BAD_OPCODE":              ErrBadOpcode,
+	"MALFORMED_PUSH":          ErrMalformed,
+	"INVALID_STACK_OPERATION": ErrStackUnderflow,
+	"UNBALANCED_CONDITIONAL":  ErrUnbalancedIf,
+	"OP_RETURN":               ErrOpReturn,
+	"VERIFY":                  ErrVerify,
+	"EQUALVERIFY":             ErrEqualVerify,
+	"CHECKSIGVERIFY":          ErrCheckSigVerify,
+	"CHECKMULTISIGVERIFY":     ErrCheckMultisig,
+	"PUBKEY_COUNT":            ErrPubKeyCount,
+	"SIG_COUNT":               ErrSigCount,
+	"PUBKEY_ENCODING":         ErrPubKey,
+	"SIG_ENCODING":            ErrSignature,
+	"NULLFAIL":                ErrNullFail,
+	"NEGATIVE_LOCKTIME":       ErrNegativeLockTime,
+	"UNSATISFIED_LOCKTIME":    ErrLockTime,
+	"NUMBER":                  ErrNumber,
+}
+
+// testdata中的测试向量,资源限制的向量由程序生成
+func loadVectors(t *testing.T) []*vector {
+	data, err := os.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []*vector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	return append(vectors, limitVectors()...)
+}
+
+func limitVectors() []*vector {
+	repeat := func(tok string, n int) string {
+		return strings.TrimSpace(strings.Repeat(tok+" ", n))
+	}
+	push := func(n int) string {
+		return hex.EncodeToString(AppendData(nil, make([]byte, n)))
+	}
+	//由最大的元素和OP_DROP组成,用OP_NOP补足n个字节
+	sized := func(n int) string {
+		unit := push(MAX_ELEMENT_SIZE) + "75"
+		count := n / (len(unit) / 2)
+		if count*(len(unit)/2) >= n-1 {
+			count -= 1
+		}
+		return strings.Repeat(unit, count) + strings.Repeat("61", n-count*len(unit)/2)
+	}
+	return []*vector{
+		{Unlock: "1", Lock: repeat("OP_NOP", MAX_OPS), Error: "OK", Comment: "operation limit"},
+		{Unlock: "1", Lock: repeat("OP_NOP", MAX_OPS+1), Error: "OP_COUNT", Comment: "operation limit exceeded"},
+		{Unlock: "1", Lock: "OP_NOP " + repeat("1", MAX_STACK_SIZE-1), Error: "OK", Comment: "stack size limit"},
+		{Unlock: "1", Lock: "OP_NOP " + repeat("1", MAX_STACK_SIZE), Error: "STACK_SIZE", Comment: "stack size limit exceeded"},
+		{UnlockHex: push(MAX_ELEMENT_SIZE), Lock: "OP_SIZE 520 OP_EQUAL", Error: "OK", Comment: "element size limit"},
+		{UnlockHex: push(MAX_ELEMENT_SIZE + 1), Lock: "OP_SIZE 521 OP_EQUAL", Error: "PUSH_SIZE", Comment: "element size limit exceeded"},
+		{Unlock: "1", LockHex: sized(MAX_SCRIPT_SIZE), Error: "OK", Comment: "script size limit"},
+		{Unlock: "1", LockHex: sized(MAX_SCRIPT_SIZE + 1), Error: "SCRIPT_SIZE", Comment: "script size limit exceeded"},
+	}
+}
+
+func (v *vector) scripts() (unlock, lock Script, err error) {
+	build := func(asm, h string) (Script, error) {
+		if h != "" {
+			return FromHex(h)
+		}
+		return Parse(asm)
+	}
+	if unlock, err = build(v.Unlock, v.UnlockHex); err != nil {
+		return nil, nil, err
+	}
+	if lock, err = build(v.Lock, v.LockHex); err != nil {
+		return nil, nil, err
+	}
+	return unlock, lock, nil
+}
+
+// 执行一个测试向量,结果与期望不同时返回错误
+func (v *vector) run() error {
+	unlock, lock, err := v.scripts()
+	if err != nil {
+		return err
+	}
+	ctx := &Context{Height: v.Height, Time: v.Time}
+	if v.SigHash != "" {
+		h, err := hex.DecodeString(v.SigHash)
+		if err != nil || len(h) != len(ctx.SigHash) {
+			return fmt.Errorf("invalid sighash %q", v.SigHash)
+		}
+		copy(ctx.SigHash[:], h)
+	}
+	got := Verify(unlock, lock, ctx)
+	if v.Error == "OK" {
+		if got != nil {
+			return fmt.Errorf("expected OK, got %v", got)
+		}
+		return nil
+	}
+	want, ok := vectorErrors[v.Error]
+	if !ok {
+		return fmt.Errorf("unknown error name %q", v.Error)
+	}
+	if !errors.Is(got, want) {
+		return fmt.Errorf("expected %s, got %v", v.Error, got)
+	}
+	return nil
+}
+
+func TestVectors(t *testing.T) {
+	for i, v := range loadVectors(t) {
+		if err := v.run(); err != nil {
+			t.Errorf("vector %d (%s): %v", i, v.Comment, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"GoProject/address"
+	"GoProject/params"
+	"GoProject/script"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func init() {
+	log.SetPrefix("Script Tool: ")
+	log.SetFlags(0)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: script_tool [flags] <command> [args]
+
+Commands:
+  asm <script>     assemble a script and print its hex and address
+  disasm <hex>     disassemble a script
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+// 汇编、反汇编脚本
+func main() {
+	network := flag.String("network", "mainnet", "Network of script addresses: mainnet, testnet or regtest")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	args := strings.Join(flag.Args()[1:], " ")
+
+	switch flag.Arg(0) {
+	case "asm":
+		p, err := params.ByName(*network)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		s, err := script.Parse(args)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		fmt.Printf("hex     %s\naddress %s\n", s.Hex(), address.FromScript(s, p))
+	case "disasm":
+		s, err := script.FromHex(strings.ReplaceAll(args, " ", ""))
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		fmt.Println(s)
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
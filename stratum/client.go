@@ -0,0 +1,224 @@
+package stratum
+
+import (
+	"GoProject/block"
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// 简单的测试矿工: 订阅任务,搜索满足份额难度的nonce并提交
+type Client struct {
+	conn        net.Conn
+	worker      string
+	extranonce1 uint32
+	difficulty  int
+
+	jobs    chan *Job
+	pending map[int]chan *Response
+	nextId  int
+	mux     sync.Mutex
+}
+
+// 服务端下发的任务
+type Job struct {
+	Id           string
+	PreviousHash [32]byte
+	StateRoot    [32]byte
+	Transactions []*block.Transaction
+	Difficulty   int
+	Clean        bool
+}
+
+func Dial(address string, worker string) (*Client, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:    conn,
+		worker:  worker,
+		jobs:    make(chan *Job, 16),
+		pending: make(map[int]chan *Response),
+	}
+	go c.readLoop()
+
+	var sub []json.RawMessage
+	if err := c.call(METHOD_SUBSCRIBE, []interface{}{worker}, &sub); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if len(sub) < 2 {
+		conn.Close()
+		return nil, fmt.Errorf("invalid subscribe result")
+	}
+	var en1 string
+	if err := json.Unmarshal(sub[1], &en1); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	v, err := strconv.ParseUint(en1, 16, 32)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.extranonce1 = uint32(v)
+
+	var ok bool
+	if err := c.call(METHOD_AUTHORIZE, []interface{}{worker, ""}, &ok); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// 新任务的通道
+func (c *Client) Jobs() <-chan *Job {
+	return c.jobs
+}
+
+// 当前份额难度
+func (c *Client) Difficulty() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.difficulty
+}
+
+func (c *Client) call(method string, params interface{}, result interface{}) error {
+	p, _ := json.Marshal(params)
+	c.mux.Lock()
+	c.nextId += 1
+	id := c.nextId
+	ch := make(chan *Response, 1)
+	c.pending[id] = ch
+	c.mux.Unlock()
+
+	m, _ := json.Marshal(&Request{Id: &id, Method: method, Params: p})
+	if _, err := c.conn.Write(append(m, '\n')); err != nil {
+		return err
+	}
+	resp, ok := <-ch
+	if !ok {
+		return fmt.Errorf("connection closed")
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s: %v", method, resp.Error)
+	}
+	r, _ := json.Marshal(resp.Result)
+	return json.Unmarshal(r, result)
+}
+
+func (c *Client) readLoop() {
+	defer func() {
+		c.mux.Lock()
+		for id, ch := range c.pending {
+			close(ch)
+			delete(c.pending, id)
+		}
+		c.mux.Unlock()
+		close(c.jobs)
+	}()
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var msg struct {
+			Id     *int              `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+			Result interface{}       `json:"result"`
+			Error  interface{}       `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			log.Printf("ERROR: stratum client: %v", err)
+			return
+		}
+		switch {
+		case msg.Method == METHOD_SET_DIFFICULTY && len(msg.Params) > 0:
+			var d int
+			_ = json.Unmarshal(msg.Params[0], &d)
+			c.mux.Lock()
+			c.difficulty = d
+			c.mux.Unlock()
+		case msg.Method == METHOD_NOTIFY:
+			j, err := parseJob(msg.Params)
+			if err != nil {
+				log.Printf("ERROR: stratum client: %v", err)
+				continue
+			}
+			c.jobs <- j
+		case msg.Id != nil:
+			c.mux.Lock()
+			ch, ok := c.pending[*msg.Id]
+			delete(c.pending, *msg.Id)
+			c.mux.Unlock()
+			if ok {
+				ch <- &Response{Id: msg.Id, Result: msg.Result, Error: msg.Error}
+			}
+		}
+	}
+}
+
+func parseJob(params []json.RawMessage) (*Job, error) {
+	if len(params) < 6 {
+		return nil, fmt.Errorf("invalid notify params")
+	}
+	j := new(Job)
+	var previousHash, stateRoot string
+	if err := json.Unmarshal(params[0], &j.Id); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params[1], &previousHash); err != nil {
+		return nil, err
+	}
+	ph, err := hex.DecodeString(previousHash)
+	if err != nil || len(ph) != 32 {
+		return nil, fmt.Errorf("invalid previous hash")
+	}
+	copy(j.PreviousHash[:], ph)
+	if err := json.Unmarshal(params[2], &stateRoot); err != nil {
+		return nil, err
+	}
+	sr, err := hex.DecodeString(stateRoot)
+	if err != nil || len(sr) != 32 {
+		return nil, fmt.Errorf("invalid state root")
+	}
+	copy(j.StateRoot[:], sr)
+	if err := json.Unmarshal(params[3], &j.Transactions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params[4], &j.Difficulty); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params[5], &j.Clean); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// 从start开始搜索满足份额难度的extranonce2,最多尝试tries次
+func (c *Client) Search(j *Job, start uint32, tries uint32) (uint32, bool) {
+	difficulty := c.Difficulty()
+	for i := uint32(0); i < tries; i++ {
+		extranonce2 := start + i
+		nonce := JoinNonce(c.extranonce1, extranonce2)
+		if block.MeetsDifficulty(nonce, j.PreviousHash, j.StateRoot, j.Transactions, difficulty) {
+			return extranonce2, true
+		}
+	}
+	return 0, false
+}
+
+// 提交份额,服务端接受时返回nil
+func (c *Client) Submit(j *Job, extranonce2 uint32) error {
+	var ok bool
+	return c.call(METHOD_SUBMIT, []interface{}{c.worker, j.Id, fmt.Sprintf("%08x", extranonce2)}, &ok)
+}
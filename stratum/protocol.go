@@ -0,0 +1,52 @@
+package stratum
+
+import "encoding/json"
+
+// Stratum风格的JSON-RPC消息,每行一个JSON对象
+type Request struct {
+	Id     *int            `json:"id"` //通知消息的id为null
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type Response struct {
+	Id     *int        `json:"id"`
+	Result interface{} `json:"result"`
+	Error  interface{} `json:"error"`
+}
+
+// 服务端推送的通知
+type Notification struct {
+	Id     *int          `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+const (
+	METHOD_SUBSCRIBE      = "mining.subscribe"
+	METHOD_AUTHORIZE      = "mining.authorize"
+	METHOD_SUBMIT         = "mining.submit"
+	METHOD_NOTIFY         = "mining.notify"
+	METHOD_SET_DIFFICULTY = "mining.set_difficulty"
+
+	EXTRANONCE2_SIZE = 4 //矿工自己搜索的nonce部分(字节)
+)
+
+// 错误码与Stratum保持一致
+const (
+	ERR_OTHER          = 20
+	ERR_JOB_NOT_FOUND  = 21
+	ERR_DUPLICATE      = 22
+	ERR_LOW_DIFFICULTY = 23
+	ERR_UNAUTHORIZED   = 24
+	ERR_NOT_SUBSCRIBED = 25
+)
+
+func rpcError(code int, message string) []interface{} {
+	return []interface{}{code, message, nil}
+}
+
+// nonce由服务端分配的extranonce1(高32位)和矿工搜索的extranonce2(低32位)组成
+func JoinNonce(extranonce1 uint32, extranonce2 uint32) int {
+	return int(uint64(extranonce1)<<32 | uint64(extranonce2))
+}
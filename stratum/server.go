@@ -0,0 +1,313 @@
+package stratum
+
+import (
+	"GoProject/block"
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	JOB_REFRESH_SEC = 5 //检查交易池和主链末端变化的间隔
+	MAX_JOBS        = 8 //保留的历史任务数,更早的任务提交视为过期
+)
+
+// 下发给矿工的任务
+type job struct {
+	id       string
+	template *block.BlockTemplate
+	shares   map[int]bool //已提交的nonce,拒绝重复提交
+}
+
+// 把区块模板分发给外部矿工,验证份额并把满足区块难度的解提交到区块链
+type Server struct {
+	bc              *block.BlockChain
+	address         string
+	shareDifficulty int //份额难度,低于区块难度使矿工能更频繁地提交
+
+	clients     map[*client]bool
+	jobs        map[string]*job
+	jobOrder    []string
+	currentJob  *job
+	nextJobId   int
+	extranonce1 uint32
+	mux         sync.Mutex
+
+	acceptedShares int
+	rejectedShares int
+	blocksFound    int
+}
+
+type client struct {
+	conn        net.Conn
+	extranonce1 uint32
+	subscribed  bool
+	workers     map[string]bool
+	mux         sync.Mutex //保护conn的写入
+}
+
+// shareDifficulty为0时使用区块难度减1(最小为1),高于区块难度时降为区块难度,
+// 否则满足区块难度但不满足份额难度的解会被当作无效份额拒绝
+func NewServer(bc *block.BlockChain, address string, shareDifficulty int) *Server {
+	if shareDifficulty <= 0 {
+		shareDifficulty = bc.Params().Difficulty - 1
+		if shareDifficulty < 1 {
+			shareDifficulty = 1
+		}
+	}
+	shareDifficulty = min(shareDifficulty, bc.Params().Difficulty)
+	return &Server{
+		bc:              bc,
+		address:         address,
+		shareDifficulty: shareDifficulty,
+		clients:         make(map[*client]bool),
+		jobs:            make(map[string]*job),
+	}
+}
+
+// 监听TCP端口,会一直阻塞
+func (s *Server) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+	log.Printf("action=stratum_listen, address=%s", ln.Addr())
+	return s.Serve(ln)
+}
+
+func (s *Server) Serve(ln net.Listener) error {
+	s.refreshJob()
+	go func() {
+		for range time.Tick(time.Second * JOB_REFRESH_SEC) {
+			s.refreshJob()
+		}
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// 主链末端或交易池变化时生成新任务并通知所有矿工
+func (s *Server) refreshJob() {
+	tmpl := s.bc.NewBlockTemplate(s.bc.RewardAddress())
+	s.mux.Lock()
+	if s.currentJob != nil && sameWork(s.currentJob.template, tmpl) {
+		s.mux.Unlock()
+		return
+	}
+	clean := s.currentJob == nil || s.currentJob.template.PreviousHash != tmpl.PreviousHash
+	s.nextJobId += 1
+	j := &job{id: strconv.FormatInt(int64(s.nextJobId), 16), template: tmpl, shares: make(map[int]bool)}
+	s.jobs[j.id] = j
+	s.jobOrder = append(s.jobOrder, j.id)
+	//主链末端变化后之前的任务都已过期
+	if clean {
+		for _, id := range s.jobOrder[:len(s.jobOrder)-1] {
+			delete(s.jobs, id)
+		}
+		s.jobOrder = []string{j.id}
+	}
+	for len(s.jobOrder) > MAX_JOBS {
+		delete(s.jobs, s.jobOrder[0])
+		s.jobOrder = s.jobOrder[1:]
+	}
+	s.currentJob = j
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		if c.subscribed {
+			clients = append(clients, c)
+		}
+	}
+	s.mux.Unlock()
+	for _, c := range clients {
+		c.send(notifyMessage(j, clean))
+	}
+}
+
+// 两个模板的主链末端、状态根和交易完全相同,交易池被同样数量的其他交易替换时也能发现变化
+func sameWork(a *block.BlockTemplate, b *block.BlockTemplate) bool {
+	if a.PreviousHash != b.PreviousHash || a.StateRoot != b.StateRoot ||
+		len(a.Transactions) != len(b.Transactions) {
+		return false
+	}
+	for i, t := range a.Transactions {
+		if t.ID() != b.Transactions[i].ID() {
+			return false
+		}
+	}
+	return true
+}
+
+func notifyMessage(j *job, clean bool) *Notification {
+	transactions, _ := json.Marshal(j.template.Transactions)
+	return &Notification{
+		Method: METHOD_NOTIFY,
+		Params: []interface{}{
+			j.id,
+			fmt.Sprintf("%x", j.template.PreviousHash),
+			fmt.Sprintf("%x", j.template.StateRoot),
+			json.RawMessage(transactions),
+			j.template.Difficulty,
+			clean,
+		},
+	}
+}
+
+func (c *client) send(v interface{}) {
+	m, _ := json.Marshal(v)
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(append(m, '\n')); err != nil {
+		log.Printf("ERROR: stratum write: %v", err)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	s.mux.Lock()
+	s.extranonce1 += 1
+	c := &client{conn: conn, extranonce1: s.extranonce1, workers: make(map[string]bool)}
+	s.clients[c] = true
+	s.mux.Unlock()
+	defer func() {
+		s.mux.Lock()
+		delete(s.clients, c)
+		s.mux.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			log.Printf("ERROR: stratum: %v", err)
+			return
+		}
+		result, rpcErr := s.dispatch(c, &req)
+		c.send(&Response{Id: req.Id, Result: result, Error: rpcErr})
+		if req.Method == METHOD_SUBSCRIBE && rpcErr == nil {
+			s.mux.Lock()
+			j := s.currentJob
+			s.mux.Unlock()
+			c.send(&Notification{Method: METHOD_SET_DIFFICULTY, Params: []interface{}{s.shareDifficulty}})
+			if j != nil {
+				c.send(notifyMessage(j, true))
+			}
+		}
+	}
+}
+
+func (s *Server) dispatch(c *client, req *Request) (interface{}, interface{}) {
+	switch req.Method {
+	case METHOD_SUBSCRIBE:
+		s.mux.Lock()
+		c.subscribed = true
+		s.mux.Unlock()
+		return []interface{}{
+			fmt.Sprintf("%08x", c.extranonce1),
+			fmt.Sprintf("%08x", c.extranonce1),
+			EXTRANONCE2_SIZE,
+		}, nil
+	case METHOD_AUTHORIZE:
+		var params []string
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) < 1 {
+			return false, rpcError(ERR_OTHER, "invalid params")
+		}
+		c.workers[params[0]] = true
+		return true, nil
+	case METHOD_SUBMIT:
+		if !c.subscribed {
+			return false, rpcError(ERR_NOT_SUBSCRIBED, "not subscribed")
+		}
+		var params []string
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) < 3 {
+			return false, rpcError(ERR_OTHER, "invalid params")
+		}
+		if !c.workers[params[0]] {
+			return false, rpcError(ERR_UNAUTHORIZED, "unauthorized worker")
+		}
+		return s.submit(c, params[1], params[2])
+	}
+	return nil, rpcError(ERR_OTHER, "unknown method")
+}
+
+// 验证份额,满足区块难度时提交区块
+func (s *Server) submit(c *client, jobId string, extranonce2Hex string) (interface{}, interface{}) {
+	b, err := hex.DecodeString(extranonce2Hex)
+	if err != nil || len(b) != EXTRANONCE2_SIZE {
+		return false, rpcError(ERR_OTHER, "invalid extranonce2")
+	}
+	extranonce2 := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	nonce := JoinNonce(c.extranonce1, extranonce2)
+
+	s.mux.Lock()
+	j, ok := s.jobs[jobId]
+	if !ok {
+		s.rejectedShares += 1
+		s.mux.Unlock()
+		return false, rpcError(ERR_JOB_NOT_FOUND, "job not found")
+	}
+	if j.shares[nonce] {
+		s.rejectedShares += 1
+		s.mux.Unlock()
+		return false, rpcError(ERR_DUPLICATE, "duplicate share")
+	}
+	j.shares[nonce] = true
+	s.mux.Unlock()
+
+	tmpl := j.template
+	if !block.MeetsDifficulty(nonce, tmpl.PreviousHash, tmpl.StateRoot, tmpl.Transactions, s.shareDifficulty) {
+		s.mux.Lock()
+		s.rejectedShares += 1
+		s.mux.Unlock()
+		return false, rpcError(ERR_LOW_DIFFICULTY, "low difficulty share")
+	}
+	s.mux.Lock()
+	s.acceptedShares += 1
+	s.mux.Unlock()
+
+	//同时满足区块难度,提交完整区块
+	if block.MeetsDifficulty(nonce, tmpl.PreviousHash, tmpl.StateRoot, tmpl.Transactions, tmpl.Difficulty) {
+		if err := s.bc.SubmitBlock(tmpl.Block(nonce)); err != nil {
+			log.Printf("ERROR: stratum submit block: %v", err)
+		} else {
+			s.mux.Lock()
+			s.blocksFound += 1
+			s.mux.Unlock()
+			go s.refreshJob()
+		}
+	}
+	return true, nil
+}
+
+// 矿池统计
+type Stats struct {
+	Clients         int `json:"clients"`
+	ShareDifficulty int `json:"share_difficulty"`
+	AcceptedShares  int `json:"accepted_shares"`
+	RejectedShares  int `json:"rejected_shares"`
+	BlocksFound     int `json:"blocks_found"`
+}
+
+func (s *Server) Stats() *Stats {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return &Stats{
+		Clients:         len(s.clients),
+		ShareDifficulty: s.shareDifficulty,
+		AcceptedShares:  s.acceptedShares,
+		RejectedShares:  s.rejectedShares,
+		BlocksFound:     s.blocksFound,
+	}
+}
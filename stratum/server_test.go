@@ -0,0 +1,141 @@
+package stratum
+
+import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/params"
+	"net"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, bc *block.BlockChain, shareDifficulty int) (*Server, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := NewServer(bc, "", shareDifficulty)
+	go s.Serve(ln)
+	return s, ln.Addr().String()
+}
+
+func nextJob(t *testing.T, c *Client) *Job {
+	t.Helper()
+	select {
+	case j := <-c.Jobs():
+		return j
+	case <-time.After(5 * time.Second):
+		t.Fatal("no job received")
+	}
+	return nil
+}
+
+// 第一个满足条件的extranonce2
+func findExtranonce2(c *Client, match func(nonce int) bool) uint32 {
+	for e2 := uint32(0); ; e2++ {
+		if match(JoinNonce(c.extranonce1, e2)) {
+			return e2
+		}
+	}
+}
+
+func TestShares(t *testing.T) {
+	bc := block.NewBlockChain((&address.Address{Version: params.TestNet.AddressVersion}).String(), 0, params.TestNet)
+	s, addr := startServer(t, bc, 1)
+	c, err := Dial(addr, "worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	j := nextJob(t, c)
+	if c.Difficulty() != 1 || j.Difficulty != params.TestNet.Difficulty {
+		t.Fatalf("share difficulty %d, block difficulty %d", c.Difficulty(), j.Difficulty)
+	}
+	meets := func(nonce int, difficulty int) bool {
+		return block.MeetsDifficulty(nonce, j.PreviousHash, j.StateRoot, j.Transactions, difficulty)
+	}
+
+	//满足份额难度但不满足区块难度
+	share := findExtranonce2(c, func(n int) bool { return meets(n, 1) && !meets(n, j.Difficulty) })
+	if err := c.Submit(j, share); err != nil {
+		t.Fatalf("valid share rejected: %v", err)
+	}
+	if err := c.Submit(j, share); err == nil {
+		t.Error("duplicate share accepted")
+	}
+	low := findExtranonce2(c, func(n int) bool { return !meets(n, 1) })
+	if err := c.Submit(j, low); err == nil {
+		t.Error("low difficulty share accepted")
+	}
+	if err := c.Submit(&Job{Id: "unknown"}, share); err == nil {
+		t.Error("share for an unknown job accepted")
+	}
+	var ok bool
+	if err := c.call(METHOD_SUBMIT, []interface{}{"other", j.Id, "00000000"}, &ok); err == nil {
+		t.Error("share from an unauthorized worker accepted")
+	}
+	if stats := s.Stats(); stats.AcceptedShares != 1 || stats.RejectedShares != 3 || stats.BlocksFound != 0 {
+		t.Errorf("stats %+v", stats)
+	}
+
+	//满足区块难度的份额作为区块提交
+	solution := findExtranonce2(c, func(n int) bool { return meets(n, j.Difficulty) })
+	if err := c.Submit(j, solution); err != nil {
+		t.Fatalf("block solution rejected: %v", err)
+	}
+	if stats := s.Stats(); stats.BlocksFound != 1 || len(bc.Chain()) != 2 {
+		t.Errorf("stats %+v, chain has %d blocks", stats, len(bc.Chain()))
+	}
+	//新的主链末端产生新任务
+	if next := nextJob(t, c); next.PreviousHash != bc.LastBlock().Hash() || !next.Clean {
+		t.Errorf("job after the block does not build on the new tip")
+	}
+}
+
+// 份额难度默认为区块难度减1,且不能高于区块难度
+func TestShareDifficulty(t *testing.T) {
+	tests := []struct {
+		name            string
+		p               *params.Params
+		shareDifficulty int
+		want            int
+	}{
+		{"mainnet default", params.MainNet, 0, params.MainNet.Difficulty - 1},
+		{"regtest default", params.RegTest, 0, 1},
+		{"negative", params.MainNet, -1, params.MainNet.Difficulty - 1},
+		{"below block", params.MainNet, 1, 1},
+		{"equal to block", params.MainNet, params.MainNet.Difficulty, params.MainNet.Difficulty},
+		{"above block", params.MainNet, params.MainNet.Difficulty + 2, params.MainNet.Difficulty},
+		{"above regtest block", params.RegTest, 5, params.RegTest.Difficulty},
+	}
+	for _, tt := range tests {
+		s := NewServer(block.NewBlockChain("miner", 0, tt.p), "", tt.shareDifficulty)
+		if s.shareDifficulty != tt.want {
+			t.Errorf("%s: share difficulty %d, want %d", tt.name, s.shareDifficulty, tt.want)
+		}
+	}
+}
+
+// 交易池中的交易被同样数量的其他交易替换时产生新任务
+func TestSameWork(t *testing.T) {
+	tx := func(value float32) *block.Transaction { return block.NewTransaction("alice", "bob", value) }
+	base := &block.BlockTemplate{Transactions: []*block.Transaction{tx(1), tx(2)}}
+	tests := []struct {
+		name  string
+		other *block.BlockTemplate
+		same  bool
+	}{
+		{"identical", &block.BlockTemplate{Transactions: []*block.Transaction{tx(1), tx(2)}}, true},
+		{"replaced", &block.BlockTemplate{Transactions: []*block.Transaction{tx(1), tx(3)}}, false},
+		{"reordered", &block.BlockTemplate{Transactions: []*block.Transaction{tx(2), tx(1)}}, false},
+		{"fewer", &block.BlockTemplate{Transactions: []*block.Transaction{tx(1)}}, false},
+		{"other tip", &block.BlockTemplate{PreviousHash: [32]byte{1}, Transactions: []*block.Transaction{tx(1), tx(2)}}, false},
+		{"other state", &block.BlockTemplate{StateRoot: [32]byte{1}, Transactions: []*block.Transaction{tx(1), tx(2)}}, false},
+	}
+	for _, tt := range tests {
+		if got := sameWork(base, tt.other); got != tt.same {
+			t.Errorf("%s: same work %v", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"GoProject/stratum"
+	"flag"
+	"log"
+)
+
+func init() {
+	log.SetPrefix("Stratum Miner: ")
+}
+
+// 用于测试矿池的本地矿工
+func main() {
+	server := flag.String("server", "127.0.0.1:3333", "Stratum server address")
+	worker := flag.String("worker", "worker1", "Worker name")
+	shares := flag.Int("shares", 0, "Exit after this many accepted shares (0: run forever)")
+	flag.Parse()
+
+	c, err := stratum.Dial(*server, *worker)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	defer c.Close()
+
+	accepted := 0
+	j, ok := <-c.Jobs()
+	var next uint32
+	for ok {
+		select {
+		case newJob, open := <-c.Jobs():
+			//收到新任务后从头开始搜索
+			j, ok = newJob, open
+			next = 0
+			continue
+		default:
+		}
+		extranonce2, found := c.Search(j, next, 1000)
+		next += 1000
+		if !found {
+			continue
+		}
+		next = extranonce2 + 1
+		if err := c.Submit(j, extranonce2); err != nil {
+			log.Printf("share rejected: %v", err)
+			continue
+		}
+		accepted += 1
+		log.Printf("share accepted job=%s extranonce2=%08x", j.Id, extranonce2)
+		if *shares > 0 && accepted >= *shares {
+			return
+		}
+	}
+}
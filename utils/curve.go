@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// 编码公钥时使用的曲线标识
+type CurveId byte
+
+const (
+	CURVE_P256      CurveId = 0x01
+	CURVE_SECP256K1 CurveId = 0x02
+)
+
+func (id CurveId) String() string {
+	switch id {
+	case CURVE_P256:
+		return "p256"
+	case CURVE_SECP256K1:
+		return "secp256k1"
+	}
+	return fmt.Sprintf("curve(%d)", byte(id))
+}
+
+func (id CurveId) Curve() (elliptic.Curve, error) {
+	switch id {
+	case CURVE_P256:
+		return elliptic.P256(), nil
+	case CURVE_SECP256K1:
+		return secp256k1.S256(), nil
+	}
+	return nil, fmt.Errorf("unknown curve id %d", byte(id))
+}
+
+// 根据名称获取曲线,用于命令行参数
+func CurveByName(name string) (elliptic.Curve, error) {
+	for _, id := range []CurveId{CURVE_P256, CURVE_SECP256K1} {
+		if id.String() == name {
+			return id.Curve()
+		}
+	}
+	return nil, fmt.Errorf("unknown curve %q (available: p256, secp256k1)", name)
+}
+
+func CurveIdOf(curve elliptic.Curve) (CurveId, error) {
+	switch curve.Params().Name {
+	case elliptic.P256().Params().Name:
+		return CURVE_P256, nil
+	case secp256k1.S256().Params().Name:
+		return CURVE_SECP256K1, nil
+	}
+	return 0, fmt.Errorf("unsupported curve %s", curve.Params().Name)
+}
+
+// SEC1压缩格式: 0x02/0x03 + X
+func CompressPublicKey(pub *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(pub.Curve, pub.X, pub.Y)
+}
+
+// 将公钥编码为字符串
+// P-256保持原来的128位hex(X||Y),其他曲线使用 曲线标识(1字节) + SEC1压缩公钥
+func PublicKeyToString(pub *ecdsa.PublicKey) string {
+	id, err := CurveIdOf(pub.Curve)
+	if err != nil || id == CURVE_P256 {
+		return fmt.Sprintf("%064x%064x", pub.X, pub.Y)
+	}
+	return fmt.Sprintf("%02x%x", byte(id), CompressPublicKey(pub))
+}
+
+// 解析PublicKeyToString的结果,也接受带曲线标识的P-256公钥和非压缩公钥
+func DecodePublicKey(s string) (*ecdsa.PublicKey, error) {
+	//原来的格式: 64字节 X||Y,P-256
+	if len(s) == 128 {
+		x, y := String2BigIntTuple(s)
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: &x, Y: &y}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("public key is not on curve p256")
+		}
+		return pub, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) < 2 {
+		return nil, fmt.Errorf("invalid public key %q", s)
+	}
+	return ParsePublicKey(b)
+}
+
+// 曲线标识(1字节) + SEC1压缩公钥,用于多重签名和脚本
+func EncodePublicKey(pub *ecdsa.PublicKey) ([]byte, error) {
+	id, err := CurveIdOf(pub.Curve)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(id)}, CompressPublicKey(pub)...), nil
+}
+
+// 解析 曲线标识 + SEC1公钥(压缩或非压缩)
+func ParsePublicKey(b []byte) (*ecdsa.PublicKey, error) {
+	if len(b) < 2 {
+		return nil, fmt.Errorf("invalid public key length %d", len(b))
+	}
+	id := CurveId(b[0])
+	curve, err := id.Curve()
+	if err != nil {
+		return nil, err
+	}
+	point := b[1:]
+	switch id {
+	case CURVE_SECP256K1:
+		//secp256k1的a=0,不能使用elliptic.UnmarshalCompressed
+		k, err := secp256k1.ParsePubKey(point)
+		if err != nil {
+			return nil, err
+		}
+		return k.ToECDSA(), nil
+	default:
+		x, y := elliptic.UnmarshalCompressed(curve, point)
+		if x == nil {
+			x, y = elliptic.Unmarshal(curve, point)
+		}
+		if x == nil {
+			return nil, fmt.Errorf("invalid %s public key", id)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+}
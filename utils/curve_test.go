@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestCurveByName(t *testing.T) {
+	for _, id := range []CurveId{CURVE_P256, CURVE_SECP256K1} {
+		curve, err := CurveByName(id.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := CurveIdOf(curve); err != nil || got != id {
+			t.Errorf("%s: curve id %v, %v", id, got, err)
+		}
+	}
+	if _, err := CurveByName("p384"); err == nil {
+		t.Error("unknown curve accepted")
+	}
+	if _, err := CurveIdOf(elliptic.P384()); err == nil {
+		t.Error("unsupported curve accepted")
+	}
+}
+
+func TestPublicKeyRoundTrip(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), secp256k1.S256()} {
+		privateKey, _ := ecdsa.GenerateKey(curve, rand.Reader)
+		pub := &privateKey.PublicKey
+		s := PublicKeyToString(pub)
+		got, err := DecodePublicKey(s)
+		if err != nil {
+			t.Fatalf("%s: %v", curve.Params().Name, err)
+		}
+		if got.Curve.Params().Name != curve.Params().Name || got.X.Cmp(pub.X) != 0 || got.Y.Cmp(pub.Y) != 0 {
+			t.Errorf("%s: decoded a different key", curve.Params().Name)
+		}
+	}
+}
+
+// P-256保持原来的128位hex,也接受带曲线标识的压缩格式
+func TestP256Encoding(t *testing.T) {
+	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	pub := &privateKey.PublicKey
+	if s := PublicKeyToString(pub); len(s) != 128 {
+		t.Fatalf("p256 public key %q is not 128 hex characters", s)
+	}
+	tagged := "01" + hex.EncodeToString(CompressPublicKey(pub))
+	got, err := DecodePublicKey(tagged)
+	if err != nil || got.X.Cmp(pub.X) != 0 || got.Y.Cmp(pub.Y) != 0 {
+		t.Fatalf("tagged p256 key: %v", err)
+	}
+}
+
+func TestDecodePublicKeyErrors(t *testing.T) {
+	privateKey, _ := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
+	point := hex.EncodeToString(CompressPublicKey(&privateKey.PublicKey))
+	tests := map[string]string{
+		"empty":         "",
+		"not hex":       "zz" + point,
+		"unknown curve": "09" + point,
+		"short point":   "02" + point[:20],
+		"off curve":     strings.Repeat("1", 128),
+	}
+	for name, s := range tests {
+		if _, err := DecodePublicKey(s); err == nil {
+			t.Errorf("%s: invalid public key accepted", name)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package utils
 
 import (
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"encoding/hex"
 	"fmt"
 	"log"
@@ -31,23 +30,51 @@ func String2BigIntTuple(s string) (big.Int, big.Int) {
 	return bix, biy
 }
 
+// 格式错误时返回nil
 func SignatureFromString(s string) *Signature {
+	if len(s) != 128 {
+		return nil
+	}
 	r, y := String2BigIntTuple(s)
-	log.Println(&r)
-	log.Println(&y)
-	return &Signature{&r, &y}
+	return &Signature{R: &r, S: &y}
 }
 
-// 将公钥转为ecdsa
+// 多重签名的签名列表,未签名的位置为空字符串
+func SignaturesToStrings(signatures []*Signature) []string {
+	if signatures == nil {
+		return nil
+	}
+	strs := make([]string, len(signatures))
+	for i, s := range signatures {
+		if s != nil {
+			strs[i] = s.String()
+		}
+	}
+	return strs
+}
+
+func SignaturesFromStrings(strs []string) []*Signature {
+	signatures := make([]*Signature, len(strs))
+	for i, s := range strs {
+		signatures[i] = SignatureFromString(s)
+	}
+	return signatures
+}
+
+// 将公钥转为ecdsa,支持P-256和secp256k1,格式错误时返回nil
 func PublicKeyFromString(s string) *ecdsa.PublicKey {
-	x, y := String2BigIntTuple(s)
-	return &ecdsa.PublicKey{elliptic.P256(), &x, &y}
+	pub, err := DecodePublicKey(s)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		return nil
+	}
+	return pub
 }
 
-// 将私钥转为ecdsa
+// 将私钥转为ecdsa,曲线与公钥相同
 func PrivateKeyFromString(s string, publicKey *ecdsa.PublicKey) *ecdsa.PrivateKey {
 	b, _ := hex.DecodeString(s[:])
 	var bi big.Int
 	_ = bi.SetBytes(b)
-	return &ecdsa.PrivateKey{*publicKey, &bi}
+	return &ecdsa.PrivateKey{PublicKey: *publicKey, D: &bi}
 }
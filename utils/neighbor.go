@@ -10,13 +10,14 @@ import (
 )
 
 func IsFoundHost(host string, port uint16) bool {
-	target := fmt.Sprintf("%s:%d", host, port)
+	target := net.JoinHostPort(host, strconv.Itoa(int(port)))
 
-	_, err := net.DialTimeout("tcp", target, 1*time.Second)
+	conn, err := net.DialTimeout("tcp", target, 1*time.Second)
 	if err != nil {
 		fmt.Printf("%s %v\n", target, err)
 		return false
 	}
+	conn.Close()
 	return true
 }
 
@@ -56,5 +57,12 @@ func GetHost() string {
 		return "127.0.0.1"
 	}
 	//fmt.Println("address:", address)
-	return address[1]
+	//优先使用非回环的IPv4地址
+	for _, a := range address {
+		ip := net.ParseIP(a)
+		if ip != nil && ip.To4() != nil && !ip.IsLoopback() {
+			return a
+		}
+	}
+	return "127.0.0.1"
 }
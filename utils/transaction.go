@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+)
+
+// 交易签名的内容,钱包签名和节点验证必须使用相同的格式
+type SigningPayload struct {
+	ChainId   uint32   `json:"chain_id"`
+	Sender    string   `json:"sender_blockchain_address"`
+	Recipient string   `json:"recipient_blockchain_address"`
+	Value     float32  `json:"value"`
+	Nonce     uint64   `json:"nonce,omitempty"`     //发送方账户的nonce,防止重放
+	LockTime  int64    `json:"lock_time,omitempty"` //为0时省略,与未加入锁定时间前的签名兼容
+	Code      string   `json:"code,omitempty"`      //合约交易的字节码和参数(十六进制)
+	Input     string   `json:"input,omitempty"`
+	GasLimit  uint64   `json:"gas_limit,omitempty"`
+	Token     *TokenOp `json:"token,omitempty"` //代币交易的操作
+	Data      string   `json:"data,omitempty"`  //附带的数据(十六进制)
+}
+
+// 代币操作,数量都以最小单位计
+type TokenOp struct {
+	Op       string `json:"op"`                 //"create"或"transfer"
+	Symbol   string `json:"symbol"`             //代币的唯一标识
+	Decimals uint8  `json:"decimals,omitempty"` //创建时使用,显示时的小数位数
+	Amount   uint64 `json:"amount"`             //创建时为发行总量,转账时为转账数量
+}
+
+// 签名使用的哈希值
+func (p *SigningPayload) Hash() [32]byte {
+	m, _ := json.Marshal(p)
+	return sha256.Sum256(m)
+}
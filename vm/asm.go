@@ -0,0 +1,156 @@
+package vm
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const LABEL_SIZE = 2 //@label压入的跳转位置的字节数
+
+// 解析一个压入的值: 十进制数字为8字节整数,0x开头为十六进制数据,"..."为不含空白的字符串
+func ParseArg(tok string) ([]byte, error) {
+	if strings.HasPrefix(tok, "0x") || strings.HasPrefix(tok, "0X") {
+		b, err := hex.DecodeString(tok[2:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid data %q: %v", tok, err)
+		}
+		return b, nil
+	}
+	if n, err := strconv.ParseInt(tok, 10, 64); err == nil {
+		return FromInt(n), nil
+	}
+	if len(tok) >= 2 && strings.HasPrefix(tok, `"`) && strings.HasSuffix(tok, `"`) {
+		return []byte(tok[1 : len(tok)-1]), nil
+	}
+	return nil, fmt.Errorf("invalid value %q", tok)
+}
+
+func appendPush(code []byte, data []byte) ([]byte, error) {
+	if len(data) > MAX_ELEMENT_SIZE {
+		return nil, ErrElementSize
+	}
+	code = append(code, byte(PUSH), byte(len(data)))
+	return append(code, data...), nil
+}
+
+// 汇编,例如 "loop: 1 ADD DUP 10 LT @loop JUMPI"
+// 操作码之外的记号按ParseArg压入;"name:"定义标签(生成JUMPDEST),"@name"压入标签的位置
+func Assemble(asm string) ([]byte, error) {
+	toks := strings.Fields(asm)
+	//第一遍: 计算标签的位置,@label固定占用LABEL_SIZE字节
+	labels := make(map[string]int)
+	pc := 0
+	for _, tok := range toks {
+		switch {
+		case strings.HasSuffix(tok, ":") && len(tok) > 1:
+			name := strings.TrimSuffix(tok, ":")
+			if _, ok := labels[name]; ok {
+				return nil, fmt.Errorf("duplicate label %q", name)
+			}
+			labels[name] = pc
+			pc += 1
+		case strings.HasPrefix(tok, "@"):
+			pc += 2 + LABEL_SIZE
+		default:
+			if _, ok := opcodesByName[strings.ToUpper(tok)]; ok {
+				pc += 1
+				continue
+			}
+			data, err := ParseArg(tok)
+			if err != nil {
+				return nil, err
+			}
+			pc += 2 + len(data)
+		}
+	}
+	//第二遍: 生成字节码
+	var code []byte
+	for _, tok := range toks {
+		var err error
+		switch {
+		case strings.HasSuffix(tok, ":") && len(tok) > 1:
+			code = append(code, byte(JUMPDEST))
+		case strings.HasPrefix(tok, "@"):
+			dest, ok := labels[tok[1:]]
+			if !ok {
+				return nil, fmt.Errorf("undefined label %q", tok[1:])
+			}
+			code, err = appendPush(code, binary.BigEndian.AppendUint16(nil, uint16(dest)))
+		default:
+			if op, ok := opcodesByName[strings.ToUpper(tok)]; ok {
+				if op == PUSH {
+					return nil, errors.New("PUSH takes no operand, write the value instead")
+				}
+				code = append(code, byte(op))
+				continue
+			}
+			data, _ := ParseArg(tok)
+			code, err = appendPush(code, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(code) > MAX_CODE_SIZE {
+		return nil, ErrCodeSize
+	}
+	return code, nil
+}
+
+// 反汇编: 8字节的数据显示为十进制数字,其他数据显示为0x开头的十六进制
+func Disassemble(code []byte) (string, error) {
+	ins, err := instructions(code)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(ins))
+	for i, in := range ins {
+		switch {
+		case in.op != PUSH:
+			parts[i] = in.op.String()
+		case len(in.data) == NUM_SIZE:
+			n, _ := ToInt(in.data)
+			parts[i] = strconv.FormatInt(n, 10)
+		default:
+			parts[i] = "0x" + hex.EncodeToString(in.data)
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// 调用参数的编码: 每个参数为1字节长度加数据
+func EncodeArgs(args [][]byte) ([]byte, error) {
+	if len(args) > MAX_ARGS {
+		return nil, fmt.Errorf("too many arguments: %d > %d", len(args), MAX_ARGS)
+	}
+	var b []byte
+	for i, a := range args {
+		if len(a) > MAX_ELEMENT_SIZE-1 {
+			return nil, fmt.Errorf("argument %d: %w", i, ErrElementSize)
+		}
+		b = append(b, byte(len(a)))
+		b = append(b, a...)
+	}
+	return b, nil
+}
+
+func DecodeArgs(b []byte) ([][]byte, error) {
+	args := [][]byte{}
+	for i := 0; i < len(b); {
+		n := int(b[i])
+		i += 1
+		if i+n > len(b) {
+			return nil, errors.New("malformed arguments")
+		}
+		args = append(args, b[i:i+n])
+		i += n
+	}
+	if len(args) > MAX_ARGS {
+		return nil, fmt.Errorf("too many arguments: %d > %d", len(args), MAX_ARGS)
+	}
+	return args, nil
+}
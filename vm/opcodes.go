@@ -0,0 +1,134 @@
+package vm
+
+// 操作码
+type Opcode byte
+
+const (
+	STOP Opcode = 0x00 //正常结束,没有返回值
+	PUSH Opcode = 0x01 //接下来1个字节为数据长度,然后是数据
+
+	//栈操作
+	POP  Opcode = 0x02
+	DUP  Opcode = 0x03
+	SWAP Opcode = 0x04
+	OVER Opcode = 0x05 //复制次栈顶
+
+	//算术和比较,数字为8字节大端有符号整数
+	ADD Opcode = 0x10
+	SUB Opcode = 0x11
+	MUL Opcode = 0x12
+	DIV Opcode = 0x13
+	MOD Opcode = 0x14
+	LT  Opcode = 0x15
+	GT  Opcode = 0x16
+	EQ  Opcode = 0x17 //按字节比较
+	NOT Opcode = 0x18 //假为1,真为0
+
+	//字节串
+	SHA256 Opcode = 0x20
+	CONCAT Opcode = 0x21
+	SIZE   Opcode = 0x22
+
+	//流程控制,跳转目标必须是JUMPDEST
+	JUMP     Opcode = 0x30
+	JUMPI    Opcode = 0x31 //栈: dest cond
+	JUMPDEST Opcode = 0x32
+
+	//调用环境
+	CALLER Opcode = 0x40 //调用者地址
+	ARG    Opcode = 0x41 //栈: i,压入第i个参数
+	ARGC   Opcode = 0x42 //参数个数
+
+	//存储
+	SLOAD  Opcode = 0x50 //栈: key,不存在时压入空字节串
+	SSTORE Opcode = 0x51 //栈: key value,value为空时删除
+
+	//结束
+	RETURN Opcode = 0x60 //栈: value
+	REVERT Opcode = 0x61 //栈: message,撤销存储的修改
+)
+
+var opcodeNames = map[Opcode]string{
+	STOP:     "STOP",
+	PUSH:     "PUSH",
+	POP:      "POP",
+	DUP:      "DUP",
+	SWAP:     "SWAP",
+	OVER:     "OVER",
+	ADD:      "ADD",
+	SUB:      "SUB",
+	MUL:      "MUL",
+	DIV:      "DIV",
+	MOD:      "MOD",
+	LT:       "LT",
+	GT:       "GT",
+	EQ:       "EQ",
+	NOT:      "NOT",
+	SHA256:   "SHA256",
+	CONCAT:   "CONCAT",
+	SIZE:     "SIZE",
+	JUMP:     "JUMP",
+	JUMPI:    "JUMPI",
+	JUMPDEST: "JUMPDEST",
+	CALLER:   "CALLER",
+	ARG:      "ARG",
+	ARGC:     "ARGC",
+	SLOAD:    "SLOAD",
+	SSTORE:   "SSTORE",
+	RETURN:   "RETURN",
+	REVERT:   "REVERT",
+}
+
+var opcodesByName = func() map[string]Opcode {
+	m := make(map[string]Opcode)
+	for op, name := range opcodeNames {
+		m[name] = op
+	}
+	return m
+}()
+
+// 每个操作码消耗的gas,SHA256另外按数据长度计费
+var gasCosts = map[Opcode]uint64{
+	STOP:     0,
+	PUSH:     3,
+	POP:      2,
+	DUP:      3,
+	SWAP:     3,
+	OVER:     3,
+	ADD:      3,
+	SUB:      3,
+	MUL:      5,
+	DIV:      5,
+	MOD:      5,
+	LT:       3,
+	GT:       3,
+	EQ:       3,
+	NOT:      3,
+	SHA256:   30,
+	CONCAT:   3,
+	SIZE:     2,
+	JUMP:     8,
+	JUMPI:    10,
+	JUMPDEST: 1,
+	CALLER:   2,
+	ARG:      3,
+	ARGC:     2,
+	SLOAD:    50,
+	SSTORE:   200,
+	RETURN:   0,
+	REVERT:   0,
+}
+
+const SHA256_WORD_GAS = 6 //SHA256每32字节的gas
+
+func (op Opcode) String() string {
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+func (op Opcode) valid() bool {
+	_, ok := opcodeNames[op]
+	return ok
+}
@@ -0,0 +1,365 @@
+package vm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 资源限制
+const (
+	MAX_CODE_SIZE    = 10000 //合约字节码的字节数
+	MAX_ELEMENT_SIZE = 256   //单个栈元素和存储值的字节数
+	MAX_STACK_SIZE   = 1024  //栈中元素的数量
+	MAX_ARGS         = 16    //调用参数的个数
+	NUM_SIZE         = 8     //数字的字节数
+)
+
+var (
+	ErrCodeSize       = errors.New("code too large")
+	ErrBadOpcode      = errors.New("unknown opcode")
+	ErrMalformed      = errors.New("malformed push")
+	ErrOutOfGas       = errors.New("out of gas")
+	ErrStackUnderflow = errors.New("stack underflow")
+	ErrStackSize      = errors.New("stack size limit exceeded")
+	ErrElementSize    = errors.New("element size limit exceeded")
+	ErrNumber         = errors.New("invalid number")
+	ErrDivideByZero   = errors.New("division by zero")
+	ErrJump           = errors.New("invalid jump destination")
+	ErrArg            = errors.New("argument index out of range")
+	ErrRevert         = errors.New("execution reverted")
+)
+
+// 合约的键值存储
+type Storage interface {
+	Get(key []byte) []byte
+	Set(key []byte, value []byte) //value为空时删除
+}
+
+// 合约执行的环境
+type Context struct {
+	Caller  string   //调用者的地址
+	Args    [][]byte //调用参数
+	Storage Storage
+}
+
+type stack [][]byte
+
+func (st *stack) push(b []byte) error {
+	if len(b) > MAX_ELEMENT_SIZE {
+		return ErrElementSize
+	}
+	if len(*st) >= MAX_STACK_SIZE {
+		return ErrStackSize
+	}
+	*st = append(*st, b)
+	return nil
+}
+
+func (st *stack) pop() ([]byte, error) {
+	n := len(*st)
+	if n == 0 {
+		return nil, ErrStackUnderflow
+	}
+	b := (*st)[n-1]
+	*st = (*st)[:n-1]
+	return b, nil
+}
+
+func (st *stack) popInt() (int64, error) {
+	b, err := st.pop()
+	if err != nil {
+		return 0, err
+	}
+	return ToInt(b)
+}
+
+// 数字编码为8字节大端有符号整数
+func FromInt(n int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(n))
+}
+
+// 不超过8字节的大端有符号整数,空字节串为0
+func ToInt(b []byte) (int64, error) {
+	if len(b) > NUM_SIZE {
+		return 0, ErrNumber
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	var v int64
+	if b[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, c := range b {
+		v = v<<8 | int64(c)
+	}
+	return v, nil
+}
+
+func fromBool(v bool) []byte {
+	if v {
+		return FromInt(1)
+	}
+	return FromInt(0)
+}
+
+// 任意非0字节为真
+func asBool(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// 一条指令: 所在位置、操作码和PUSH的数据
+type instruction struct {
+	pc   int
+	op   Opcode
+	data []byte
+}
+
+// 拆分为指令,检查操作码和PUSH的长度
+func instructions(code []byte) ([]instruction, error) {
+	if len(code) > MAX_CODE_SIZE {
+		return nil, ErrCodeSize
+	}
+	var ins []instruction
+	for pc := 0; pc < len(code); {
+		op := Opcode(code[pc])
+		if !op.valid() {
+			return nil, fmt.Errorf("%w 0x%02x at %d", ErrBadOpcode, byte(op), pc)
+		}
+		in := instruction{pc: pc, op: op}
+		pc += 1
+		if op == PUSH {
+			if pc >= len(code) || pc+1+int(code[pc]) > len(code) {
+				return nil, fmt.Errorf("%w at %d", ErrMalformed, in.pc)
+			}
+			n := int(code[pc])
+			in.data = code[pc+1 : pc+1+n]
+			pc += 1 + n
+		}
+		ins = append(ins, in)
+	}
+	return ins, nil
+}
+
+// 检查字节码是否可以部署
+func Validate(code []byte) error {
+	if len(code) == 0 {
+		return errors.New("empty code")
+	}
+	_, err := instructions(code)
+	return err
+}
+
+// 执行合约,返回RETURN的值和消耗的gas
+// 出错时存储的修改由调用方丢弃,gas不足时消耗全部gasLimit
+func Run(code []byte, ctx *Context, gasLimit uint64) ([]byte, uint64, error) {
+	ins, err := instructions(code)
+	if err != nil {
+		return nil, 0, err
+	}
+	//跳转目标: 位置 -> 指令序号
+	dests := make(map[int64]int)
+	for i, in := range ins {
+		if in.op == JUMPDEST {
+			dests[int64(in.pc)] = i
+		}
+	}
+	var st stack
+	var gas uint64
+	for i := 0; i < len(ins); i++ {
+		in := ins[i]
+		cost := gasCosts[in.op]
+		if in.op == SHA256 && len(st) > 0 {
+			cost += uint64((len(st[len(st)-1])+31)/32) * SHA256_WORD_GAS
+		}
+		if gas+cost > gasLimit {
+			return nil, gasLimit, ErrOutOfGas
+		}
+		gas += cost
+		switch in.op {
+		case STOP:
+			return nil, gas, nil
+		case RETURN:
+			v, err := st.pop()
+			return v, gas, err
+		case REVERT:
+			msg, err := st.pop()
+			if err != nil {
+				return nil, gas, err
+			}
+			return nil, gas, fmt.Errorf("%w: %s", ErrRevert, msg)
+		case JUMP, JUMPI:
+			dest, err := st.popInt()
+			if err != nil {
+				return nil, gas, err
+			}
+			if in.op == JUMPI {
+				cond, err := st.pop()
+				if err != nil {
+					return nil, gas, err
+				}
+				if !asBool(cond) {
+					continue
+				}
+			}
+			j, ok := dests[dest]
+			if !ok {
+				return nil, gas, fmt.Errorf("%w %d", ErrJump, dest)
+			}
+			i = j
+		default:
+			if err := step(in, &st, ctx); err != nil {
+				return nil, gas, err
+			}
+		}
+	}
+	return nil, gas, nil
+}
+
+func step(in instruction, st *stack, ctx *Context) error {
+	switch op := in.op; op {
+	case PUSH:
+		return st.push(in.data)
+	case JUMPDEST:
+	case POP:
+		_, err := st.pop()
+		return err
+	case DUP, OVER:
+		n := len(*st)
+		k := 1
+		if op == OVER {
+			k = 2
+		}
+		if n < k {
+			return ErrStackUnderflow
+		}
+		return st.push((*st)[n-k])
+	case SWAP:
+		n := len(*st)
+		if n < 2 {
+			return ErrStackUnderflow
+		}
+		(*st)[n-1], (*st)[n-2] = (*st)[n-2], (*st)[n-1]
+	case ADD, SUB, MUL, DIV, MOD, LT, GT:
+		b, err := st.popInt()
+		if err != nil {
+			return err
+		}
+		a, err := st.popInt()
+		if err != nil {
+			return err
+		}
+		v, err := arithmetic(op, a, b)
+		if err != nil {
+			return err
+		}
+		return st.push(v)
+	case EQ:
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		a, err := st.pop()
+		if err != nil {
+			return err
+		}
+		return st.push(fromBool(bytes.Equal(a, b)))
+	case NOT:
+		a, err := st.pop()
+		if err != nil {
+			return err
+		}
+		return st.push(fromBool(!asBool(a)))
+	case SHA256:
+		a, err := st.pop()
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(a)
+		return st.push(h[:])
+	case CONCAT:
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		a, err := st.pop()
+		if err != nil {
+			return err
+		}
+		return st.push(append(append([]byte{}, a...), b...))
+	case SIZE:
+		n := len(*st)
+		if n == 0 {
+			return ErrStackUnderflow
+		}
+		return st.push(FromInt(int64(len((*st)[n-1]))))
+	case CALLER:
+		return st.push([]byte(ctx.Caller))
+	case ARGC:
+		return st.push(FromInt(int64(len(ctx.Args))))
+	case ARG:
+		i, err := st.popInt()
+		if err != nil {
+			return err
+		}
+		if i < 0 || i >= int64(len(ctx.Args)) {
+			return fmt.Errorf("%w: %d", ErrArg, i)
+		}
+		return st.push(ctx.Args[i])
+	case SLOAD:
+		key, err := st.pop()
+		if err != nil {
+			return err
+		}
+		return st.push(ctx.Storage.Get(key))
+	case SSTORE:
+		value, err := st.pop()
+		if err != nil {
+			return err
+		}
+		key, err := st.pop()
+		if err != nil {
+			return err
+		}
+		if len(key) == 0 {
+			return errors.New("empty storage key")
+		}
+		ctx.Storage.Set(key, value)
+	default:
+		return fmt.Errorf("%w 0x%02x", ErrBadOpcode, byte(op))
+	}
+	return nil
+}
+
+// 整数运算按64位补码回绕
+func arithmetic(op Opcode, a int64, b int64) ([]byte, error) {
+	switch op {
+	case ADD:
+		return FromInt(a + b), nil
+	case SUB:
+		return FromInt(a - b), nil
+	case MUL:
+		return FromInt(a * b), nil
+	case DIV, MOD:
+		if b == 0 {
+			return nil, ErrDivideByZero
+		}
+		if op == DIV {
+			return FromInt(a / b), nil
+		}
+		return FromInt(a % b), nil
+	case LT:
+		return fromBool(a < b), nil
+	case GT:
+		return fromBool(a > b), nil
+	}
+	return nil, fmt.Errorf("%w 0x%02x", ErrBadOpcode, byte(op))
+}
@@ -0,0 +1,162 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type mapStorage map[string][]byte
+
+func (m mapStorage) Get(key []byte) []byte { return m[string(key)] }
+
+func (m mapStorage) Set(key []byte, value []byte) {
+	if len(value) == 0 {
+		delete(m, string(key))
+		return
+	}
+	m[string(key)] = value
+}
+
+func run(t *testing.T, asm string, args [][]byte, gasLimit uint64) ([]byte, uint64, error) {
+	t.Helper()
+	code, err := Assemble(asm)
+	if err != nil {
+		t.Fatalf("assemble %q: %v", asm, err)
+	}
+	return Run(code, &Context{Caller: "alice", Args: args, Storage: mapStorage{}}, gasLimit)
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		asm  string
+		args [][]byte
+		want []byte
+		err  error
+	}{
+		{"add", "1 2 ADD RETURN", nil, FromInt(3), nil},
+		{"sub negative", "1 2 SUB RETURN", nil, FromInt(-1), nil},
+		{"mod", "7 3 MOD RETURN", nil, FromInt(1), nil},
+		{"compare", "1 2 LT RETURN", nil, FromInt(1), nil},
+		{"equal bytes", `"ab" "ab" EQ NOT RETURN`, nil, FromInt(0), nil},
+		{"concat and size", `"ab" "cd" CONCAT SIZE SWAP POP RETURN`, nil, FromInt(4), nil},
+		{"over", "1 2 OVER RETURN", nil, FromInt(1), nil},
+		{"caller", "CALLER RETURN", nil, []byte("alice"), nil},
+		{"args", "ARGC 1 ARG CONCAT RETURN", [][]byte{{1}, {2}}, append(FromInt(2), 2), nil},
+		{"branch taken", "1 @yes JUMPI 0 RETURN yes: 1 RETURN", nil, FromInt(1), nil},
+		{"branch not taken", "0 @yes JUMPI 0 RETURN yes: 1 RETURN", nil, FromInt(0), nil},
+		{"stop", "1 STOP", nil, nil, nil},
+		{"revert", `"no" REVERT`, nil, nil, ErrRevert},
+		{"underflow", "ADD", nil, nil, ErrStackUnderflow},
+		{"divide by zero", "1 0 DIV", nil, nil, ErrDivideByZero},
+		{"jump into data", "3 JUMP", nil, nil, ErrJump},
+		{"argument out of range", "0 ARG", nil, nil, ErrArg},
+		{"number too long", "0x000000000000000001 1 ADD", nil, nil, ErrNumber},
+		{"element too large", strings.Repeat(`"0123456789abcdef0123456789abcdef" `, 8) + strings.Repeat("CONCAT ", 7) + `"x" CONCAT`, nil, nil, ErrElementSize},
+	}
+	for _, tt := range tests {
+		ret, _, err := run(t, tt.asm, tt.args, 10000)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if !bytes.Equal(ret, tt.want) {
+			t.Errorf("%s: returned %x, want %x", tt.name, ret, tt.want)
+		}
+	}
+}
+
+// gas正好足够时成功,少1时失败并消耗全部gas上限
+func TestOutOfGas(t *testing.T) {
+	data := "0x" + strings.Repeat("00", 64)
+	tests := []struct {
+		name string
+		asm  string
+		gas  uint64
+	}{
+		{"push and add", "1 2 ADD RETURN", 3 + 3 + 3},
+		{"storage", `"k" "v" SSTORE "k" SLOAD RETURN`, 3 + 3 + 200 + 3 + 50},
+		{"sha256 per word", data + " SHA256 RETURN", 3 + 30 + 2*SHA256_WORD_GAS},
+	}
+	for _, tt := range tests {
+		if _, used, err := run(t, tt.asm, nil, tt.gas); err != nil || used != tt.gas {
+			t.Errorf("%s: used %d of %d, err %v", tt.name, used, tt.gas, err)
+		}
+		if _, used, err := run(t, tt.asm, nil, tt.gas-1); err != ErrOutOfGas || used != tt.gas-1 {
+			t.Errorf("%s: used %d with one gas less, err %v", tt.name, used, err)
+		}
+	}
+	//无限循环在gas耗尽时停止
+	if _, used, err := run(t, "loop: @loop JUMP", nil, 100000); err != ErrOutOfGas || used != 100000 {
+		t.Errorf("infinite loop: used %d, err %v", used, err)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	code, _ := Assemble(`"k" SLOAD 1 ADD DUP "k" SWAP SSTORE RETURN`)
+	storage := mapStorage{}
+	ctx := &Context{Storage: storage}
+	for i := int64(1); i <= 3; i++ {
+		ret, _, err := Run(code, ctx, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := ToInt(ret); n != i || !bytes.Equal(storage["k"], FromInt(i)) {
+			t.Fatalf("call %d returned %x, stored %x", i, ret, storage["k"])
+		}
+	}
+}
+
+func TestInvalidCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+		err  error
+	}{
+		{"unknown opcode", []byte{0xff}, ErrBadOpcode},
+		{"push without length", []byte{byte(PUSH)}, ErrMalformed},
+		{"push past end", []byte{byte(PUSH), 2, 1}, ErrMalformed},
+		{"too large", make([]byte, MAX_CODE_SIZE+1), ErrCodeSize},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.code); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	if err := Validate(nil); err == nil {
+		t.Error("empty code accepted")
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	asm := `1 -2 0x00ff "hi" ADD JUMPDEST STOP`
+	code, err := Assemble(asm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Disassemble(code); got != `1 -2 0x00ff 0x6869 ADD JUMPDEST STOP` {
+		t.Errorf("disassembled %q", got)
+	}
+	for _, bad := range []string{"@missing", "a: a:", "PUSH", "0xzz", "what"} {
+		if _, err := Assemble(bad); err == nil {
+			t.Errorf("%q assembled", bad)
+		}
+	}
+	args := [][]byte{{}, {1, 2}, []byte("abc")}
+	b, err := EncodeArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeArgs(b)
+	if err != nil || len(decoded) != 3 || !bytes.Equal(decoded[2], args[2]) {
+		t.Errorf("decoded %x, err %v", decoded, err)
+	}
+	if _, err := DecodeArgs([]byte{3, 1}); err == nil {
+		t.Error("truncated arguments decoded")
+	}
+	if _, err := EncodeArgs(make([][]byte, MAX_ARGS+1)); err == nil {
+		t.Error("too many arguments encoded")
+	}
+}
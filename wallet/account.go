@@ -0,0 +1,215 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ACCOUNTS_FILE           = "accounts.idx" //账户名 -> 地址,与密钥文件保存在同一目录
+	ACCOUNT_SESSION_TTL_SEC = 300            //解锁后会话的默认有效期
+	ACCOUNT_SESSION_MAX_SEC = 24 * 3600
+)
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	ErrAccountExists   = errors.New("account already exists")
+	ErrAccountLocked   = errors.New("account is locked, unlock it first")
+	accountIdPattern   = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// 托管账户: 名称对应密钥库中的一个地址
+type Account struct {
+	Id        string `json:"account"`
+	Address   string `json:"block_chain_address"`
+	PublicKey string `json:"public_key"`
+	Curve     string `json:"curve"`
+}
+
+// 解锁后的会话,会话期间私钥只保存在内存中
+type session struct {
+	account string
+	wallet  *Wallet
+	expires time.Time
+}
+
+// 账户管理: 私钥加密保存在密钥库中,解锁后由服务器签名
+type AccountManager struct {
+	keystore *KeyStore
+	accounts map[string]string //账户名 -> 地址
+	sessions map[string]*session
+	mux      sync.Mutex
+}
+
+// 读取密钥库目录中的账户列表
+func NewAccountManager(ks *KeyStore) (*AccountManager, error) {
+	am := &AccountManager{
+		keystore: ks,
+		accounts: make(map[string]string),
+		sessions: make(map[string]*session),
+	}
+	data, err := os.ReadFile(am.accountsPath())
+	if err == nil {
+		if err := json.Unmarshal(data, &am.accounts); err != nil {
+			return nil, fmt.Errorf("%s: %v", am.accountsPath(), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return am, nil
+}
+
+func (am *AccountManager) accountsPath() string {
+	return filepath.Join(am.keystore.Dir(), ACCOUNTS_FILE)
+}
+
+func (am *AccountManager) save() error {
+	data, err := json.MarshalIndent(am.accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := am.accountsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, am.accountsPath())
+}
+
+func (am *AccountManager) account(id string, w *Wallet) *Account {
+	info := w.KeyInfo()
+	return &Account{Id: id, Address: info.Address, PublicKey: info.PublicKey, Curve: info.Curve}
+}
+
+// 创建账户,address为空时生成新钱包,否则使用密钥库中已有的密钥
+func (am *AccountManager) CreateAccount(id string, address string, curve elliptic.Curve, passphrase string) (*Account, error) {
+	if !accountIdPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid account id %q", id)
+	}
+	am.mux.Lock()
+	defer am.mux.Unlock()
+	if _, ok := am.accounts[id]; ok {
+		return nil, ErrAccountExists
+	}
+	var w *Wallet
+	var err error
+	if address == "" {
+		w = NewWalletWithCurve(curve, am.keystore.params)
+		err = am.keystore.Store(w, passphrase)
+	} else {
+		//确认密码正确,之后才能解锁
+		w, err = am.keystore.Load(address, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+	am.accounts[id] = w.BlockChainAddress()
+	if err := am.save(); err != nil {
+		delete(am.accounts, id)
+		return nil, err
+	}
+	return am.account(id, w), nil
+}
+
+// 所有账户,按名称排序
+func (am *AccountManager) Accounts() ([]*Account, error) {
+	keys, err := am.keystore.List()
+	if err != nil {
+		return nil, err
+	}
+	byAddress := make(map[string]*KeyInfo)
+	for _, k := range keys {
+		byAddress[k.Address] = k
+	}
+	am.mux.Lock()
+	defer am.mux.Unlock()
+	accounts := make([]*Account, 0, len(am.accounts))
+	for id, address := range am.accounts {
+		a := &Account{Id: id, Address: address}
+		if k, ok := byAddress[address]; ok {
+			a.PublicKey = k.PublicKey
+			a.Curve = k.Curve
+		}
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Id < accounts[j].Id })
+	return accounts, nil
+}
+
+// 用密码解锁账户,返回会话令牌,ttlSec <= 0 时使用默认有效期
+func (am *AccountManager) Unlock(id string, passphrase string, ttlSec int) (string, time.Time, *Account, error) {
+	if ttlSec <= 0 {
+		ttlSec = ACCOUNT_SESSION_TTL_SEC
+	}
+	if ttlSec > ACCOUNT_SESSION_MAX_SEC {
+		ttlSec = ACCOUNT_SESSION_MAX_SEC
+	}
+	am.mux.Lock()
+	address, ok := am.accounts[id]
+	am.mux.Unlock()
+	if !ok {
+		return "", time.Time{}, nil, ErrAccountNotFound
+	}
+	//解密较慢,不持有锁
+	w, err := am.keystore.Load(address, passphrase)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, nil, err
+	}
+	token := hex.EncodeToString(b)
+	expires := time.Now().Add(time.Duration(ttlSec) * time.Second)
+
+	am.mux.Lock()
+	defer am.mux.Unlock()
+	am.removeExpired()
+	am.sessions[token] = &session{account: id, wallet: w, expires: expires}
+	return token, expires, am.account(id, w), nil
+}
+
+// 结束会话
+func (am *AccountManager) Lock(token string) {
+	am.mux.Lock()
+	defer am.mux.Unlock()
+	delete(am.sessions, token)
+}
+
+// 会话对应账户的钱包,用于服务器端签名
+func (am *AccountManager) Signer(id string, token string) (*Wallet, error) {
+	am.mux.Lock()
+	defer am.mux.Unlock()
+	am.removeExpired()
+	s, ok := am.sessions[token]
+	if !ok || s.account != id {
+		return nil, ErrAccountLocked
+	}
+	return s.wallet, nil
+}
+
+func (am *AccountManager) removeExpired() {
+	now := time.Now()
+	for token, s := range am.sessions {
+		if now.After(s.expires) {
+			delete(am.sessions, token)
+		}
+	}
+}
+
+// 账户相关请求
+type AccountRequest struct {
+	Account    *string `json:"account"`
+	Passphrase *string `json:"passphrase"`
+	Address    *string `json:"block_chain_address"` //使用密钥库中已有的密钥创建账户
+	TTL        *int    `json:"ttl"`                 //会话有效期(秒)
+}
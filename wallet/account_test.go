@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"GoProject/params"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestAccountManager(t *testing.T) {
+	dir := t.TempDir()
+	ks, _ := NewKeyStore(dir, params.RegTest)
+	am, err := NewAccountManager(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := am.CreateAccount("alice", "", secp256k1.S256(), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := am.CreateAccount("alice", "", secp256k1.S256(), "pw"); err != ErrAccountExists {
+		t.Fatalf("duplicate account: %v", err)
+	}
+	if _, err := am.CreateAccount("../bob", "", secp256k1.S256(), "pw"); err == nil {
+		t.Fatal("invalid account id accepted")
+	}
+	//已有密钥创建账户需要正确的密码
+	if _, err := am.CreateAccount("carol", a.Address, nil, "wrong"); err != ErrDecrypt {
+		t.Fatalf("wrong passphrase: %v", err)
+	}
+
+	//账户列表在重新打开后仍然存在
+	am, err = NewAccountManager(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := am.Accounts()
+	if err != nil || len(accounts) != 1 || *accounts[0] != *a {
+		t.Fatalf("accounts %v, err %v", accounts, err)
+	}
+
+	if _, err := am.Signer("alice", "nope"); err != ErrAccountLocked {
+		t.Fatalf("signer before unlock: %v", err)
+	}
+	if _, _, _, err := am.Unlock("bob", "pw", 0); err != ErrAccountNotFound {
+		t.Fatalf("unknown account: %v", err)
+	}
+	if _, _, _, err := am.Unlock("alice", "wrong", 0); err != ErrDecrypt {
+		t.Fatalf("wrong passphrase: %v", err)
+	}
+	token, expires, _, err := am.Unlock("alice", "pw", 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expires); d > ACCOUNT_SESSION_MAX_SEC*time.Second {
+		t.Errorf("session ttl %v not capped", d)
+	}
+	w, err := am.Signer("alice", token)
+	if err != nil || w.BlockChainAddress() != a.Address {
+		t.Fatalf("signer %v, err %v", w, err)
+	}
+	if _, err := am.Signer("carol", token); err != ErrAccountLocked {
+		t.Fatalf("token used for another account: %v", err)
+	}
+	am.Lock(token)
+	if _, err := am.Signer("alice", token); err != ErrAccountLocked {
+		t.Fatalf("signer after lock: %v", err)
+	}
+}
+
+func TestAccountSessionExpiry(t *testing.T) {
+	ks, _ := NewKeyStore(t.TempDir(), params.RegTest)
+	am, _ := NewAccountManager(ks)
+	if _, err := am.CreateAccount("alice", "", secp256k1.S256(), "pw"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, _, err := am.Unlock("alice", "pw", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	am.sessions[token].expires = time.Now().Add(-time.Second)
+	if _, err := am.Signer("alice", token); err != ErrAccountLocked {
+		t.Fatalf("expired session: %v", err)
+	}
+	if len(am.sessions) != 0 {
+		t.Errorf("expired session not removed")
+	}
+}
@@ -0,0 +1,74 @@
+package wallet
+
+import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/params"
+	"GoProject/vm"
+	"errors"
+	"fmt"
+)
+
+// 签名合约交易,金额为0,手续费按实际消耗的gas从发送方余额中扣除
+func NewContractTransaction(p *params.Params, w *Wallet, contract string, nonce uint64, code []byte, input []byte,
+	gasLimit uint64) *block.TransactionRequest {
+	t := NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockChainAddress(), contract, 0, 0, p.ChainId)
+	t.nonce = nonce
+	t.code = code
+	t.input = input
+	t.gasLimit = gasLimit
+	return t.request()
+}
+
+// 合约相关请求,账户使用会话令牌签名
+type ContractRequest struct {
+	Account         *string  `json:"account"`
+	Code            *string  `json:"code"`             //部署时使用,汇编
+	Salt            *string  `json:"salt"`             //部署时使用,可选,区分相同字节码的合约
+	ContractAddress *string  `json:"contract_address"` //调用时使用
+	Args            []string `json:"args"`             //调用时使用,格式同汇编中的值
+	GasLimit        *uint64  `json:"gas_limit"`
+}
+
+// 签名部署交易,返回交易和合约地址
+func (cr *ContractRequest) Deploy(p *params.Params, w *Wallet, nonce uint64) (*block.TransactionRequest, string, error) {
+	if cr.Code == nil || cr.GasLimit == nil {
+		return nil, "", errors.New("missing code or gas_limit")
+	}
+	code, err := vm.Assemble(*cr.Code)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := vm.Validate(code); err != nil {
+		return nil, "", err
+	}
+	var salt []byte
+	if cr.Salt != nil {
+		salt = []byte(*cr.Salt)
+	}
+	contract := address.FromContract(w.BlockChainAddress(), code, salt, p)
+	return NewContractTransaction(p, w, contract, nonce, code, salt, *cr.GasLimit), contract, nil
+}
+
+// 签名调用交易
+func (cr *ContractRequest) Call(p *params.Params, w *Wallet, nonce uint64) (*block.TransactionRequest, error) {
+	if cr.ContractAddress == nil || cr.GasLimit == nil {
+		return nil, errors.New("missing contract_address or gas_limit")
+	}
+	if !address.IsContract(*cr.ContractAddress, p) {
+		return nil, fmt.Errorf("%s is not a contract address", *cr.ContractAddress)
+	}
+	args := make([][]byte, len(cr.Args))
+	for i, a := range cr.Args {
+		b, err := vm.ParseArg(a)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i, err)
+		}
+		args[i] = b
+	}
+	input, err := vm.EncodeArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return NewContractTransaction(p, w, *cr.ContractAddress, nonce, nil, input, *cr.GasLimit), nil
+}
@@ -0,0 +1,254 @@
+package wallet
+
+import (
+	"GoProject/params"
+	"GoProject/utils"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	HARDENED_OFFSET       = 0x80000000 //强化派生的索引起点
+	DEFAULT_GAP_LIMIT     = 20         //连续多少个未使用的地址后停止扫描
+	MNEMONIC_ENTROPY_BITS = 128        //12个助记词
+)
+
+var ErrInvalidChild = errors.New("invalid child key, use the next index")
+
+// BIP44默认路径 m/44'/coin_type'/0'/0,后面再加地址索引
+func DefaultDerivationPath(p *params.Params) string {
+	return fmt.Sprintf("m/44'/%d'/0'/0", p.HDCoinType)
+}
+
+// 主密钥的HMAC密钥: secp256k1使用BIP32的"Bitcoin seed",P-256使用SLIP-0010的"Nist256p1 seed"
+func masterKeySeed(curve elliptic.Curve) ([]byte, error) {
+	id, err := utils.CurveIdOf(curve)
+	if err != nil {
+		return nil, err
+	}
+	switch id {
+	case utils.CURVE_SECP256K1:
+		return []byte("Bitcoin seed"), nil
+	case utils.CURVE_P256:
+		return []byte("Nist256p1 seed"), nil
+	}
+	return nil, fmt.Errorf("unsupported curve %s", id)
+}
+
+// BIP32扩展私钥
+type ExtendedKey struct {
+	curve     elliptic.Curve
+	key       []byte //32字节私钥
+	chainCode []byte //32字节链码
+	depth     uint8
+	index     uint32
+}
+
+// 由种子生成主密钥
+func NewMasterKey(curve elliptic.Curve, seed []byte) (*ExtendedKey, error) {
+	hmacKey, err := masterKeySeed(curve)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha512.New, hmacKey)
+	mac.Write(seed)
+	i := mac.Sum(nil)
+	k := new(big.Int).SetBytes(i[:32])
+	if k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("invalid master key, use another seed")
+	}
+	return &ExtendedKey{curve: curve, key: i[:32], chainCode: i[32:]}, nil
+}
+
+// 派生第i个子密钥,i >= HARDENED_OFFSET 时为强化派生
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	data := make([]byte, 0, 37)
+	if i >= HARDENED_OFFSET {
+		data = append(data, 0x00)
+		data = append(data, k.key...)
+	} else {
+		data = append(data, utils.CompressPublicKey(&k.PrivateKey().PublicKey)...)
+	}
+	data = binary.BigEndian.AppendUint32(data, i)
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := k.curve.Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, ErrInvalidChild
+	}
+	child := il.Add(il, new(big.Int).SetBytes(k.key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, ErrInvalidChild
+	}
+	key := make([]byte, 32)
+	child.FillBytes(key)
+	return &ExtendedKey{curve: k.curve, key: key, chainCode: sum[32:], depth: k.depth + 1, index: i}, nil
+}
+
+// 按路径派生,例如 m/44'/0'/0'/0/5
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	key := k
+	for _, i := range indexes {
+		if key, err = key.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func (k *ExtendedKey) PrivateKey() *ecdsa.PrivateKey {
+	d := new(big.Int).SetBytes(k.key)
+	x, y := k.curve.ScalarBaseMult(k.key)
+	return &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: k.curve, X: x, Y: y}, D: d}
+}
+
+// 解析派生路径,'或h表示强化派生
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q", path)
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		i, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || i >= HARDENED_OFFSET {
+			return nil, fmt.Errorf("invalid derivation path %q", path)
+		}
+		if hardened {
+			i += HARDENED_OFFSET
+		}
+		indexes = append(indexes, uint32(i))
+	}
+	return indexes, nil
+}
+
+// 生成新的助记词
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(MNEMONIC_ENTROPY_BITS)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// 分层确定性钱包: 一组助记词可以恢复所有地址
+type HDWallet struct {
+	mnemonic string       //由种子加载时为空
+	seed     []byte       //BIP39种子,加密保存在密钥库中
+	path     string       //地址索引之前的路径
+	account  *ExtendedKey //path对应的扩展私钥
+	params   *params.Params
+}
+
+// 生成新的助记词并创建HD钱包,path为空时使用BIP44默认路径
+func NewHDWallet(p *params.Params, curve elliptic.Curve, passphrase string, path string) (*HDWallet, error) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		return nil, err
+	}
+	return RestoreHDWallet(p, curve, mnemonic, passphrase, path)
+}
+
+// 由助记词恢复HD钱包,同一组助记词在不同曲线上派生出不同的地址
+func RestoreHDWallet(p *params.Params, curve elliptic.Curve, mnemonic string, passphrase string, path string) (*HDWallet, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	hw, err := hdWalletFromSeed(p, curve, seed, path)
+	if err != nil {
+		return nil, err
+	}
+	hw.mnemonic = mnemonic
+	return hw, nil
+}
+
+func hdWalletFromSeed(p *params.Params, curve elliptic.Curve, seed []byte, path string) (*HDWallet, error) {
+	if path == "" {
+		path = DefaultDerivationPath(p)
+	}
+	master, err := NewMasterKey(curve, seed)
+	if err != nil {
+		return nil, err
+	}
+	account, err := master.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+	return &HDWallet{seed: seed, path: path, account: account, params: p}, nil
+}
+
+func (hw *HDWallet) Mnemonic() string {
+	return hw.mnemonic
+}
+
+func (hw *HDWallet) Path() string {
+	return hw.path
+}
+
+// 第index个地址的钱包
+func (hw *HDWallet) Wallet(index uint32) (*Wallet, error) {
+	if index >= HARDENED_OFFSET {
+		return nil, fmt.Errorf("invalid address index %d", index)
+	}
+	k, err := hw.account.Child(index)
+	if err != nil {
+		return nil, err
+	}
+	return NewWalletFromPrivateKey(k.PrivateKey(), hw.params), nil
+}
+
+// 按gap limit扫描地址: 从0开始派生,连续gapLimit个地址未使用时停止
+// 返回所有已使用的钱包和下一个可用的地址索引
+func (hw *HDWallet) Scan(gapLimit int, used func(address string) (bool, error)) ([]*Wallet, uint32, error) {
+	if gapLimit <= 0 {
+		gapLimit = DEFAULT_GAP_LIMIT
+	}
+	wallets := make([]*Wallet, 0)
+	next := uint32(0)
+	gap := 0
+	for index := uint32(0); gap < gapLimit && index < HARDENED_OFFSET; index++ {
+		w, err := hw.Wallet(index)
+		if err == ErrInvalidChild {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		ok, err := used(w.BlockChainAddress())
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			wallets = append(wallets, w)
+			next = index + 1
+			gap = 0
+		} else {
+			gap += 1
+		}
+	}
+	return wallets, next, nil
+}
@@ -0,0 +1,182 @@
+package wallet
+
+import (
+	"GoProject/block"
+	"GoProject/params"
+	"GoProject/utils"
+	"crypto/elliptic"
+	"encoding/hex"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+type hdVector struct {
+	path      string
+	chainCode string
+	key       string
+}
+
+// BIP32测试向量1与SLIP-0010 nist256p1测试向量1,种子相同
+var hdSeed = "000102030405060708090a0b0c0d0e0f"
+
+var hdVectors = []struct {
+	name    string
+	curve   elliptic.Curve
+	vectors []hdVector
+}{
+	{"secp256k1", secp256k1.S256(), []hdVector{
+		{"m", "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{"m/0'", "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0'/1/2'", "04466b9cc8e161e966409ca52986c584f07e9dc81f735db683c3ff6ec7b1503f", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+		{"m/0'/1/2'/2", "cfb71883f01676f587d023cc53a35bc7f88f724b1f8c2892ac1275ac822a3edd", "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4"},
+		{"m/0'/1/2'/2/1000000000", "c783e67b921d2beb8f6b389cc646d7263b4145701dadd2161548a8b078e65e9e", "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8"},
+	}},
+	{"p256", elliptic.P256(), []hdVector{
+		{"m", "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+		{"m/0h", "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+		{"m/0h/1", "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+		{"m/0h/1/2h", "98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318", "694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7"},
+		{"m/0h/1/2h/2", "ba96f776a5c3907d7fd48bde5620ee374d4acfd540378476019eab70790c63a0", "5996c37fd3dd2679039b23ed6f70b506c6b56b3cb5e424681fb0fa64caf82aaa"},
+		{"m/0h/1/2h/2/1000000000", "b9b7b82d326bb9cb5b5b121066feea4eb93d5241103c9e7a18aad40f1dde8059", "21c4f269ef0a5fd1badf47eeacebeeaa3de22eb8e5b0adcd0f27dd99d34d0119"},
+	}},
+}
+
+func TestDeriveVectors(t *testing.T) {
+	seed, _ := hex.DecodeString(hdSeed)
+	for _, c := range hdVectors {
+		master, err := NewMasterKey(c.curve, seed)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for _, v := range c.vectors {
+			k, err := master.Derive(v.path)
+			if err != nil {
+				t.Errorf("%s %s: %v", c.name, v.path, err)
+				continue
+			}
+			if got := hex.EncodeToString(k.chainCode); got != v.chainCode {
+				t.Errorf("%s %s: chain code %s, want %s", c.name, v.path, got, v.chainCode)
+			}
+			if got := hex.EncodeToString(k.key); got != v.key {
+				t.Errorf("%s %s: key %s, want %s", c.name, v.path, got, v.key)
+			}
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	indexes, err := ParsePath("m/44'/1h/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{HARDENED_OFFSET + 44, HARDENED_OFFSET + 1, 0}
+	for i := range want {
+		if len(indexes) != len(want) || indexes[i] != want[i] {
+			t.Fatalf("indexes %v, want %v", indexes, want)
+		}
+	}
+	for _, path := range []string{"", "44'/0", "m/", "m/x", "m/-1", "m/2147483648", "m/0''"} {
+		if _, err := ParsePath(path); err == nil {
+			t.Errorf("%q: expected error", path)
+		}
+	}
+}
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// 同一组助记词恢复出相同的地址
+func TestRestoreHDWallet(t *testing.T) {
+	p := params.RegTest
+	a, err := RestoreHDWallet(p, elliptic.P256(), testMnemonic, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Path() != DefaultDerivationPath(p) {
+		t.Errorf("path %s, want the BIP44 default", a.Path())
+	}
+	b, err := RestoreHDWallet(p, elliptic.P256(), "  "+testMnemonic+"\n", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wa, _ := a.Wallet(0)
+	wb, _ := b.Wallet(0)
+	if wa.BlockChainAddress() != wb.BlockChainAddress() {
+		t.Fatalf("restored addresses differ")
+	}
+	//不同的passphrase派生出不同的地址
+	c, _ := RestoreHDWallet(p, elliptic.P256(), testMnemonic, "secret", "")
+	if wc, _ := c.Wallet(0); wc.BlockChainAddress() == wa.BlockChainAddress() {
+		t.Fatalf("passphrase ignored")
+	}
+	//同一组助记词在不同曲线上派生出不同的地址
+	k, _ := RestoreHDWallet(p, secp256k1.S256(), testMnemonic, "", "")
+	if wk, _ := k.Wallet(0); wk.BlockChainAddress() == wa.BlockChainAddress() {
+		t.Fatalf("secp256k1 and p256 wallets share an address")
+	}
+	if _, err := RestoreHDWallet(p, elliptic.P256(), "abandon abandon", "", ""); err == nil {
+		t.Fatalf("invalid mnemonic accepted")
+	}
+	if _, err := a.Wallet(HARDENED_OFFSET); err == nil {
+		t.Fatalf("hardened address index accepted")
+	}
+}
+
+// 连续gapLimit个未使用的地址后停止扫描
+func TestScan(t *testing.T) {
+	hw, _ := RestoreHDWallet(params.RegTest, elliptic.P256(), testMnemonic, "", "")
+	used := make(map[string]bool)
+	for _, index := range []uint32{0, 2, 5} {
+		w, _ := hw.Wallet(index)
+		used[w.BlockChainAddress()] = true
+	}
+	checked := 0
+	wallets, next, err := hw.Scan(3, func(address string) (bool, error) {
+		checked += 1
+		return used[address], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wallets) != 3 || next != 6 {
+		t.Fatalf("found %d wallets, next index %d", len(wallets), next)
+	}
+	if checked != 9 {
+		t.Errorf("checked %d addresses, want 9", checked)
+	}
+	//地址5在第一段空隙之后,gap limit为2时找不到
+	if wallets, next, _ := hw.Scan(2, func(address string) (bool, error) { return used[address], nil }); len(wallets) != 2 || next != 3 {
+		t.Errorf("gap limit 2: found %d wallets, next index %d", len(wallets), next)
+	}
+}
+
+// 余额为0的地址如果有交易记录或发出过交易,恢复时仍然视为已使用
+func TestScanAddressHistory(t *testing.T) {
+	p := params.RegTest
+	hw, _ := RestoreHDWallet(p, elliptic.P256(), testMnemonic, "", "")
+	w0, _ := hw.Wallet(0)
+	receiver := NewWallet(p).BlockChainAddress()
+	bc := block.NewBlockChain(receiver, 0, p)
+	bc.Generate(1, w0.BlockChainAddress())
+	tr, err := NewUnsignedTransaction(p, w0.BlockChainAddress(), receiver, block.MINING_REWARD, 0, 0, nil).Sign(w0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := utils.DecodePublicKey(*tr.SenderPublicKey)
+	if err := bc.AcceptTransaction(*tr.SenderBlockChainAddress, receiver, *tr.Value, tr.Nonce, 0, nil, publicKey,
+		utils.SignatureFromString(*tr.Signature)); err != nil {
+		t.Fatal(err)
+	}
+	bc.Generate(1, receiver)
+	if amount := bc.CalculateTotalAmount(w0.BlockChainAddress()); amount != 0 {
+		t.Fatalf("balance %v, want 0", amount)
+	}
+	wallets, next, err := hw.Scan(3, func(address string) (bool, error) {
+		_, n := bc.AddressTransactions(address, 0, 1)
+		return n > 0 || bc.NextNonce(address) > 0, nil
+	})
+	if err != nil || len(wallets) != 1 || next != 1 || wallets[0].BlockChainAddress() != w0.BlockChainAddress() {
+		t.Errorf("found %d wallets, next index %d, err %v", len(wallets), next, err)
+	}
+}
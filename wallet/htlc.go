@@ -0,0 +1,116 @@
+package wallet
+
+import (
+	"GoProject/block"
+	"GoProject/params"
+	"GoProject/script"
+	"GoProject/utils"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// 生成随机的原像和它的SHA-256
+func NewPreimage() ([]byte, [32]byte, error) {
+	preimage := make([]byte, script.PREIMAGE_LEN)
+	if _, err := rand.Read(preimage); err != nil {
+		return nil, [32]byte{}, err
+	}
+	return preimage, sha256.Sum256(preimage), nil
+}
+
+// 用原像赎回合约中的value,钱包必须是合约的接收方
+func RedeemHTLC(p *params.Params, lock script.Script, w *Wallet, preimage []byte, receiver string,
+	value float32, nonce uint64) (*block.TransactionRequest, error) {
+	h, err := script.ParseHTLC(lock)
+	if err != nil {
+		return nil, err
+	}
+	if !samePublicKey(h.Recipient, w.PublicKey()) {
+		return nil, fmt.Errorf("wallet %s is not the recipient of the htlc", w.BlockChainAddress())
+	}
+	if len(preimage) != script.PREIMAGE_LEN || sha256.Sum256(preimage) != h.Hash {
+		return nil, errors.New("preimage does not match the htlc hash")
+	}
+	return spendScript(p, lock, w, receiver, value, nonce, func(sig []byte) script.Script {
+		return script.RedeemHTLC(sig, preimage)
+	})
+}
+
+// 超时后取回合约中的value,钱包必须是合约的退款方
+func RefundHTLC(p *params.Params, lock script.Script, w *Wallet, receiver string,
+	value float32, nonce uint64) (*block.TransactionRequest, error) {
+	h, err := script.ParseHTLC(lock)
+	if err != nil {
+		return nil, err
+	}
+	if !samePublicKey(h.Refund, w.PublicKey()) {
+		return nil, fmt.Errorf("wallet %s is not the refund key of the htlc", w.BlockChainAddress())
+	}
+	return spendScript(p, lock, w, receiver, value, nonce, script.RefundHTLC)
+}
+
+// 签名从脚本地址转出的交易,由unlock生成解锁脚本
+func spendScript(p *params.Params, lock script.Script, w *Wallet, receiver string, value float32, nonce uint64,
+	unlock func(sig []byte) script.Script) (*block.TransactionRequest, error) {
+	st := NewScriptTransaction(p, lock, receiver, value, nonce, 0)
+	raw, err := st.RawSignature(w)
+	if err != nil {
+		return nil, err
+	}
+	sig, _ := hex.DecodeString(raw.Signature)
+	return st.Finalize(unlock(sig)), nil
+}
+
+func samePublicKey(a, b *ecdsa.PublicKey) bool {
+	x, err := utils.EncodePublicKey(a)
+	if err != nil {
+		return false
+	}
+	y, err := utils.EncodePublicKey(b)
+	return err == nil && bytes.Equal(x, y)
+}
+
+// 哈希时间锁合约相关请求,账户使用会话令牌签名
+type HTLCRequest struct {
+	Account                   *string `json:"account"`
+	RecipientPublicKey        *string `json:"recipient_public_key"`         //创建时使用
+	Hash                      *string `json:"hash"`                         //创建时使用,为空时生成新的原像
+	Timeout                   *int64  `json:"timeout"`                      //创建时使用,区块高度或unix时间(秒)
+	Value                     *string `json:"value"`                        //创建时存入合约的数量,为空时不存入
+	LockScript                *string `json:"lock_script"`                  //赎回和退款时使用
+	Preimage                  *string `json:"preimage"`                     //赎回时使用
+	ReceiverBlockChainAddress *string `json:"receiver_block_chain_address"` //赎回和退款时使用,默认为账户地址
+}
+
+// 由请求创建合约,refund为创建者的公钥;未给出哈希时返回新生成的原像
+func (hr *HTLCRequest) HTLC(refund *ecdsa.PublicKey) (*script.HTLC, []byte, error) {
+	if hr.RecipientPublicKey == nil || hr.Timeout == nil {
+		return nil, nil, errors.New("missing recipient_public_key or timeout")
+	}
+	recipient, err := utils.DecodePublicKey(*hr.RecipientPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := &script.HTLC{Recipient: recipient, Refund: refund, Timeout: *hr.Timeout}
+	var preimage []byte
+	if hr.Hash == nil {
+		if preimage, h.Hash, err = NewPreimage(); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		b, err := hex.DecodeString(*hr.Hash)
+		if err != nil || len(b) != len(h.Hash) {
+			return nil, nil, fmt.Errorf("invalid hash %q", *hr.Hash)
+		}
+		copy(h.Hash[:], b)
+	}
+	if _, err := h.Script(); err != nil {
+		return nil, nil, err
+	}
+	return h, preimage, nil
+}
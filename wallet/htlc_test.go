@@ -0,0 +1,122 @@
+package wallet
+
+import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/params"
+	"GoProject/script"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// 提交脚本交易到节点
+func acceptScript(bc *block.BlockChain, tr *block.TransactionRequest) error {
+	lock, _ := script.FromHex(*tr.LockScript)
+	unlock, _ := script.FromHex(*tr.UnlockScript)
+	return bc.AcceptScriptTransaction(*tr.SenderBlockChainAddress, *tr.ReceiverBlockChainAddress, *tr.Value,
+		tr.Nonce, tr.LockTime, nil, lock, unlock)
+}
+
+// 创建以高度timeout超时的合约,并向合约地址存入一个区块的奖励
+func fundHTLC(t *testing.T, recipient, refund *Wallet, timeout int64) (*block.BlockChain, script.Script, []byte) {
+	t.Helper()
+	preimage, hash, err := NewPreimage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := (&script.HTLC{Hash: hash, Recipient: recipient.PublicKey(), Refund: refund.PublicKey(), Timeout: timeout}).Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc := block.NewBlockChain(refund.BlockChainAddress(), 0, params.RegTest)
+	if _, err := bc.Generate(1, address.FromScript(lock, params.RegTest)); err != nil {
+		t.Fatal(err)
+	}
+	return bc, lock, preimage
+}
+
+func TestHTLCRedeem(t *testing.T) {
+	p := params.RegTest
+	alice, bob := NewWallet(p), NewWallet(p)
+	bc, lock, preimage := fundHTLC(t, bob, alice, 100)
+	h, err := script.ParseHTLC(lock)
+	if err != nil || h.Timeout != 100 {
+		t.Fatalf("parse htlc %+v, %v", h, err)
+	}
+
+	if _, err := RedeemHTLC(p, lock, alice, preimage, alice.BlockChainAddress(), 0.5, 0); err == nil {
+		t.Error("refund key redeemed")
+	}
+	wrong, _, _ := NewPreimage()
+	if _, err := RedeemHTLC(p, lock, bob, wrong, bob.BlockChainAddress(), 0.5, 0); err == nil {
+		t.Error("wrong preimage accepted by wallet")
+	}
+	//节点同样拒绝错误的原像
+	tr, _ := RedeemHTLC(p, lock, bob, preimage, bob.BlockChainAddress(), 0.5, 0)
+	bad := script.RedeemHTLC(make([]byte, 64), wrong).Hex()
+	forged := *tr
+	forged.UnlockScript = &bad
+	if err := acceptScript(bc, &forged); err == nil {
+		t.Error("redeem with wrong preimage accepted")
+	}
+
+	if bc.FindPreimage(h.Hash) != nil {
+		t.Fatal("preimage found before redeem")
+	}
+	if err := acceptScript(bc, tr); err != nil {
+		t.Fatal(err)
+	}
+	r := bc.FindPreimage(h.Hash)
+	if r == nil || r.Preimage != hex.EncodeToString(preimage) || r.Height != -1 || r.Confirmed {
+		t.Fatalf("revealed in pool %+v", r)
+	}
+	bc.Generate(1, "")
+	r = bc.FindPreimage(h.Hash)
+	if r == nil || r.Height != 2 || !r.Confirmed || r.Recipient != bob.BlockChainAddress() {
+		t.Fatalf("revealed in chain %+v", r)
+	}
+	if amount := bc.CalculateTotalAmount(bob.BlockChainAddress()); amount != 0.5 {
+		t.Errorf("recipient has %v", amount)
+	}
+}
+
+// 退款只能在超时之后
+func TestHTLCRefund(t *testing.T) {
+	p := params.RegTest
+	alice, bob := NewWallet(p), NewWallet(p)
+	bc, lock, _ := fundHTLC(t, bob, alice, 4)
+	if _, err := RefundHTLC(p, lock, bob, bob.BlockChainAddress(), 0.5, 0); err == nil {
+		t.Error("recipient refunded")
+	}
+	tr, err := RefundHTLC(p, lock, alice, alice.BlockChainAddress(), 0.5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//下一个区块的高度为2、3时仍然锁定
+	for len(bc.Chain()) < 4 {
+		if err := acceptScript(bc, tr); err == nil || !strings.Contains(err.Error(), "locktime") {
+			t.Fatalf("refund at height %d: %v", len(bc.Chain()), err)
+		}
+		bc.Generate(1, "")
+	}
+	if err := acceptScript(bc, tr); err != nil {
+		t.Fatalf("refund at height %d: %v", len(bc.Chain()), err)
+	}
+}
+
+func TestParseHTLC(t *testing.T) {
+	p := params.RegTest
+	alice, bob := NewWallet(p), NewWallet(p)
+	_, lock, _ := fundHTLC(t, bob, alice, 100)
+	changed := append(script.Script{}, lock...)
+	changed[len(changed)-1] = byte(script.OP_CHECKSIGVERIFY)
+	for _, s := range []script.Script{nil, lock[:len(lock)-1], changed} {
+		if _, err := script.ParseHTLC(s); err != script.ErrNotHTLC {
+			t.Errorf("%x: %v", []byte(s), err)
+		}
+	}
+	if _, err := (&script.HTLC{Recipient: bob.PublicKey(), Refund: alice.PublicKey()}).Script(); err == nil {
+		t.Error("htlc without timeout")
+	}
+}
@@ -0,0 +1,471 @@
+package wallet
+
+import (
+	"GoProject/params"
+	"GoProject/utils"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	KEYSTORE_VERSION = 1
+	KEYSTORE_KDF     = "scrypt"
+	KEYSTORE_CIPHER  = "aes-256-gcm"
+	SCRYPT_N         = 1 << 15 //约100ms,32MB内存
+	SCRYPT_R         = 8
+	SCRYPT_P         = 1
+	SCRYPT_MAX_N     = 1 << 20 //导入的密钥文件允许的最大N,防止耗尽内存
+	SCRYPT_MAX_R     = 32
+	SCRYPT_MAX_P     = 16      //p个块依次计算,限制CPU时间
+	SCRYPT_MAX_NR    = 1 << 21 //scrypt使用128*N*r字节内存,最多256MB
+	SCRYPT_KEY_LEN   = 32
+	HD_WALLET_FILE   = "hdwallet.key" //加密的HD钱包种子,不是.json文件,List不会列出
+)
+
+var (
+	ErrKeyNotFound   = errors.New("key not found in keystore")
+	ErrDecrypt       = errors.New("could not decrypt key with given passphrase")
+	ErrAddressExists = errors.New("key already exists in keystore")
+
+	ErrHDWalletNotFound = errors.New("no hd wallet in keystore")
+	ErrHDWalletExists   = errors.New("keystore already has another hd wallet")
+)
+
+// 密钥文件,只有私钥是加密的
+type keyFile struct {
+	Version   int         `json:"version"`
+	Address   string      `json:"address"`
+	Curve     string      `json:"curve"`
+	PublicKey string      `json:"public_key"`
+	Crypto    keyFileData `json:"crypto"`
+}
+
+type keyFileData struct {
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       string `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Nonce      string `json:"nonce"`
+	CipherText string `json:"ciphertext"`
+}
+
+// 密钥库中保存的公开信息,不包含私钥
+type KeyInfo struct {
+	Address   string `json:"block_chain_address"`
+	PublicKey string `json:"public_key"`
+	Curve     string `json:"curve"`
+}
+
+// 使用scrypt派生密钥,AES-GCM加密私钥,地址作为附加数据防止被替换
+func EncryptKey(w *Wallet, passphrase string) ([]byte, error) {
+	id, err := utils.CurveIdOf(w.publicKey.Curve)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	w.privateKey.D.FillBytes(key)
+	c, err := sealKey(key, []byte(w.blockChainAddress), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&keyFile{
+		Version:   KEYSTORE_VERSION,
+		Address:   w.blockChainAddress,
+		Curve:     id.String(),
+		PublicKey: w.PublicKeyStr(),
+		Crypto:    *c,
+	}, "", "  ")
+}
+
+// 用passphrase加密plaintext,ad为不加密但参与认证的附加数据
+func sealKey(plaintext []byte, ad []byte, passphrase string) (*keyFileData, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newKeyCipher(passphrase, salt, SCRYPT_N, SCRYPT_R, SCRYPT_P)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &keyFileData{
+		KDF:        KEYSTORE_KDF,
+		N:          SCRYPT_N,
+		R:          SCRYPT_R,
+		P:          SCRYPT_P,
+		Salt:       hex.EncodeToString(salt),
+		Cipher:     KEYSTORE_CIPHER,
+		Nonce:      hex.EncodeToString(nonce),
+		CipherText: hex.EncodeToString(aead.Seal(nil, nonce, plaintext, ad)),
+	}, nil
+}
+
+// 解密sealKey的结果,密码错误或附加数据被修改时返回ErrDecrypt
+func openKey(c *keyFileData, ad []byte, passphrase string) ([]byte, error) {
+	salt, err := hex.DecodeString(c.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file salt: %v", err)
+	}
+	nonce, err := hex.DecodeString(c.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file nonce: %v", err)
+	}
+	cipherText, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file ciphertext: %v", err)
+	}
+	aead, err := newKeyCipher(passphrase, salt, c.N, c.R, c.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid key file nonce length")
+	}
+	plaintext, err := aead.Open(nil, nonce, cipherText, ad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// 解密密钥文件,地址与当前网络不一致时返回错误
+func DecryptKey(data []byte, passphrase string, p *params.Params) (*Wallet, error) {
+	kf, err := parseKeyFile(data)
+	if err != nil {
+		return nil, err
+	}
+	key, err := openKey(&kf.Crypto, []byte(kf.Address), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	curve, err := utils.CurveByName(kf.Curve)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := privateKeyFromBytes(curve, key)
+	if err != nil {
+		return nil, err
+	}
+	w := NewWalletFromPrivateKey(privateKey, p)
+	if w.blockChainAddress != kf.Address {
+		return nil, fmt.Errorf("key file address %s does not match %s network", kf.Address, p.Name)
+	}
+	return w, nil
+}
+
+func parseKeyFile(data []byte) (*keyFile, error) {
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("invalid key file: %v", err)
+	}
+	if kf.Version != KEYSTORE_VERSION {
+		return nil, fmt.Errorf("unsupported key file version %d", kf.Version)
+	}
+	if kf.Crypto.KDF != KEYSTORE_KDF || kf.Crypto.Cipher != KEYSTORE_CIPHER {
+		return nil, fmt.Errorf("unsupported key file kdf %q or cipher %q", kf.Crypto.KDF, kf.Crypto.Cipher)
+	}
+	if err := checkAddressName(kf.Address); err != nil {
+		return nil, err
+	}
+	return &kf, nil
+}
+
+func newKeyCipher(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	if n <= 1 || n > SCRYPT_MAX_N || r <= 0 || r > SCRYPT_MAX_R || p <= 0 || p > SCRYPT_MAX_P || n*r > SCRYPT_MAX_NR {
+		return nil, fmt.Errorf("invalid scrypt parameters n=%d r=%d p=%d", n, r, p)
+	}
+	dk, err := scrypt.Key([]byte(passphrase), salt, n, r, p, SCRYPT_KEY_LEN)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(dk)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 由32字节私钥恢复ecdsa私钥
+func privateKeyFromBytes(curve elliptic.Curve, key []byte) (*ecdsa.PrivateKey, error) {
+	d := new(big.Int).SetBytes(key)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+	x, y := curve.ScalarBaseMult(d.FillBytes(make([]byte, 32)))
+	return &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}, D: d}, nil
+}
+
+// 地址用作文件名,不允许包含路径
+func checkAddressName(address string) error {
+	if address == "" || strings.ContainsAny(address, `/\.`) {
+		return fmt.Errorf("invalid address %q", address)
+	}
+	return nil
+}
+
+// 密钥库: 每个地址一个加密的密钥文件 <address>.json
+type KeyStore struct {
+	dir    string
+	params *params.Params
+}
+
+// 打开密钥库,目录不存在时创建
+func NewKeyStore(dir string, p *params.Params) (*KeyStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &KeyStore{dir, p}, nil
+}
+
+func (ks *KeyStore) Dir() string {
+	return ks.dir
+}
+
+func (ks *KeyStore) keyPath(address string) (string, error) {
+	if err := checkAddressName(address); err != nil {
+		return "", err
+	}
+	return filepath.Join(ks.dir, address+".json"), nil
+}
+
+// 加密保存钱包,已存在时返回ErrAddressExists
+func (ks *KeyStore) Store(w *Wallet, passphrase string) error {
+	data, err := EncryptKey(w, passphrase)
+	if err != nil {
+		return err
+	}
+	return ks.write(w.blockChainAddress, data)
+}
+
+func (ks *KeyStore) write(address string, data []byte) error {
+	path, err := ks.keyPath(address)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return ErrAddressExists
+	}
+	return ks.writeFile(path, data)
+}
+
+// 先写临时文件再重命名,避免留下不完整的密钥文件
+func (ks *KeyStore) writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(ks.dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 加载并解密地址对应的钱包
+func (ks *KeyStore) Load(address string, passphrase string) (*Wallet, error) {
+	data, err := ks.Export(address)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptKey(data, passphrase, ks.params)
+}
+
+// 导出加密的密钥文件
+func (ks *KeyStore) Export(address string) ([]byte, error) {
+	path, err := ks.keyPath(address)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrKeyNotFound
+	}
+	return data, err
+}
+
+// 导入加密的密钥文件,导入前先用passphrase验证
+func (ks *KeyStore) Import(data []byte, passphrase string) (*Wallet, error) {
+	w, err := DecryptKey(data, passphrase, ks.params)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.write(w.blockChainAddress, data); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// 导入hex编码的私钥,使用passphrase加密保存
+func (ks *KeyStore) ImportPrivateKey(curve elliptic.Curve, privateKey string, passphrase string) (*Wallet, error) {
+	key, err := hex.DecodeString(privateKey)
+	if err != nil || len(key) > 32 {
+		return nil, errors.New("invalid private key")
+	}
+	pk, err := privateKeyFromBytes(curve, key)
+	if err != nil {
+		return nil, err
+	}
+	w := NewWalletFromPrivateKey(pk, ks.params)
+	if err := ks.Store(w, passphrase); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// 列出密钥库中的所有地址,按地址排序
+func (ks *KeyStore) List() ([]*KeyInfo, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*KeyInfo, 0)
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(ks.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		kf, err := parseKeyFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.Name(), err)
+		}
+		keys = append(keys, &KeyInfo{Address: kf.Address, PublicKey: kf.PublicKey, Curve: kf.Curve})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Address < keys[j].Address })
+	return keys, nil
+}
+
+// 加载密钥库中的第一个钱包,密钥库为空时创建新钱包并保存
+func (ks *KeyStore) LoadOrCreate(curve elliptic.Curve, passphrase string) (*Wallet, bool, error) {
+	keys, err := ks.List()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(keys) > 0 {
+		w, err := ks.Load(keys[0].Address, passphrase)
+		return w, false, err
+	}
+	w := NewWalletWithCurve(curve, ks.params)
+	if err := ks.Store(w, passphrase); err != nil {
+		return nil, false, err
+	}
+	return w, true, nil
+}
+
+// 加密保存HD钱包派生的钱包,已保存的地址跳过,返回它们的公开信息
+func (ks *KeyStore) StoreDerived(wallets []*Wallet, passphrase string) ([]*KeyInfo, error) {
+	keys := make([]*KeyInfo, len(wallets))
+	for i, w := range wallets {
+		if err := ks.Store(w, passphrase); err != nil && err != ErrAddressExists {
+			return nil, err
+		}
+		keys[i] = w.KeyInfo()
+	}
+	return keys, nil
+}
+
+// 加密的HD钱包文件,曲线和路径作为附加数据,种子是加密的
+type hdWalletFile struct {
+	Version int         `json:"version"`
+	Curve   string      `json:"curve"`
+	Path    string      `json:"path"`
+	Crypto  keyFileData `json:"crypto"`
+}
+
+func (f *hdWalletFile) ad() []byte {
+	return []byte(f.Curve + " " + f.Path)
+}
+
+// 加密保存HD钱包的种子,之后派生地址不需要再提供助记词
+// 密钥库中每个网络只保存一个HD钱包,已保存同一个钱包时直接返回
+func (ks *KeyStore) StoreHDWallet(hw *HDWallet, passphrase string) error {
+	if stored, err := ks.LoadHDWallet(passphrase); err == nil {
+		if stored.account.curve != hw.account.curve || stored.path != hw.path || !bytes.Equal(stored.seed, hw.seed) {
+			return ErrHDWalletExists
+		}
+		return nil
+	} else if err != ErrHDWalletNotFound {
+		return err
+	}
+	id, err := utils.CurveIdOf(hw.account.curve)
+	if err != nil {
+		return err
+	}
+	f := &hdWalletFile{Version: KEYSTORE_VERSION, Curve: id.String(), Path: hw.path}
+	c, err := sealKey(hw.seed, f.ad(), passphrase)
+	if err != nil {
+		return err
+	}
+	f.Crypto = *c
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ks.writeFile(filepath.Join(ks.dir, HD_WALLET_FILE), data)
+}
+
+// 加载并解密保存的HD钱包,返回的钱包没有助记词
+func (ks *KeyStore) LoadHDWallet(passphrase string) (*HDWallet, error) {
+	data, err := os.ReadFile(filepath.Join(ks.dir, HD_WALLET_FILE))
+	if os.IsNotExist(err) {
+		return nil, ErrHDWalletNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f hdWalletFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid hd wallet file: %v", err)
+	}
+	if f.Version != KEYSTORE_VERSION || f.Crypto.KDF != KEYSTORE_KDF || f.Crypto.Cipher != KEYSTORE_CIPHER {
+		return nil, fmt.Errorf("unsupported hd wallet file version %d", f.Version)
+	}
+	seed, err := openKey(&f.Crypto, f.ad(), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	curve, err := utils.CurveByName(f.Curve)
+	if err != nil {
+		return nil, err
+	}
+	return hdWalletFromSeed(ks.params, curve, seed, f.Path)
+}
+
+// 钱包的公开信息
+func (w *Wallet) KeyInfo() *KeyInfo {
+	id, _ := utils.CurveIdOf(w.publicKey.Curve)
+	return &KeyInfo{Address: w.blockChainAddress, PublicKey: w.PublicKeyStr(), Curve: id.String()}
+}
+
+// 密钥库相关请求
+type KeyStoreRequest struct {
+	Passphrase *string         `json:"passphrase"`
+	PrivateKey *string         `json:"private_key"` //导入hex私钥
+	KeyFile    json.RawMessage `json:"key_file"`    //导入加密的密钥文件
+}
@@ -0,0 +1,217 @@
+package wallet
+
+import (
+	"GoProject/params"
+	"crypto/elliptic"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestKeyStoreRoundTrip(t *testing.T) {
+	ks, err := NewKeyStore(t.TempDir(), params.RegTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, curve := range []elliptic.Curve{elliptic.P256(), secp256k1.S256()} {
+		w := NewWalletWithCurve(curve, params.RegTest)
+		if err := ks.Store(w, "secret"); err != nil {
+			t.Fatal(err)
+		}
+		if err := ks.Store(w, "secret"); err != ErrAddressExists {
+			t.Fatalf("store twice: %v", err)
+		}
+		got, err := ks.Load(w.BlockChainAddress(), "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.PrivateKeyStr() != w.PrivateKeyStr() || got.BlockChainAddress() != w.BlockChainAddress() {
+			t.Fatalf("loaded wallet differs from stored wallet")
+		}
+		if _, err := ks.Load(w.BlockChainAddress(), "wrong"); err != ErrDecrypt {
+			t.Fatalf("wrong passphrase: %v", err)
+		}
+	}
+	keys, err := ks.List()
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("list %d keys, err %v", len(keys), err)
+	}
+	if _, err := ks.Load("missing", "secret"); err != ErrKeyNotFound {
+		t.Fatalf("missing key: %v", err)
+	}
+	if _, err := ks.Load("../escape", "secret"); err == nil {
+		t.Fatalf("path in address accepted")
+	}
+}
+
+func TestKeyStoreImport(t *testing.T) {
+	src, _ := NewKeyStore(t.TempDir(), params.RegTest)
+	w := NewWallet(params.RegTest)
+	if err := src.Store(w, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := src.Export(w.BlockChainAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, _ := NewKeyStore(t.TempDir(), params.RegTest)
+	if _, err := dst.Import(data, "wrong"); err != ErrDecrypt {
+		t.Fatalf("import with wrong passphrase: %v", err)
+	}
+	if _, err := dst.Import(data, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.Import(data, "secret"); err != ErrAddressExists {
+		t.Fatalf("import twice: %v", err)
+	}
+
+	//另一个网络的密钥库拒绝该密钥文件
+	other, _ := NewKeyStore(t.TempDir(), params.MainNet)
+	if _, err := other.Import(data, "secret"); err == nil {
+		t.Fatalf("key file imported into another network")
+	}
+
+	//替换地址后认证失败
+	var kf keyFile
+	json.Unmarshal(data, &kf)
+	kf.Address = NewWallet(params.RegTest).BlockChainAddress()
+	swapped, _ := json.Marshal(&kf)
+	if _, err := dst.Import(swapped, "secret"); err != ErrDecrypt {
+		t.Fatalf("swapped address: %v", err)
+	}
+}
+
+func TestImportPrivateKey(t *testing.T) {
+	ks, _ := NewKeyStore(t.TempDir(), params.RegTest)
+	w := NewWallet(params.RegTest)
+	key := hex.EncodeToString(w.PrivateKey().D.FillBytes(make([]byte, 32)))
+	got, err := ks.ImportPrivateKey(elliptic.P256(), key, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BlockChainAddress() != w.BlockChainAddress() {
+		t.Fatalf("imported address %s, want %s", got.BlockChainAddress(), w.BlockChainAddress())
+	}
+	for _, key := range []string{"zz", "00", "ff" + key} {
+		if _, err := ks.ImportPrivateKey(elliptic.P256(), key, "secret"); err == nil {
+			t.Errorf("%q: invalid private key accepted", key)
+		}
+	}
+}
+
+func TestLoadOrCreate(t *testing.T) {
+	ks, _ := NewKeyStore(t.TempDir(), params.RegTest)
+	w, created, err := ks.LoadOrCreate(secp256k1.S256(), "secret")
+	if err != nil || !created {
+		t.Fatalf("created %v, err %v", created, err)
+	}
+	again, created, err := ks.LoadOrCreate(secp256k1.S256(), "secret")
+	if err != nil || created || again.BlockChainAddress() != w.BlockChainAddress() {
+		t.Fatalf("second call created %v, err %v", created, err)
+	}
+	if _, _, err := ks.LoadOrCreate(secp256k1.S256(), "wrong"); err != ErrDecrypt {
+		t.Fatalf("wrong passphrase: %v", err)
+	}
+}
+
+// HD钱包派生的私钥加密保存,返回和编码的信息都不包含私钥
+func TestStoreDerived(t *testing.T) {
+	ks, _ := NewKeyStore(t.TempDir(), params.RegTest)
+	hw, err := RestoreHDWallet(params.RegTest, elliptic.P256(), testMnemonic, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w0, _ := hw.Wallet(0)
+	w1, _ := hw.Wallet(1)
+	if err := ks.Store(w0, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	//已保存的地址跳过
+	keys, err := ks.StoreDerived([]*Wallet{w0, w1}, "secret")
+	if err != nil || len(keys) != 2 || keys[1].Address != w1.BlockChainAddress() {
+		t.Fatalf("keys %+v, err %v", keys, err)
+	}
+	got, err := ks.Load(w1.BlockChainAddress(), "secret")
+	if err != nil || got.PrivateKeyStr() != w1.PrivateKeyStr() {
+		t.Fatalf("derived key not stored: %v", err)
+	}
+	for _, v := range []interface{}{w1, keys[1]} {
+		m, _ := json.Marshal(v)
+		if strings.Contains(string(m), w1.PrivateKeyStr()) {
+			t.Errorf("private key in %s", m)
+		}
+	}
+}
+
+// 导入的密钥文件不能用过大的scrypt参数耗尽内存或CPU
+func TestKeyCipherLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		n, r, p int
+		ok      bool
+	}{
+		{"small", 16, 1, 1, true},
+		{"n too small", 1, 1, 1, false},
+		{"n too large", SCRYPT_MAX_N * 2, 1, 1, false},
+		{"r zero", 16, 0, 1, false},
+		{"r too large", 16, SCRYPT_MAX_R + 1, 1, false},
+		{"p zero", 16, 1, 0, false},
+		{"p too large", 16, 1, SCRYPT_MAX_P + 1, false},
+		{"memory too large", SCRYPT_MAX_N, SCRYPT_MAX_R, 1, false},
+		{"r overflow", 16, 1 << 62, 1, false},
+	}
+	for _, tt := range tests {
+		if _, err := newKeyCipher("secret", make([]byte, 32), tt.n, tt.r, tt.p); (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+// HD钱包的种子加密保存,之后派生地址不需要助记词
+func TestStoreHDWallet(t *testing.T) {
+	ks, _ := NewKeyStore(t.TempDir(), params.RegTest)
+	if _, err := ks.LoadHDWallet("secret"); err != ErrHDWalletNotFound {
+		t.Fatalf("empty keystore: %v", err)
+	}
+	hw, _ := RestoreHDWallet(params.RegTest, elliptic.P256(), testMnemonic, "bip39", "")
+	if err := ks.StoreHDWallet(hw, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	//再次保存同一个HD钱包不报错
+	if err := ks.StoreHDWallet(hw, "secret"); err != nil {
+		t.Errorf("store again: %v", err)
+	}
+	loaded, err := ks.LoadHDWallet("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := hw.Wallet(3)
+	got, _ := loaded.Wallet(3)
+	if got.BlockChainAddress() != want.BlockChainAddress() || loaded.Path() != hw.Path() || loaded.Mnemonic() != "" {
+		t.Errorf("loaded wallet differs")
+	}
+	if _, err := ks.LoadHDWallet("wrong"); err != ErrDecrypt {
+		t.Errorf("wrong passphrase: %v", err)
+	}
+	other, _ := RestoreHDWallet(params.RegTest, elliptic.P256(), testMnemonic, "", "")
+	if err := ks.StoreHDWallet(other, "secret"); err != ErrHDWalletExists {
+		t.Errorf("another hd wallet: %v", err)
+	}
+	if keys, err := ks.List(); err != nil || len(keys) != 0 {
+		t.Errorf("list %+v, err %v", keys, err)
+	}
+
+	//修改路径后认证失败
+	path := filepath.Join(ks.Dir(), HD_WALLET_FILE)
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(data), hw.Path(), "m/0", 1)), 0600)
+	if _, err := ks.LoadHDWallet("secret"); err != ErrDecrypt {
+		t.Errorf("changed path: %v", err)
+	}
+}
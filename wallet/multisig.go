@@ -0,0 +1,125 @@
+package wallet
+
+import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/params"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// 部分签名的多重签名交易,在多个持有人之间传递收集签名
+type PartiallySignedTransaction struct {
+	UnsignedTransaction
+	Multisig   *address.Multisig `json:"multisig"`
+	Signatures []string          `json:"signatures"` //与multisig中排序后的公钥一一对应,未签名为空字符串
+}
+
+// 从多重签名地址向receiver转账
+func NewPartiallySignedTransaction(p *params.Params, ms *address.Multisig, receiver string, value float32, nonce uint64,
+	lockTime int64) *PartiallySignedTransaction {
+	return &PartiallySignedTransaction{
+		UnsignedTransaction: *NewUnsignedTransaction(p, ms.Address(p), receiver, value, nonce, lockTime, nil),
+		Multisig:            ms,
+		Signatures:          make([]string, len(ms.PublicKeys())),
+	}
+}
+
+// 解析部分签名交易,校验网络、签名哈希和多重签名地址
+func ParsePartiallySignedTransaction(data []byte, p *params.Params) (*PartiallySignedTransaction, error) {
+	if _, err := ParseUnsignedTransaction(data, p); err != nil {
+		return nil, err
+	}
+	var pst PartiallySignedTransaction
+	if err := json.Unmarshal(data, &pst); err != nil {
+		return nil, fmt.Errorf("invalid partially signed transaction: %v", err)
+	}
+	if pst.Multisig == nil {
+		return nil, errors.New("missing multisig")
+	}
+	if pst.Multisig.Address(p) != pst.SenderBlockChainAddress {
+		return nil, fmt.Errorf("multisig does not match sender %s", pst.SenderBlockChainAddress)
+	}
+	if pst.Signatures == nil {
+		pst.Signatures = make([]string, len(pst.Multisig.PublicKeys()))
+	}
+	if len(pst.Signatures) != len(pst.Multisig.PublicKeys()) {
+		return nil, fmt.Errorf("expected %d signature slots, got %d", len(pst.Multisig.PublicKeys()), len(pst.Signatures))
+	}
+	return &pst, nil
+}
+
+// 用钱包的私钥签名,钱包的公钥必须在多重签名中
+func (pst *PartiallySignedTransaction) Sign(w *Wallet) error {
+	i := pst.Multisig.IndexOf(w.PublicKey())
+	if i < 0 {
+		return fmt.Errorf("wallet %s is not a signer of %s", w.BlockChainAddress(), pst.SenderBlockChainAddress)
+	}
+	pst.Signatures[i] = pst.transaction(w).GenerateSignature().String()
+	return nil
+}
+
+// 合并其他持有人签过的同一笔交易
+func (pst *PartiallySignedTransaction) Combine(other *PartiallySignedTransaction) error {
+	if other.SigningHash != pst.SigningHash || other.SenderBlockChainAddress != pst.SenderBlockChainAddress {
+		return errors.New("cannot combine different transactions")
+	}
+	for i, s := range other.Signatures {
+		if pst.Signatures[i] == "" {
+			pst.Signatures[i] = s
+		}
+	}
+	return nil
+}
+
+// 已收集的签名数
+func (pst *PartiallySignedTransaction) SignatureCount() int {
+	count := 0
+	for _, s := range pst.Signatures {
+		if s != "" {
+			count += 1
+		}
+	}
+	return count
+}
+
+func (pst *PartiallySignedTransaction) Complete() bool {
+	return pst.SignatureCount() >= pst.Multisig.Threshold()
+}
+
+// 签名足够时转换为可以广播的交易
+func (pst *PartiallySignedTransaction) Finalize() (*block.TransactionRequest, error) {
+	if !pst.Complete() {
+		return nil, fmt.Errorf("transaction has %d of %d required signatures", pst.SignatureCount(), pst.Multisig.Threshold())
+	}
+	sender := pst.SenderBlockChainAddress
+	receiver := pst.ReceiverBlockChainAddress
+	value := pst.Value
+	return &block.TransactionRequest{
+		SenderBlockChainAddress:   &sender,
+		ReceiverBlockChainAddress: &receiver,
+		Value:                     &value,
+		Nonce:                     pst.Nonce,
+		LockTime:                  pst.LockTime,
+		Data:                      pst.Data,
+		Multisig:                  pst.Multisig,
+		Signatures:                pst.Signatures,
+	}, nil
+}
+
+// 多重签名相关请求
+type MultisigRequest struct {
+	Threshold                 *int     `json:"threshold"`
+	PublicKeys                []string `json:"public_keys"`
+	ReceiverBlockChainAddress *string  `json:"receiver_block_chain_address"` //构建交易时使用
+	Value                     *string  `json:"value"`                        //构建交易时使用
+	LockTime                  int64    `json:"lock_time"`                    //构建交易时使用,可选
+}
+
+func (mr *MultisigRequest) Multisig() (*address.Multisig, error) {
+	if mr.Threshold == nil {
+		return nil, errors.New("missing threshold")
+	}
+	return address.ParseMultisig(*mr.Threshold, mr.PublicKeys)
+}
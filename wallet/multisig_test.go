@@ -0,0 +1,145 @@
+package wallet
+
+import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/params"
+	"GoProject/script"
+	"GoProject/utils"
+	"crypto/ecdsa"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// 2-of-3多重签名: 两个持有人分别签名后合并
+func TestPartiallySignedTransaction(t *testing.T) {
+	p := params.RegTest
+	wallets := []*Wallet{NewWallet(p), NewWallet(p), NewWallet(p)}
+	ms, err := address.NewMultisig(2, []*ecdsa.PublicKey{wallets[0].PublicKey(), wallets[1].PublicKey(), wallets[2].PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := NewWallet(p).BlockChainAddress()
+	pst := NewPartiallySignedTransaction(p, ms, receiver, 0.5, 0, 0)
+	data, _ := json.Marshal(pst)
+
+	a, err := ParsePartiallySignedTransaction(data, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ParsePartiallySignedTransaction(data, p)
+	if err := a.Sign(NewWallet(p)); err == nil {
+		t.Fatal("non-signer signed")
+	}
+	if err := a.Sign(wallets[0]); err != nil {
+		t.Fatal(err)
+	}
+	if a.Complete() {
+		t.Fatal("complete with one signature")
+	}
+	if _, err := a.Finalize(); err == nil {
+		t.Fatal("finalized with one signature")
+	}
+	b.Sign(wallets[2])
+	if err := a.Combine(b); err != nil {
+		t.Fatal(err)
+	}
+	if a.SignatureCount() != 2 || !a.Complete() {
+		t.Fatalf("%d signatures after combine", a.SignatureCount())
+	}
+	other := NewPartiallySignedTransaction(p, ms, receiver, 0.6, 0, 0)
+	if err := a.Combine(other); err == nil {
+		t.Fatal("combined a different transaction")
+	}
+	tr, err := a.Finalize()
+	if err != nil || !tr.Validate() {
+		t.Fatalf("finalize %v", err)
+	}
+
+	bc := block.NewBlockChain(wallets[0].BlockChainAddress(), 0, p)
+	bc.Generate(1, ms.Address(p))
+	signatures := utils.SignaturesFromStrings(tr.Signatures)
+	if err := bc.AcceptMultisigTransaction(ms.Address(p), receiver, 0.5, 0, 0, nil, ms, signatures); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 签名必须放在对应公钥的位置,数量不少于阈值
+func TestMultisigSignatureSlots(t *testing.T) {
+	p := params.RegTest
+	wallets := []*Wallet{NewWallet(p), NewWallet(p), NewWallet(p)}
+	ms, _ := address.NewMultisig(2, []*ecdsa.PublicKey{wallets[0].PublicKey(), wallets[1].PublicKey(), wallets[2].PublicKey()})
+	receiver := NewWallet(p).BlockChainAddress()
+	sender := ms.Address(p)
+	sign := func(w *Wallet) *utils.Signature {
+		return NewTransaction(w.PrivateKey(), w.PublicKey(), sender, receiver, 1, 0, p.ChainId).GenerateSignature()
+	}
+	slot := func(w *Wallet) int { return ms.IndexOf(w.PublicKey()) }
+	other, _ := address.NewMultisig(2, []*ecdsa.PublicKey{wallets[0].PublicKey(), wallets[1].PublicKey()})
+
+	tests := []struct {
+		name       string
+		ms         *address.Multisig
+		signatures func() []*utils.Signature
+		want       string
+	}{
+		{"below threshold", ms, func() []*utils.Signature {
+			s := make([]*utils.Signature, 3)
+			s[slot(wallets[0])] = sign(wallets[0])
+			return s
+		}, "1 of 2 required"},
+		{"wrong slot", ms, func() []*utils.Signature {
+			s := make([]*utils.Signature, 3)
+			s[slot(wallets[0])] = sign(wallets[1])
+			s[slot(wallets[1])] = sign(wallets[0])
+			return s
+		}, "invalid signature"},
+		{"too few slots", ms, func() []*utils.Signature { return []*utils.Signature{sign(wallets[0]), sign(wallets[1])} }, "signature slots"},
+		{"other multisig", other, func() []*utils.Signature { return make([]*utils.Signature, 2) }, "does not match"},
+		{"missing multisig", nil, func() []*utils.Signature { return nil }, "missing multisig"},
+	}
+	for _, tt := range tests {
+		bc := block.NewBlockChain(wallets[0].BlockChainAddress(), 0, p)
+		bc.Generate(1, sender)
+		err := bc.AcceptMultisigTransaction(sender, receiver, 1, 0, 0, nil, tt.ms, tt.signatures())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	//签名顺序按排序后的公钥,任意两个持有人都可以
+	bc := block.NewBlockChain(wallets[0].BlockChainAddress(), 0, p)
+	bc.Generate(1, sender)
+	s := make([]*utils.Signature, 3)
+	s[slot(wallets[1])] = sign(wallets[1])
+	s[slot(wallets[2])] = sign(wallets[2])
+	if err := bc.AcceptMultisigTransaction(sender, receiver, 1, 0, 0, nil, ms, s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 公钥集合的编码是只有压入操作的脚本,不能作为锁定脚本花费多重签名地址的资金
+func TestMultisigBytesAsLockScript(t *testing.T) {
+	p := params.RegTest
+	wallets := []*Wallet{NewWallet(p), NewWallet(p)}
+	ms, _ := address.NewMultisig(2, []*ecdsa.PublicKey{wallets[0].PublicKey(), wallets[1].PublicKey()})
+	bc := block.NewBlockChain(wallets[0].BlockChainAddress(), 0, p)
+	lock := script.Script(ms.Bytes())
+	bc.Generate(1, ms.Address(p))
+	bc.Generate(1, address.FromScript(lock, p))
+	receiver := NewWallet(p).BlockChainAddress()
+	if err := bc.AcceptScriptTransaction(ms.Address(p), receiver, 0.5, 0, 0, nil, lock, nil); err == nil {
+		t.Error("multisig funds spent with the key set as lock script")
+	}
+	if err := bc.AcceptScriptTransaction(address.FromScript(lock, p), receiver, 0.5, 0, 0, nil, lock, nil); err == nil {
+		t.Error("funds spent with the key set as lock script")
+	}
+	//只有压入操作的锁定脚本不需要任何解锁数据
+	push := script.Script{byte(script.OP_1)}
+	bc.Generate(1, address.FromScript(push, p))
+	err := bc.AcceptScriptTransaction(address.FromScript(push, p), receiver, 0.5, 0, 0, nil, push, nil)
+	if err == nil || !strings.Contains(err.Error(), script.ErrLockPushOnly.Error()) {
+		t.Errorf("push-only lock script: %v", err)
+	}
+}
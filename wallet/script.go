@@ -0,0 +1,111 @@
+package wallet
+
+import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/params"
+	"GoProject/script"
+	"GoProject/utils"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// 从脚本地址转出的交易,签名后由持有人组装解锁脚本
+type ScriptTransaction struct {
+	UnsignedTransaction
+	LockScript string `json:"lock_script"` //十六进制
+}
+
+func NewScriptTransaction(p *params.Params, lock script.Script, receiver string, value float32, nonce uint64,
+	lockTime int64) *ScriptTransaction {
+	return &ScriptTransaction{
+		UnsignedTransaction: *NewUnsignedTransaction(p, address.FromScript(lock, p), receiver, value, nonce, lockTime, nil),
+		LockScript:          lock.Hex(),
+	}
+}
+
+// 解析脚本交易,校验网络、签名哈希和锁定脚本
+func ParseScriptTransaction(data []byte, p *params.Params) (*ScriptTransaction, error) {
+	if _, err := ParseUnsignedTransaction(data, p); err != nil {
+		return nil, err
+	}
+	var st ScriptTransaction
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("invalid script transaction: %v", err)
+	}
+	lock, err := script.FromHex(st.LockScript)
+	if err != nil {
+		return nil, err
+	}
+	if len(lock) == 0 {
+		return nil, errors.New("missing lock script")
+	}
+	if address.FromScript(lock, p) != st.SenderBlockChainAddress {
+		return nil, fmt.Errorf("lock script does not match sender %s", st.SenderBlockChainAddress)
+	}
+	return &st, nil
+}
+
+// 附加解锁脚本,转换为可以广播的交易
+func (st *ScriptTransaction) Finalize(unlock script.Script) *block.TransactionRequest {
+	sender := st.SenderBlockChainAddress
+	receiver := st.ReceiverBlockChainAddress
+	value := st.Value
+	lock := st.LockScript
+	unlockHex := unlock.Hex()
+	return &block.TransactionRequest{
+		SenderBlockChainAddress:   &sender,
+		ReceiverBlockChainAddress: &receiver,
+		Value:                     &value,
+		Nonce:                     st.Nonce,
+		LockTime:                  st.LockTime,
+		Data:                      st.Data,
+		LockScript:                &lock,
+		UnlockScript:              &unlockHex,
+	}
+}
+
+// 用于解锁脚本的签名: 公钥(曲线标识 + 压缩公钥)和64字节的R||S
+type RawSignature struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+// 签名交易内容,不要求钱包地址为发送方
+func (ut *UnsignedTransaction) RawSignature(w *Wallet) (*RawSignature, error) {
+	pub, err := utils.EncodePublicKey(w.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	return &RawSignature{
+		PublicKey: hex.EncodeToString(pub),
+		Signature: ut.transaction(w).GenerateSignature().String(),
+	}, nil
+}
+
+// 脚本相关请求,脚本为汇编格式
+type ScriptRequest struct {
+	Script                    *string `json:"script"`
+	ReceiverBlockChainAddress *string `json:"receiver_block_chain_address"` //构建交易时使用
+	Value                     *string `json:"value"`                        //构建交易时使用
+	LockTime                  int64   `json:"lock_time"`                    //构建交易时使用,可选
+}
+
+func (sr *ScriptRequest) LockScript() (script.Script, error) {
+	if sr.Script == nil {
+		return nil, errors.New("missing script")
+	}
+	s, err := script.Parse(*sr.Script)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, errors.New("empty script")
+	}
+	if len(s) > script.MAX_SCRIPT_SIZE {
+		return nil, script.ErrScriptSize
+	}
+	return s, nil
+}
@@ -0,0 +1,70 @@
+package wallet
+
+import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/params"
+	"GoProject/utils"
+	"errors"
+)
+
+// 签名代币交易,金额为0
+func NewTokenTransaction(p *params.Params, w *Wallet, receiver string, nonce uint64, op *utils.TokenOp) *block.TransactionRequest {
+	t := NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockChainAddress(), receiver, 0, 0, p.ChainId)
+	t.nonce = nonce
+	t.token = op
+	return t.request()
+}
+
+// 代币相关请求,账户使用会话令牌签名
+type TokenRequest struct {
+	Account                   *string `json:"account"`
+	Symbol                    *string `json:"symbol"`
+	Decimals                  *uint8  `json:"decimals"`                     //创建时使用
+	Amount                    *string `json:"amount"`                       //创建时为发行总量,转账时为转账数量,按小数位数书写
+	ReceiverBlockChainAddress *string `json:"receiver_block_chain_address"` //转账时使用,创建时默认为账户地址
+}
+
+func (tr *TokenRequest) Validate() bool {
+	return tr.Account != nil && tr.Symbol != nil && tr.Amount != nil
+}
+
+// 签名创建代币的交易,发行总量归接收地址
+func (tr *TokenRequest) Create(p *params.Params, w *Wallet, nonce uint64) (*block.TransactionRequest, error) {
+	var decimals uint8
+	if tr.Decimals != nil {
+		decimals = *tr.Decimals
+	}
+	if decimals > block.MAX_TOKEN_DECIMALS {
+		return nil, errors.New("too many decimals")
+	}
+	supply, err := block.ParseTokenAmount(*tr.Amount, decimals)
+	if err != nil {
+		return nil, err
+	}
+	receiver := w.BlockChainAddress()
+	if tr.ReceiverBlockChainAddress != nil {
+		receiver = *tr.ReceiverBlockChainAddress
+	}
+	if err := address.Validate(receiver, p); err != nil {
+		return nil, err
+	}
+	op := &utils.TokenOp{Op: block.TOKEN_CREATE, Symbol: *tr.Symbol, Decimals: decimals, Amount: supply}
+	return NewTokenTransaction(p, w, receiver, nonce, op), nil
+}
+
+// 签名代币转账交易,decimals为代币的小数位数
+func (tr *TokenRequest) Transfer(p *params.Params, w *Wallet, nonce uint64, decimals uint8) (*block.TransactionRequest, error) {
+	if tr.ReceiverBlockChainAddress == nil {
+		return nil, errors.New("missing receiver_block_chain_address")
+	}
+	if err := address.Validate(*tr.ReceiverBlockChainAddress, p); err != nil {
+		return nil, err
+	}
+	amount, err := block.ParseTokenAmount(*tr.Amount, decimals)
+	if err != nil {
+		return nil, err
+	}
+	op := &utils.TokenOp{Op: block.TOKEN_TRANSFER, Symbol: *tr.Symbol, Amount: amount}
+	return NewTokenTransaction(p, w, *tr.ReceiverBlockChainAddress, nonce, op), nil
+}
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"GoProject/block"
+	"GoProject/params"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// 未签名交易,由钱包服务生成,在离线机器上签名
+type UnsignedTransaction struct {
+	Network                   string  `json:"network"`
+	ChainId                   uint32  `json:"chain_id"`
+	SenderBlockChainAddress   string  `json:"sender_block_chain_address"`
+	ReceiverBlockChainAddress string  `json:"receiver_block_chain_address"`
+	Value                     float32 `json:"value"`
+	Nonce                     uint64  `json:"nonce"` //发送方账户的nonce,构建时从节点获取
+	LockTime                  int64   `json:"lock_time,omitempty"`
+	Data                      string  `json:"data,omitempty"` //附带的数据(十六进制)
+	SigningHash               string  `json:"signing_hash"`   //签名内容的哈希,签名前核对
+}
+
+// lockTime为0时不锁定,data为附带的数据,可以为空
+func NewUnsignedTransaction(p *params.Params, sender string, receiver string, value float32, nonce uint64, lockTime int64,
+	data []byte) *UnsignedTransaction {
+	ut := &UnsignedTransaction{
+		Network:                   p.Name,
+		ChainId:                   p.ChainId,
+		SenderBlockChainAddress:   sender,
+		ReceiverBlockChainAddress: receiver,
+		Value:                     value,
+		Nonce:                     nonce,
+		LockTime:                  lockTime,
+		Data:                      hex.EncodeToString(data),
+	}
+	ut.SigningHash = ut.hash()
+	return ut
+}
+
+// 对应的钱包交易,w为nil时只能用于计算签名哈希
+func (ut *UnsignedTransaction) transaction(w *Wallet) *Transaction {
+	t := &Transaction{
+		senderBlockChainAddress:   ut.SenderBlockChainAddress,
+		receiverBlockChainAddress: ut.ReceiverBlockChainAddress,
+		value:                     ut.Value,
+		nonce:                     ut.Nonce,
+		lockTime:                  ut.LockTime,
+		chainId:                   ut.ChainId,
+	}
+	t.data, _ = hex.DecodeString(ut.Data)
+	if w != nil {
+		t.senderPrivateKey = w.PrivateKey()
+		t.senderPublicKey = w.PublicKey()
+	}
+	return t
+}
+
+func (ut *UnsignedTransaction) hash() string {
+	h := ut.transaction(nil).SigningPayload().Hash()
+	return hex.EncodeToString(h[:])
+}
+
+// 解析未签名交易,校验网络和签名哈希
+func ParseUnsignedTransaction(data []byte, p *params.Params) (*UnsignedTransaction, error) {
+	var ut UnsignedTransaction
+	if err := json.Unmarshal(data, &ut); err != nil {
+		return nil, fmt.Errorf("invalid unsigned transaction: %v", err)
+	}
+	if ut.ChainId != p.ChainId {
+		return nil, fmt.Errorf("transaction is for chain %d (%s), not %s", ut.ChainId, ut.Network, p.Name)
+	}
+	if _, err := hex.DecodeString(ut.Data); err != nil {
+		return nil, fmt.Errorf("invalid data: %v", err)
+	}
+	if ut.SigningHash != ut.hash() {
+		return nil, fmt.Errorf("signing hash does not match transaction fields")
+	}
+	return &ut, nil
+}
+
+// 使用发送方的钱包签名,返回可以广播的交易
+func (ut *UnsignedTransaction) Sign(w *Wallet) (*block.TransactionRequest, error) {
+	if w.BlockChainAddress() != ut.SenderBlockChainAddress {
+		return nil, fmt.Errorf("wallet %s cannot sign for sender %s", w.BlockChainAddress(), ut.SenderBlockChainAddress)
+	}
+	return ut.transaction(w).request(), nil
+}
+
+// 构建未签名交易的请求
+type BuildTransactionRequest struct {
+	SenderBlockChainAddress   *string `json:"sender_block_chain_address"`
+	ReceiverBlockChainAddress *string `json:"receiver_block_chain_address"`
+	Value                     *string `json:"value"`
+	LockTime                  int64   `json:"lock_time"` //可选,区块高度或unix时间(秒)
+	Memo                      *string `json:"memo"`      //可选,附带的备注,按字节收取手续费
+}
+
+func (br *BuildTransactionRequest) Validate() bool {
+	if br.SenderBlockChainAddress == nil ||
+		br.ReceiverBlockChainAddress == nil ||
+		br.Value == nil {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package wallet
+
+import (
+	"GoProject/block"
+	"GoProject/params"
+	"GoProject/utils"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOfflineSigning(t *testing.T) {
+	w, receiver := NewWallet(params.RegTest), NewWallet(params.RegTest)
+	ut := NewUnsignedTransaction(params.RegTest, w.BlockChainAddress(), receiver.BlockChainAddress(), 0.5, 0, 0, []byte("memo"))
+	data, err := json.Marshal(ut)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//离线机器上解析并签名
+	parsed, err := ParseUnsignedTransaction(data, params.RegTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parsed.Sign(NewWallet(params.RegTest)); err == nil {
+		t.Fatal("signed with another wallet")
+	}
+	tr, err := parsed.Sign(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tr.Validate() {
+		t.Fatal("signed request is incomplete")
+	}
+
+	if tr.Data != hex.EncodeToString([]byte("memo")) {
+		t.Fatalf("data %q", tr.Data)
+	}
+
+	//节点按相同的签名内容验证,数据参与签名
+	bc := block.NewBlockChain(w.BlockChainAddress(), 0, params.RegTest)
+	bc.Generate(1, w.BlockChainAddress())
+	publicKey, err := utils.DecodePublicKey(*tr.SenderPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := utils.SignatureFromString(*tr.Signature)
+	if err := bc.AcceptTransaction(*tr.SenderBlockChainAddress, *tr.ReceiverBlockChainAddress, *tr.Value, tr.Nonce, tr.LockTime,
+		[]byte("other"), publicKey, signature); err == nil {
+		t.Fatal("data changed after signing")
+	}
+	if err := bc.AcceptTransaction(*tr.SenderBlockChainAddress, *tr.ReceiverBlockChainAddress, *tr.Value, tr.Nonce, tr.LockTime,
+		[]byte("memo"), publicKey, signature); err != nil {
+		t.Fatalf("offline signature rejected by node: %v", err)
+	}
+}
+
+func TestParseUnsignedTransaction(t *testing.T) {
+	ut := NewUnsignedTransaction(params.RegTest, "sender", "receiver", 1.5, 3, 0, []byte("memo"))
+	tests := []struct {
+		name   string
+		modify func(ut UnsignedTransaction) UnsignedTransaction
+		p      *params.Params
+		want   string
+	}{
+		{"other network", func(ut UnsignedTransaction) UnsignedTransaction { return ut }, params.MainNet, "not mainnet"},
+		{"value changed", func(ut UnsignedTransaction) UnsignedTransaction { ut.Value = 15; return ut }, params.RegTest, "signing hash"},
+		{"receiver changed", func(ut UnsignedTransaction) UnsignedTransaction { ut.ReceiverBlockChainAddress = "x"; return ut }, params.RegTest, "signing hash"},
+		{"nonce changed", func(ut UnsignedTransaction) UnsignedTransaction { ut.Nonce = 4; return ut }, params.RegTest, "signing hash"},
+		{"data changed", func(ut UnsignedTransaction) UnsignedTransaction { ut.Data = "00"; return ut }, params.RegTest, "signing hash"},
+		{"invalid data", func(ut UnsignedTransaction) UnsignedTransaction { ut.Data = "zz"; return ut }, params.RegTest, "invalid data"},
+		{"chain id changed", func(ut UnsignedTransaction) UnsignedTransaction { ut.ChainId = params.MainNet.ChainId; return ut }, params.MainNet, "signing hash"},
+	}
+	for _, tt := range tests {
+		data, _ := json.Marshal(tt.modify(*ut))
+		_, err := ParseUnsignedTransaction(data, tt.p)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err %v, want %q", tt.name, err, tt.want)
+		}
+	}
+	if _, err := ParseUnsignedTransaction([]byte("{"), params.RegTest); err == nil {
+		t.Error("invalid json accepted")
+	}
+}
@@ -1,15 +1,16 @@
 package wallet
 
 import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/params"
 	"GoProject/utils"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/btcsuite/btcutil/base58"
-	"golang.org/x/crypto/ripemd160"
 )
 
 // 钱包结构体
@@ -19,53 +20,33 @@ type Wallet struct {
 	blockChainAddress string            //节点/地址
 }
 
-// 创建钱包
-func NewWallet() *Wallet {
+// 创建P-256钱包,地址使用网络参数中的版本字节
+func NewWallet(p *params.Params) *Wallet {
+	return NewWalletWithCurve(elliptic.P256(), p)
+}
+
+// 使用指定曲线(P-256或secp256k1)创建钱包
+func NewWalletWithCurve(curve elliptic.Curve, p *params.Params) *Wallet {
 	//1. 创建ECDSA私钥（32字节）公钥（64字节）
+	privateKey, _ := ecdsa.GenerateKey(curve, rand.Reader)
+	return NewWalletFromPrivateKey(privateKey, p)
+}
+
+// 使用已有的私钥(例如HD钱包派生的私钥)创建钱包
+func NewWalletFromPrivateKey(privateKey *ecdsa.PrivateKey, p *params.Params) *Wallet {
 	w := new(Wallet)
-	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	w.privateKey = privateKey
 	w.publicKey = &w.privateKey.PublicKey
-	//2. 对32字节的公钥执行SHA-256哈希运算
-	h2 := sha256.New()
-	h2.Write(w.publicKey.X.Bytes())
-	h2.Write(w.publicKey.Y.Bytes())
-	digest2 := h2.Sum(nil) //为对象创建哈希值
-	//3.对SHA-256（20字节）的结果执行RIPEMD-160哈希运算
-	h3 := ripemd160.New()
-	h3.Write(digest2)
-	digest3 := h3.Sum(nil)
-	//4. 在 RIPEMD-160哈希前添加一个版本字节（例如，0x00 表示主网）。
-	vd4 := make([]byte, 21)
-	vd4[0] = 0x00
-	copy(vd4[1:], digest3[:])
-	//5. 对扩展的RIPEMD-160结果进行两次SHA-256哈希处理，以生成一个校验和。
-	h5 := sha256.New()
-	h5.Write(vd4)
-	digest5 := h5.Sum(nil)
-	//6. 对上一个SHA-256哈希的结果执行SHA-256
-	h6 := sha256.New()
-	h6.Write(digest5)
-	digest6 := h6.Sum(nil)
-	//7. 如果第二个SHA-256哈希用于校验和，则取前4个字节
-	chsum := digest6[:4]
-	//8. 将7中的4个校验和字节添加到4中的扩展RIPEMD-160哈希的末尾（25个字节）
-	dc8 := make([]byte, 25)
-	copy(dc8[:21], vd4[:])   //复制 vd4 到 dc8 的前 21 个字节
-	copy(dc8[21:], chsum[:]) //复制校验和到 dc8 的后 4 个字节
-	//9. 将字节字符串的结果转换为base58编码
-	address := base58.Encode(dc8)
-	w.blockChainAddress = address
+	w.blockChainAddress = address.FromPublicKey(w.publicKey, p.AddressVersion).String()
 	return w
 }
 
+// 只包含公钥和地址,私钥只能以加密的密钥文件导出(KeyStore.Export)
 func (w *Wallet) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		PrivateKey        string `json:"private_key"`
 		PublicKey         string `json:"public_key"`
 		BlockChainAddress string `json:"block_chain_address"`
 	}{
-		PrivateKey:        w.PrivateKeyStr(),
 		PublicKey:         w.PublicKeyStr(),
 		BlockChainAddress: w.blockChainAddress,
 	})
@@ -88,8 +69,9 @@ func (w *Wallet) PublicKey() *ecdsa.PublicKey {
 	return w.publicKey
 }
 
+// P-256为128位hex,secp256k1为带曲线标识的压缩公钥
 func (w *Wallet) PublicKeyStr() string {
-	return fmt.Sprintf("%064x%064x", w.publicKey.X.Bytes(), w.publicKey.Y.Bytes())
+	return utils.PublicKeyToString(w.publicKey)
 }
 
 func (w *Wallet) BlockChainAddress() string {
@@ -102,23 +84,96 @@ type Transaction struct {
 	senderBlockChainAddress   string
 	receiverBlockChainAddress string
 	value                     float32
+	nonce                     uint64 //发送方账户的nonce,节点只接受等于账户当前nonce的交易,防止重放
+	lockTime                  int64  //锁定时间,0表示不锁定
+	chainId                   uint32 //目标网络,防止交易在其他网络上重放
+	code                      []byte //合约交易的字节码(部署)和参数
+	input                     []byte
+	gasLimit                  uint64         //大于0表示合约交易
+	token                     *utils.TokenOp //代币交易的操作
+	data                      []byte         //附带的数据
 }
 
 func NewTransaction(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey,
-	senderAddr string, receiverAddr string, value float32) *Transaction {
-	return &Transaction{privateKey, publicKey, senderAddr, receiverAddr, value}
+	senderAddr string, receiverAddr string, value float32, lockTime int64, chainId uint32) *Transaction {
+	return &Transaction{
+		senderPrivateKey:          privateKey,
+		senderPublicKey:           publicKey,
+		senderBlockChainAddress:   senderAddr,
+		receiverBlockChainAddress: receiverAddr,
+		value:                     value,
+		lockTime:                  lockTime,
+		chainId:                   chainId,
+	}
+}
+
+// 与节点验证签名时使用的内容一致
+func (t *Transaction) SigningPayload() *utils.SigningPayload {
+	return &utils.SigningPayload{
+		ChainId:   t.chainId,
+		Sender:    t.senderBlockChainAddress,
+		Recipient: t.receiverBlockChainAddress,
+		Value:     t.value,
+		Nonce:     t.nonce,
+		LockTime:  t.lockTime,
+		Code:      hex.EncodeToString(t.code),
+		Input:     hex.EncodeToString(t.input),
+		GasLimit:  t.gasLimit,
+		Token:     t.token,
+		Data:      hex.EncodeToString(t.data),
+	}
+}
+
+// 设置附带的数据,必须在签名前设置
+func (t *Transaction) SetData(data []byte) {
+	t.data = data
 }
 
 func (t *Transaction) GenerateSignature() *utils.Signature {
-	//序列化
-	m, _ := json.Marshal(t)
-	//使用SHA-256对序列化后的交易数据进行哈希运算，得到交易的哈希值。
-	h := sha256.Sum256([]byte(m))
+	//使用SHA-256对签名内容(包含网络的chain id)进行哈希运算，得到交易的哈希值。
+	h := t.SigningPayload().Hash()
 	//使用椭圆曲线数字签名算法（ECDSA）和发送者的私钥 t.senderPrivateKey 对交易哈希值 h 进行签名。签名过程生成两个值 r 和 s。
 	r, s, _ := ecdsa.Sign(rand.Reader, t.senderPrivateKey, h[:])
 	return &utils.Signature{r, s}
 }
 
+// 用钱包签名普通转账交易,nonce从节点的/nonce获取,lockTime为0时不锁定,data为附带的数据,可以为空
+func NewTransferTransaction(p *params.Params, w *Wallet, receiver string, value float32, nonce uint64, lockTime int64,
+	data []byte) *block.TransactionRequest {
+	t := NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockChainAddress(), receiver, value, lockTime, p.ChainId)
+	t.nonce = nonce
+	t.data = data
+	return t.request()
+}
+
+// 签名只携带数据的交易,金额为0,发给钱包自己,只需支付数据的手续费
+func NewDataTransaction(p *params.Params, w *Wallet, nonce uint64, data []byte) *block.TransactionRequest {
+	return NewTransferTransaction(p, w, w.BlockChainAddress(), 0, nonce, 0, data)
+}
+
+// 签名并生成发送给区块链节点的交易请求
+func (t *Transaction) request() *block.TransactionRequest {
+	sender := t.senderBlockChainAddress
+	receiver := t.receiverBlockChainAddress
+	publicKey := utils.PublicKeyToString(t.senderPublicKey)
+	value := t.value
+	signature := t.GenerateSignature().String()
+	return &block.TransactionRequest{
+		SenderBlockChainAddress:   &sender,
+		ReceiverBlockChainAddress: &receiver,
+		SenderPublicKey:           &publicKey,
+		Value:                     &value,
+		Nonce:                     t.nonce,
+		LockTime:                  t.lockTime,
+		Signature:                 &signature,
+		Code:                      hex.EncodeToString(t.code),
+		Input:                     hex.EncodeToString(t.input),
+		GasLimit:                  t.gasLimit,
+		Token:                     t.token,
+		Data:                      hex.EncodeToString(t.data),
+	}
+}
+
 func (t *Transaction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		SenderAddr   string
@@ -131,21 +186,53 @@ func (t *Transaction) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// 转账请求,使用已解锁账户的私钥在服务器端签名
 type TransactionRequest struct {
-	SenderPrivateKey          *string `json:"sender_private_key"`
-	SenderBlockChainAddress   *string `json:"sender_block_chain_address"`
+	Account                   *string `json:"account"`
 	ReceiverBlockChainAddress *string `json:"receiver_block_chain_address"`
-	SenderPublicKey           *string `json:"sender_public_key"`
 	Value                     *string `json:"value"`
+	LockTime                  int64   `json:"lock_time"` //可选,区块高度或unix时间(秒)
+	Memo                      *string `json:"memo"`      //可选,附带的备注,按字节收取手续费
 }
 
 func (tr *TransactionRequest) Validate() bool {
-	if tr.SenderPrivateKey == nil ||
-		tr.SenderBlockChainAddress == nil ||
+	if tr.Account == nil ||
 		tr.ReceiverBlockChainAddress == nil ||
-		tr.SenderPublicKey == nil ||
 		tr.Value == nil {
 		return false
 	}
 	return true
 }
+
+// HD钱包相关请求,除密钥库密码外都是可选字段
+// 助记词只在恢复时提供一次,之后使用密钥库中加密保存的种子
+type HDWalletRequest struct {
+	Mnemonic           *string `json:"mnemonic"`
+	Passphrase         *string `json:"passphrase"` //BIP39密码
+	Path               *string `json:"path"`
+	GapLimit           *int    `json:"gap_limit"`
+	Index              *uint32 `json:"index"`
+	Curve              *string `json:"curve"`
+	KeystorePassphrase *string `json:"keystore_passphrase"` //加密保存派生私钥的密码
+}
+
+func (hr *HDWalletRequest) PassphraseOrEmpty() string {
+	if hr.Passphrase == nil {
+		return ""
+	}
+	return *hr.Passphrase
+}
+
+func (hr *HDWalletRequest) PathOrEmpty() string {
+	if hr.Path == nil {
+		return ""
+	}
+	return *hr.Path
+}
+
+func (hr *HDWalletRequest) CurveOrEmpty() string {
+	if hr.Curve == nil {
+		return ""
+	}
+	return *hr.Curve
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"GoProject/block"
+	"GoProject/utils"
+	"GoProject/wallet"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+)
+
+// 部署合约,code为汇编,账户为部署者并支付gas
+func (ws *WalletServer) DeployContract(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var cr wallet.ContractRequest
+		if err := json.NewDecoder(req.Body).Decode(&cr); err != nil || cr.Account == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		signer, err := ws.accounts.Signer(*cr.Account, req.Header.Get(SESSION_HEADER))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		nonce, err := ws.fetchNonce(signer.BlockChainAddress())
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		bt, contract, err := cr.Deploy(ws.params, signer, nonce)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		if status, err := ws.submit(bt); err != nil {
+			w.WriteHeader(status)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Address     string                    `json:"contract_address"`
+			Transaction *block.TransactionRequest `json:"transaction"`
+		}{
+			Address:     contract,
+			Transaction: bt,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 调用合约,执行结果在交易打包进区块后写入合约存储
+func (ws *WalletServer) CallContract(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var cr wallet.ContractRequest
+		if err := json.NewDecoder(req.Body).Decode(&cr); err != nil || cr.Account == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		signer, err := ws.accounts.Signer(*cr.Account, req.Header.Get(SESSION_HEADER))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		nonce, err := ws.fetchNonce(signer.BlockChainAddress())
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		bt, err := cr.Call(ws.params, signer, nonce)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		ws.broadcast(w, bt)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"GoProject/address"
+	"GoProject/block"
+	"GoProject/script"
+	"GoProject/utils"
+	"GoProject/wallet"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// 创建哈希时间锁合约,账户为退款方,给出value时从账户存入合约
+func (ws *WalletServer) HTLC(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var hr wallet.HTLCRequest
+		if err := json.NewDecoder(req.Body).Decode(&hr); err != nil || hr.Account == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		signer, err := ws.accounts.Signer(*hr.Account, req.Header.Get(SESSION_HEADER))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		h, preimage, err := hr.HTLC(signer.PublicKey())
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		lock, _ := h.Script()
+		htlcAddress := address.FromScript(lock, ws.params)
+		var funding *block.TransactionRequest
+		if hr.Value != nil {
+			value, err := strconv.ParseFloat(*hr.Value, 32)
+			if err != nil || value <= 0 {
+				log.Printf("ERROR: invalid value %q", *hr.Value)
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("invalid value")))
+				return
+			}
+			nonce, err := ws.fetchNonce(signer.BlockChainAddress())
+			if err != nil {
+				log.Printf("ERROR: %v", err)
+				w.WriteHeader(http.StatusBadGateway)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			funding = wallet.NewTransferTransaction(ws.params, signer, htlcAddress, float32(value), nonce, 0, nil)
+			if status, err := ws.submit(funding); err != nil {
+				w.WriteHeader(status)
+				io.WriteString(w, string(utils.JsonStatus(err.Error())))
+				return
+			}
+		}
+		m, _ := json.Marshal(struct {
+			Address     string                    `json:"block_chain_address"`
+			LockScript  string                    `json:"lock_script"`
+			Asm         string                    `json:"asm"`
+			Hash        string                    `json:"hash"`
+			Preimage    string                    `json:"preimage,omitempty"` //只有创建者知道,赎回前不要公开
+			Timeout     int64                     `json:"timeout"`
+			Transaction *block.TransactionRequest `json:"transaction,omitempty"`
+		}{
+			Address:     htlcAddress,
+			LockScript:  lock.Hex(),
+			Asm:         lock.String(),
+			Hash:        hex.EncodeToString(h.Hash[:]),
+			Preimage:    hex.EncodeToString(preimage),
+			Timeout:     h.Timeout,
+			Transaction: funding,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 接收方用原像赎回合约的全部余额
+func (ws *WalletServer) RedeemHTLC(w http.ResponseWriter, req *http.Request) {
+	ws.spendHTLC(w, req, true)
+}
+
+// 超时后退款方取回合约的全部余额
+func (ws *WalletServer) RefundHTLC(w http.ResponseWriter, req *http.Request) {
+	ws.spendHTLC(w, req, false)
+}
+
+func (ws *WalletServer) spendHTLC(w http.ResponseWriter, req *http.Request, redeem bool) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var hr wallet.HTLCRequest
+		if err := json.NewDecoder(req.Body).Decode(&hr); err != nil ||
+			hr.Account == nil || hr.LockScript == nil || (redeem && hr.Preimage == nil) {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		signer, err := ws.accounts.Signer(*hr.Account, req.Header.Get(SESSION_HEADER))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		receiver := signer.BlockChainAddress()
+		if hr.ReceiverBlockChainAddress != nil {
+			receiver = *hr.ReceiverBlockChainAddress
+		}
+		lock, err := script.FromHex(*hr.LockScript)
+		if err == nil {
+			_, err = script.ParseHTLC(lock)
+		}
+		if err == nil {
+			err = address.Validate(receiver, ws.params)
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		amount, err := ws.fetchAmount(address.FromScript(lock, ws.params))
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if amount <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("htlc has no balance")))
+			return
+		}
+		nonce, err := ws.fetchNonce(address.FromScript(lock, ws.params))
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		var bt *block.TransactionRequest
+		if redeem {
+			preimage, _ := hex.DecodeString(*hr.Preimage)
+			bt, err = wallet.RedeemHTLC(ws.params, lock, signer, preimage, receiver, amount, nonce)
+		} else {
+			bt, err = wallet.RefundHTLC(ws.params, lock, signer, receiver, amount, nonce)
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		ws.broadcast(w, bt)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
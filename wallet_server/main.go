@@ -1,8 +1,13 @@
 package main
 
 import (
+	"GoProject/params"
+	"GoProject/utils"
+	"GoProject/wallet"
 	"flag"
+	"fmt"
 	"log"
+	"path/filepath"
 )
 
 func init() {
@@ -10,9 +15,35 @@ func init() {
 }
 
 func main() {
-	port := flag.Uint("port", 8080, "TCP Port Number for Wallet Server")
-	gateway := flag.String("gateway", "127.0.0.1:5000", "Blockchin Gateway")
+	network := flag.String("network", "mainnet", "Network: mainnet, testnet or regtest")
+	port := flag.Uint("port", 0, "TCP Port Number for Wallet Server (default from network)")
+	gateway := flag.String("gateway", "", "Blockchin Gateway (default 127.0.0.1 on the network port)")
+	curveName := flag.String("curve", "p256", "Default curve for new wallets: p256 or secp256k1")
+	keystoreDir := flag.String("keystore", "keystore/wallet", "Directory of encrypted user keys and accounts")
 	flag.Parse()
-	app := NewWalletServer(uint16(*port), *gateway)
+	curve, err := utils.CurveByName(*curveName)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	p, err := params.ByName(*network)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if *port == 0 {
+		*port = uint(p.DefaultWalletPort)
+	}
+	if *gateway == "" {
+		*gateway = fmt.Sprintf("127.0.0.1:%d", p.DefaultPort)
+	}
+	//不同网络的地址版本不同,分目录保存
+	ks, err := wallet.NewKeyStore(filepath.Join(*keystoreDir, p.Name), p)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	accounts, err := wallet.NewAccountManager(ks)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	app := NewWalletServer(uint16(*port), *gateway, p, curve, ks, accounts)
 	app.Run()
 }
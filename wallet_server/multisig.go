@@ -0,0 +1,216 @@
+package main
+
+import (
+	"GoProject/address"
+	"GoProject/utils"
+	"GoProject/wallet"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// 由公钥和门限生成多重签名地址
+func (ws *WalletServer) Multisig(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var mr wallet.MultisigRequest
+		if err := json.NewDecoder(req.Body).Decode(&mr); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		ms, err := mr.Multisig()
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Address  string            `json:"block_chain_address"`
+			Multisig *address.Multisig `json:"multisig"`
+		}{
+			Address:  ms.Address(ws.params),
+			Multisig: ms,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 构建从多重签名地址转出的部分签名交易
+func (ws *WalletServer) BuildMultisigTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var mr wallet.MultisigRequest
+		if err := json.NewDecoder(req.Body).Decode(&mr); err != nil || mr.ReceiverBlockChainAddress == nil || mr.Value == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		ms, err := mr.Multisig()
+		if err == nil {
+			err = address.Validate(*mr.ReceiverBlockChainAddress, ws.params)
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		value, err := strconv.ParseFloat(*mr.Value, 32)
+		if err != nil || value <= 0 {
+			log.Printf("ERROR: invalid value %q", *mr.Value)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid value")))
+			return
+		}
+		if mr.LockTime < 0 {
+			log.Printf("ERROR: invalid lock time %d", mr.LockTime)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid lock time")))
+			return
+		}
+		amount, err := ws.fetchAmount(ms.Address(ws.params))
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if amount < float32(value) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("not enough balance")))
+			return
+		}
+		nonce, err := ws.fetchNonce(ms.Address(ws.params))
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		pst := wallet.NewPartiallySignedTransaction(ws.params, ms, *mr.ReceiverBlockChainAddress, float32(value), nonce, mr.LockTime)
+		m, _ := json.Marshal(pst)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 使用已解锁账户的私钥为部分签名交易添加签名
+func (ws *WalletServer) SignMultisigTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var sr struct {
+			Account     *string         `json:"account"`
+			Transaction json.RawMessage `json:"transaction"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&sr); err != nil || sr.Account == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		signer, err := ws.accounts.Signer(*sr.Account, req.Header.Get(SESSION_HEADER))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		pst, err := wallet.ParsePartiallySignedTransaction(sr.Transaction, ws.params)
+		if err == nil {
+			err = pst.Sign(signer)
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(pst)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 合并多个持有人分别签名的同一笔交易
+func (ws *WalletServer) CombineMultisigTransactions(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var cr struct {
+			Transactions []json.RawMessage `json:"transactions"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&cr); err != nil || len(cr.Transactions) == 0 {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		var combined *wallet.PartiallySignedTransaction
+		for _, data := range cr.Transactions {
+			pst, err := wallet.ParsePartiallySignedTransaction(data, ws.params)
+			if err == nil && combined != nil {
+				err = combined.Combine(pst)
+			}
+			if err != nil {
+				log.Printf("ERROR: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus(err.Error())))
+				return
+			}
+			if combined == nil {
+				combined = pst
+			}
+		}
+		m, _ := json.Marshal(combined)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 签名足够后广播多重签名交易
+func (ws *WalletServer) BroadcastMultisigTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		pst, err := wallet.ParsePartiallySignedTransaction(data, ws.params)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		bt, err := pst.Finalize()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		ws.broadcast(w, bt)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"GoProject/address"
+	"GoProject/script"
+	"GoProject/utils"
+	"GoProject/wallet"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// 汇编锁定脚本,返回脚本地址
+func (ws *WalletServer) Script(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var sr wallet.ScriptRequest
+		if err := json.NewDecoder(req.Body).Decode(&sr); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		lock, err := sr.LockScript()
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Address    string `json:"block_chain_address"`
+			LockScript string `json:"lock_script"`
+			Asm        string `json:"asm"`
+		}{
+			Address:    address.FromScript(lock, ws.params),
+			LockScript: lock.Hex(),
+			Asm:        lock.String(),
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 构建从脚本地址转出的交易,由离线签名工具的sign-raw签名
+func (ws *WalletServer) BuildScriptTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var sr wallet.ScriptRequest
+		if err := json.NewDecoder(req.Body).Decode(&sr); err != nil || sr.ReceiverBlockChainAddress == nil || sr.Value == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		lock, err := sr.LockScript()
+		if err == nil {
+			err = address.Validate(*sr.ReceiverBlockChainAddress, ws.params)
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		value, err := strconv.ParseFloat(*sr.Value, 32)
+		if err != nil || value <= 0 {
+			log.Printf("ERROR: invalid value %q", *sr.Value)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid value")))
+			return
+		}
+		if sr.LockTime < 0 {
+			log.Printf("ERROR: invalid lock time %d", sr.LockTime)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid lock time")))
+			return
+		}
+		amount, err := ws.fetchAmount(address.FromScript(lock, ws.params))
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if amount < float32(value) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("not enough balance")))
+			return
+		}
+		nonce, err := ws.fetchNonce(address.FromScript(lock, ws.params))
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		st := wallet.NewScriptTransaction(ws.params, lock, *sr.ReceiverBlockChainAddress, float32(value), nonce, sr.LockTime)
+		m, _ := json.Marshal(st)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 附加解锁脚本后广播脚本交易
+func (ws *WalletServer) BroadcastScriptTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var br struct {
+			Transaction  json.RawMessage `json:"transaction"`
+			UnlockScript *string         `json:"unlock_script"` //汇编格式
+		}
+		if err := json.NewDecoder(req.Body).Decode(&br); err != nil || br.UnlockScript == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		st, err := wallet.ParseScriptTransaction(br.Transaction, ws.params)
+		var unlock script.Script
+		if err == nil {
+			unlock, err = script.Parse(*br.UnlockScript)
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		ws.broadcast(w, st.Finalize(unlock))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
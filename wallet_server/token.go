@@ -0,0 +1,140 @@
+package main
+
+import (
+	"GoProject/block"
+	"GoProject/utils"
+	"GoProject/wallet"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// 创建代币,账户为发行方,发行总量默认归账户所有
+func (ws *WalletServer) CreateToken(w http.ResponseWriter, req *http.Request) {
+	ws.tokenTransaction(w, req, func(tr *wallet.TokenRequest, signer *wallet.Wallet, nonce uint64) (*block.TransactionRequest, error) {
+		return tr.Create(ws.params, signer, nonce)
+	})
+}
+
+// 转账代币,数量按代币的小数位数书写
+func (ws *WalletServer) TransferToken(w http.ResponseWriter, req *http.Request) {
+	ws.tokenTransaction(w, req, func(tr *wallet.TokenRequest, signer *wallet.Wallet, nonce uint64) (*block.TransactionRequest, error) {
+		tk, err := ws.fetchToken(*tr.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		return tr.Transfer(ws.params, signer, nonce, tk.Decimals)
+	})
+}
+
+// 解析代币请求,由sign签名后发送给区块链节点
+func (ws *WalletServer) tokenTransaction(w http.ResponseWriter, req *http.Request,
+	sign func(tr *wallet.TokenRequest, signer *wallet.Wallet, nonce uint64) (*block.TransactionRequest, error)) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var tr wallet.TokenRequest
+		if err := json.NewDecoder(req.Body).Decode(&tr); err != nil || !tr.Validate() {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		signer, err := ws.accounts.Signer(*tr.Account, req.Header.Get(SESSION_HEADER))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		nonce, err := ws.fetchNonce(signer.BlockChainAddress())
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		bt, err := sign(&tr, signer, nonce)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		ws.broadcast(w, bt)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 查询地址持有的代币
+func (ws *WalletServer) WalletTokens(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		q := url.Values{"blockchain_address": {req.URL.Query().Get("blockchain_address")}}
+		var r struct {
+			Balances []*block.TokenBalance `json:"balances"`
+		}
+		if err := ws.fetchJSON("/tokens/balance?"+q.Encode(), &r); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Message  string                `json:"message"`
+			Balances []*block.TokenBalance `json:"balances"`
+		}{
+			Message:  "Success",
+			Balances: r.Balances,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 从区块链节点查询代币信息
+func (ws *WalletServer) fetchToken(symbol string) (*block.Token, error) {
+	var tk block.Token
+	if err := ws.fetchJSON("/tokens?"+url.Values{"symbol": {symbol}}.Encode(), &tk); err != nil {
+		return nil, err
+	}
+	return &tk, nil
+}
+
+// 从区块链节点查询地址下一笔交易应使用的nonce
+func (ws *WalletServer) fetchNonce(blockChainAddress string) (uint64, error) {
+	var nr struct {
+		Nonce uint64 `json:"nonce"`
+	}
+	if err := ws.fetchJSON("/nonce?"+url.Values{"blockchain_address": {blockChainAddress}}.Encode(), &nr); err != nil {
+		return 0, err
+	}
+	return nr.Nonce, nil
+}
+
+// GET区块链节点的path并解析应答,失败时返回节点给出的原因
+func (ws *WalletServer) fetchJSON(path string, v interface{}) error {
+	resp, err := http.Get("http://" + ws.Gateway() + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&status) == nil && status.Message != "" {
+			return errors.New(status.Message)
+		}
+		return fmt.Errorf("gateway returned status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -1,29 +1,50 @@
 package main
 
 import (
+	"GoProject/address"
 	"GoProject/block"
+	"GoProject/params"
 	"GoProject/utils"
 	wallet "GoProject/wallet"
 	"bytes"
+	"crypto/elliptic"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"path"
 	"strconv"
 )
 
 const tempDir = "wallet_server/templates"
 
+// 解锁账户后得到的会话令牌
+const SESSION_HEADER = "X-Session-Token"
+
 type WalletServer struct {
 	port    uint16
 	gateway string
+	params  *params.Params //所在网络
+	curve   elliptic.Curve //新建钱包默认使用的曲线
+
+	keystore *wallet.KeyStore       //加密保存用户私钥
+	accounts *wallet.AccountManager //托管账户,私钥不离开服务器
 }
 
-func NewWalletServer(port uint16, gateway string) *WalletServer {
-	return &WalletServer{port, gateway}
+func NewWalletServer(port uint16, gateway string, p *params.Params, curve elliptic.Curve, ks *wallet.KeyStore, accounts *wallet.AccountManager) *WalletServer {
+	return &WalletServer{port, gateway, p, curve, ks, accounts}
+}
+
+// 请求中指定的曲线,为空时使用默认曲线
+func (ws *WalletServer) curveOrDefault(name string) (elliptic.Curve, error) {
+	if name == "" {
+		return ws.curve, nil
+	}
+	return utils.CurveByName(name)
 }
 
 func (ws *WalletServer) GetPort() uint16 {
@@ -48,8 +69,28 @@ func (ws *WalletServer) Wallet(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPost:
 		w.Header().Add("Content-Type", "application/json")
-		myWallet := wallet.NewWallet()
-		m, _ := myWallet.MarshalJSON()
+		curve, err := ws.curveOrDefault(req.URL.Query().Get("curve"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		myWallet := wallet.NewWalletWithCurve(curve, ws.params)
+		//私钥加密保存,只返回公钥和地址
+		var kr wallet.KeyStoreRequest
+		if err := json.NewDecoder(req.Body).Decode(&kr); err != nil || kr.Passphrase == nil {
+			log.Println("ERROR: missing passphrase")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("passphrase required")))
+			return
+		}
+		if err := ws.keystore.Store(myWallet, *kr.Passphrase); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(myWallet.KeyInfo())
 		io.WriteString(w, string(m[:]))
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -57,118 +98,641 @@ func (ws *WalletServer) Wallet(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// 处理事务并向区块链服务器发送事务
+// 使用已解锁账户的私钥签名,并向区块链服务器发送事务
+// 请求只包含账户、接收地址和金额,会话令牌放在请求头中
 func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPost:
+		w.Header().Add("Content-type", "application/json")
 		decoder := json.NewDecoder(req.Body)
 		var t wallet.TransactionRequest
 		err := decoder.Decode(&t)
 		if err != nil {
 			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
 		if !t.Validate() {
 			log.Printf("ERROR: Invalid Transaction")
+			w.WriteHeader(http.StatusBadRequest)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		privateKey := utils.PrivateKeyFromString(*t.SenderPrivateKey, publicKey)
+		if err := address.Validate(*t.ReceiverBlockChainAddress, ws.params); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
 		value, err := strconv.ParseFloat(*t.Value, 32)
 		if err != nil {
 			log.Printf("ERROR: parse error")
+			w.WriteHeader(http.StatusBadRequest)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
 		}
-		value32 := float32(value)
-		w.Header().Add("Content-type", "application/json")
-		io.WriteString(w, string(utils.JsonStatus("success")))
-		transaction := wallet.NewTransaction(privateKey, publicKey,
-			*t.SenderBlockChainAddress, *t.ReceiverBlockChainAddress, value32)
-		signature := transaction.GenerateSignature()
-		signatureStr := signature.String()
+		if t.LockTime < 0 {
+			log.Printf("ERROR: invalid lock time %d", t.LockTime)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid lock time")))
+			return
+		}
+		sender, err := ws.accounts.Signer(*t.Account, req.Header.Get(SESSION_HEADER))
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		var memo []byte
+		if t.Memo != nil {
+			memo = []byte(*t.Memo)
+		}
+		if len(memo) > block.MAX_DATA_SIZE {
+			log.Printf("ERROR: memo too long")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(fmt.Sprintf("memo exceeds %d bytes", block.MAX_DATA_SIZE))))
+			return
+		}
+		nonce, err := ws.fetchNonce(sender.BlockChainAddress())
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		ws.broadcast(w, wallet.NewTransferTransaction(ws.params, sender, *t.ReceiverBlockChainAddress, float32(value), nonce, t.LockTime, memo))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 向区块链服务器发送已签名的交易事务,成功时返回交易内容
+func (ws *WalletServer) broadcast(w http.ResponseWriter, bt *block.TransactionRequest) {
+	if status, err := ws.submit(bt); err != nil {
+		w.WriteHeader(status)
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+		return
+	}
+	m, _ := json.Marshal(bt)
+	io.WriteString(w, string(m[:]))
+}
 
-		bt := &block.TransactionRequest{
-			t.SenderBlockChainAddress,
-			t.ReceiverBlockChainAddress,
-			t.SenderPublicKey,
-			&value32, &signatureStr,
+// 发送交易,失败时返回应答的状态码和原因,节点拒绝时为节点给出的原因
+func (ws *WalletServer) submit(bt *block.TransactionRequest) (int, error) {
+	m, _ := json.Marshal(bt)
+	url := "http://" + ws.Gateway() + "/transactions"
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(m))
+	if err != nil {
+		log.Printf("ERROR: failed to post transaction to gateway: %v", err)
+		return http.StatusBadGateway, errors.New("fail")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return http.StatusOK, nil
+	}
+	var status struct {
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&status)
+	return http.StatusBadRequest, errors.New(status.Message)
+}
+
+// 构建未签名交易,由离线签名工具签名
+func (ws *WalletServer) BuildTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var br wallet.BuildTransactionRequest
+		if err := json.NewDecoder(req.Body).Decode(&br); err != nil || !br.Validate() {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		for _, a := range []string{*br.SenderBlockChainAddress, *br.ReceiverBlockChainAddress} {
+			if err := address.Validate(a, ws.params); err != nil {
+				log.Printf("ERROR: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus(err.Error())))
+				return
+			}
+		}
+		value, err := strconv.ParseFloat(*br.Value, 32)
+		if err != nil || value <= 0 {
+			log.Printf("ERROR: invalid value %q", *br.Value)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid value")))
+			return
+		}
+		if br.LockTime < 0 {
+			log.Printf("ERROR: invalid lock time %d", br.LockTime)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("invalid lock time")))
+			return
+		}
+		var memo []byte
+		if br.Memo != nil {
+			memo = []byte(*br.Memo)
+		}
+		if len(memo) > block.MAX_DATA_SIZE {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(fmt.Sprintf("memo exceeds %d bytes", block.MAX_DATA_SIZE))))
+			return
+		}
+		//余额不足的交易签名后也会被节点拒绝
+		amount, err := ws.fetchAmount(*br.SenderBlockChainAddress)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
 		}
-		m, _ := json.Marshal(bt)
-		buffer := bytes.NewBuffer(m)
-		log.Println(ws.Gateway())
-		//向区块链服务器发送交易事务
-		url := "http://" + ws.Gateway() + "/transactions"
-		resp, err := http.Post(url, "application/json", buffer)
+		if amount < float32(value)+float32(len(memo))*block.DATA_BYTE_FEE {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("not enough balance")))
+			return
+		}
+		nonce, err := ws.fetchNonce(*br.SenderBlockChainAddress)
 		if err != nil {
-			log.Printf("ERROR: failed to post transaction to gateway: %v", err)
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-		if resp.StatusCode == http.StatusOK {
-			io.WriteString(w, string(m[:]))
-			//io.WriteString(w, string(utils.JsonStatus("success")))
+		ut := wallet.NewUnsignedTransaction(ws.params,
+			*br.SenderBlockChainAddress, *br.ReceiverBlockChainAddress, float32(value), nonce, br.LockTime, memo)
+		m, _ := json.Marshal(ut)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 广播离线签名的交易
+func (ws *WalletServer) BroadcastTransaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var bt block.TransactionRequest
+		if err := json.NewDecoder(req.Body).Decode(&bt); err != nil || !bt.Validate() {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-		io.WriteString(w, string(utils.JsonStatus("fail")))
+		ws.broadcast(w, &bt)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		log.Println("ERROR: Invalid HTTP Method")
 	}
 }
 
-func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
+// 账户: GET列出账户,POST创建账户
+func (ws *WalletServer) Accounts(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
 	switch req.Method {
 	case http.MethodGet:
-		blockchinAddress := req.URL.Query().Get("blockchain_address")
-		url := "http://" + ws.Gateway()
-		endpoint := fmt.Sprintf("%s/amount", url)
+		accounts, err := ws.accounts.Accounts()
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Accounts []*wallet.Account `json:"accounts"`
+		}{
+			Accounts: accounts,
+		})
+		io.WriteString(w, string(m[:]))
+	case http.MethodPost:
+		var ar wallet.AccountRequest
+		if err := json.NewDecoder(req.Body).Decode(&ar); err != nil || ar.Account == nil || ar.Passphrase == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		address := ""
+		if ar.Address != nil {
+			address = *ar.Address
+		}
+		curve, err := ws.curveOrDefault(req.URL.Query().Get("curve"))
+		var account *wallet.Account
+		if err == nil {
+			account, err = ws.accounts.CreateAccount(*ar.Account, address, curve, *ar.Passphrase)
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		m, _ := json.Marshal(account)
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
 
-		client := &http.Client{}
-		bcsReq, _ := http.NewRequest("GET", endpoint, nil)
-		q := bcsReq.URL.Query()
-		q.Add("blockchin_address", blockchinAddress)
-		bcsReq.URL.RawQuery = q.Encode()
+// 解锁账户,返回会话令牌
+func (ws *WalletServer) UnlockAccount(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var ar wallet.AccountRequest
+		if err := json.NewDecoder(req.Body).Decode(&ar); err != nil || ar.Account == nil || ar.Passphrase == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		ttl := 0
+		if ar.TTL != nil {
+			ttl = *ar.TTL
+		}
+		token, expires, account, err := ws.accounts.Unlock(*ar.Account, *ar.Passphrase, ttl)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Session string          `json:"session"`
+			Expires int64           `json:"expires"`
+			Account *wallet.Account `json:"account"`
+		}{
+			Session: token,
+			Expires: expires.Unix(),
+			Account: account,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
 
-		bcsResp, err := client.Do(bcsReq)
+// 锁定账户,结束请求头中的会话
+func (ws *WalletServer) LockAccount(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		ws.accounts.Lock(req.Header.Get(SESSION_HEADER))
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
 
+// 密钥库: GET列出地址,POST导入私钥或加密的密钥文件
+func (ws *WalletServer) KeyStore(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	switch req.Method {
+	case http.MethodGet:
+		keys, err := ws.keystore.List()
 		if err != nil {
 			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-		w.Header().Add("Content-type", "application/json")
-		if bcsResp.StatusCode == 200 {
-			decoder := json.NewDecoder(bcsResp.Body)
-			var bar block.AmountResponse
-			err := decoder.Decode(&bar)
-			if err != nil {
-				log.Printf("ERROR: %v", err)
-				io.WriteString(w, string(utils.JsonStatus("fail")))
-				return
+		m, _ := json.Marshal(struct {
+			Keys []*wallet.KeyInfo `json:"keys"`
+		}{
+			Keys: keys,
+		})
+		io.WriteString(w, string(m[:]))
+	case http.MethodPost:
+		var kr wallet.KeyStoreRequest
+		if err := json.NewDecoder(req.Body).Decode(&kr); err != nil || kr.Passphrase == nil ||
+			(kr.PrivateKey == nil && len(kr.KeyFile) == 0) {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		var imported *wallet.Wallet
+		var err error
+		if kr.PrivateKey != nil {
+			var curve elliptic.Curve
+			if curve, err = ws.curveOrDefault(req.URL.Query().Get("curve")); err == nil {
+				imported, err = ws.keystore.ImportPrivateKey(curve, *kr.PrivateKey, *kr.Passphrase)
 			}
-			m, _ := json.Marshal(struct {
-				Message string  `json:"message"`
-				Amount  float32 `json:"amount"`
-			}{
-				Message: "Success",
-				Amount:  bar.Amount,
-			})
-			io.WriteString(w, string(m[:]))
 		} else {
+			imported, err = ws.keystore.Import(kr.KeyFile, *kr.Passphrase)
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		m, _ := json.Marshal(imported.KeyInfo())
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 导出加密的密钥文件,用于备份或导入到其他节点
+func (ws *WalletServer) ExportKey(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		data, err := ws.keystore.Export(req.URL.Query().Get("address"))
+		if err == wallet.ErrKeyNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		blockchinAddress := req.URL.Query().Get("blockchain_address")
+		w.Header().Add("Content-type", "application/json")
+		amount, err := ws.fetchAmount(blockchinAddress)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
 		}
+		m, _ := json.Marshal(struct {
+			Message string  `json:"message"`
+			Amount  float32 `json:"amount"`
+		}{
+			Message: "Success",
+			Amount:  amount,
+		})
+		io.WriteString(w, string(m[:]))
 	default:
 		log.Printf("ERROR: Invalid HTTP Method")
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+// 从区块链节点查询地址余额
+func (ws *WalletServer) fetchAmount(address string) (float32, error) {
+	url := "http://" + ws.Gateway()
+	endpoint := fmt.Sprintf("%s/amount", url)
+
+	client := &http.Client{}
+	bcsReq, _ := http.NewRequest("GET", endpoint, nil)
+	q := bcsReq.URL.Query()
+	q.Add("blockchin_address", address)
+	bcsReq.URL.RawQuery = q.Encode()
+
+	bcsResp, err := client.Do(bcsReq)
+	if err != nil {
+		return 0, err
+	}
+	defer bcsResp.Body.Close()
+	if bcsResp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("gateway returned status %d", bcsResp.StatusCode)
+	}
+	var bar block.AmountResponse
+	if err := json.NewDecoder(bcsResp.Body).Decode(&bar); err != nil {
+		return 0, err
+	}
+	return bar.Amount, nil
+}
+
+// 创建HD钱包,种子和派生的私钥加密保存到密钥库,只在这里返回一次助记词用于备份
+func (ws *WalletServer) HDWallet(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var hr wallet.HDWalletRequest
+		if err := json.NewDecoder(req.Body).Decode(&hr); err != nil || hr.KeystorePassphrase == nil {
+			log.Println("ERROR: missing keystore passphrase")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("keystore_passphrase required")))
+			return
+		}
+		curve, err := ws.curveOrDefault(hr.CurveOrEmpty())
+		var hw *wallet.HDWallet
+		if err == nil {
+			hw, err = wallet.NewHDWallet(ws.params, curve, hr.PassphraseOrEmpty(), hr.PathOrEmpty())
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		if err := ws.keystore.StoreHDWallet(hw, *hr.KeystorePassphrase); err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(hdErrorStatus(err))
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		first, err := hw.Wallet(0)
+		var keys []*wallet.KeyInfo
+		if err == nil {
+			keys, err = ws.keystore.StoreDerived([]*wallet.Wallet{first}, *hr.KeystorePassphrase)
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Mnemonic string          `json:"mnemonic"`
+			Path     string          `json:"path"`
+			Index    uint32          `json:"index"`
+			Key      *wallet.KeyInfo `json:"key"`
+		}{
+			Mnemonic: hw.Mnemonic(),
+			Path:     hw.Path(),
+			Index:    0,
+			Key:      keys[0],
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 由助记词恢复HD钱包并加密保存种子,不提供助记词时重新扫描已保存的HD钱包
+// 按gap limit扫描用过的地址,并把它们的私钥加密保存到密钥库
+func (ws *WalletServer) RestoreHDWallet(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var hr wallet.HDWalletRequest
+		if err := json.NewDecoder(req.Body).Decode(&hr); err != nil || hr.KeystorePassphrase == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		var hw *wallet.HDWallet
+		var err error
+		if hr.Mnemonic == nil {
+			hw, err = ws.keystore.LoadHDWallet(*hr.KeystorePassphrase)
+		} else {
+			var curve elliptic.Curve
+			if curve, err = ws.curveOrDefault(hr.CurveOrEmpty()); err == nil {
+				hw, err = wallet.RestoreHDWallet(ws.params, curve, *hr.Mnemonic, hr.PassphraseOrEmpty(), hr.PathOrEmpty())
+			}
+			if err == nil {
+				err = ws.keystore.StoreHDWallet(hw, *hr.KeystorePassphrase)
+			}
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(hdErrorStatus(err))
+			io.WriteString(w, string(utils.JsonStatus(err.Error())))
+			return
+		}
+		gapLimit := wallet.DEFAULT_GAP_LIMIT
+		if hr.GapLimit != nil {
+			gapLimit = *hr.GapLimit
+		}
+		wallets, next, err := hw.Scan(gapLimit, ws.addressUsed)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		keys, err := ws.keystore.StoreDerived(wallets, *hr.KeystorePassphrase)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Path      string            `json:"path"`
+			NextIndex uint32            `json:"next_index"`
+			Keys      []*wallet.KeyInfo `json:"keys"`
+		}{
+			Path:      hw.Path(),
+			NextIndex: next,
+			Keys:      keys,
+		})
+		io.WriteString(w, string(m[:]))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 由密钥库中保存的HD钱包派生指定索引的地址,私钥加密保存到密钥库
+func (ws *WalletServer) DeriveHDWallet(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var hr wallet.HDWalletRequest
+		if err := json.NewDecoder(req.Body).Decode(&hr); err != nil || hr.Index == nil || hr.KeystorePassphrase == nil {
+			log.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		hw, err := ws.keystore.LoadHDWallet(*hr.KeystorePassphrase)
+		if err == nil {
+			var child *wallet.Wallet
+			var keys []*wallet.KeyInfo
+			if child, err = hw.Wallet(*hr.Index); err == nil {
+				keys, err = ws.keystore.StoreDerived([]*wallet.Wallet{child}, *hr.KeystorePassphrase)
+			}
+			if err == nil {
+				m, _ := json.Marshal(keys[0])
+				io.WriteString(w, string(m[:]))
+				return
+			}
+		}
+		log.Printf("ERROR: %v", err)
+		w.WriteHeader(hdErrorStatus(err))
+		io.WriteString(w, string(utils.JsonStatus(err.Error())))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		log.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// 密码错误返回401,已保存另一个HD钱包返回409
+func hdErrorStatus(err error) int {
+	switch err {
+	case wallet.ErrDecrypt:
+		return http.StatusUnauthorized
+	case wallet.ErrHDWalletExists:
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// 地址在链上有交易记录或发出过交易时视为已使用,余额为0的地址也可能用过
+func (ws *WalletServer) addressUsed(blockChainAddress string) (bool, error) {
+	var history struct {
+		Total int `json:"total"`
+	}
+	if err := ws.fetchJSON("/address/"+url.PathEscape(blockChainAddress)+"/transactions?limit=1", &history); err != nil {
+		return false, err
+	}
+	if history.Total > 0 {
+		return true, nil
+	}
+	nonce, err := ws.fetchNonce(blockChainAddress)
+	return nonce > 0, err
+}
+
 func (ws *WalletServer) Run() {
 	http.HandleFunc("/", ws.Index)
 	http.HandleFunc("/wallet", ws.Wallet)
 	http.HandleFunc("/wallet/amount", ws.WalletAmount)
+	http.HandleFunc("/wallet/hd", ws.HDWallet)
+	http.HandleFunc("/wallet/hd/restore", ws.RestoreHDWallet)
+	http.HandleFunc("/wallet/hd/derive", ws.DeriveHDWallet)
+	http.HandleFunc("/keystore", ws.KeyStore)
+	http.HandleFunc("/keystore/export", ws.ExportKey)
+	http.HandleFunc("/accounts", ws.Accounts)
+	http.HandleFunc("/accounts/unlock", ws.UnlockAccount)
+	http.HandleFunc("/accounts/lock", ws.LockAccount)
 	http.HandleFunc("/transaction", ws.CreateTransaction)
+	http.HandleFunc("/transaction/build", ws.BuildTransaction)
+	http.HandleFunc("/transaction/broadcast", ws.BroadcastTransaction)
+	http.HandleFunc("/multisig", ws.Multisig)
+	http.HandleFunc("/multisig/build", ws.BuildMultisigTransaction)
+	http.HandleFunc("/multisig/sign", ws.SignMultisigTransaction)
+	http.HandleFunc("/multisig/combine", ws.CombineMultisigTransactions)
+	http.HandleFunc("/multisig/broadcast", ws.BroadcastMultisigTransaction)
+	http.HandleFunc("/script", ws.Script)
+	http.HandleFunc("/script/build", ws.BuildScriptTransaction)
+	http.HandleFunc("/script/broadcast", ws.BroadcastScriptTransaction)
+	http.HandleFunc("/htlc", ws.HTLC)
+	http.HandleFunc("/htlc/redeem", ws.RedeemHTLC)
+	http.HandleFunc("/htlc/refund", ws.RefundHTLC)
+	http.HandleFunc("/contract/deploy", ws.DeployContract)
+	http.HandleFunc("/contract/call", ws.CallContract)
+	http.HandleFunc("/token/create", ws.CreateToken)
+	http.HandleFunc("/token/transfer", ws.TransferToken)
+	http.HandleFunc("/wallet/tokens", ws.WalletTokens)
 	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(ws.GetPort())), nil))
 }